@@ -1,223 +1,246 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
 	openai "github.com/sashabaranov/go-openai"
 )
 
-func generateResume(m *model) tea.Cmd {
-	return func() tea.Msg {
-		m.addLog("Starting resume generation.")
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" {
-			errMsg := "OPENAI_API_KEY environment variable not set"
-			m.addLog(errMsg)
-			return fmt.Errorf(errMsg)
-		}
+// Default files written by the generation actions
+const (
+	resumeOutputFile      = "generated_resume.md" // The extension follows the format
+	coverLetterOutputFile = "generated_cover_letter.txt"
+	jsonResumeOutputFile  = "resume.json"
+)
+
+// Default system prompts for the AI actions
+const (
+	resumeSystemPrompt      = "You are a professional resume writer. You will not have all the context you need, but do the best you can use the context of the readmes and project to extrapolate and write good detailed prject sections. Make sure its structured like a resume and only shows the most prominent projects. Extrapolate all the other sections based on the info you have. Make sure to include the most relevant projects and skills."
+	coverLetterSystemPrompt = "You are a professional cover letter writer. Generate a compelling cover letter based on the provided information. Tailor the letter to highlight the candidate's skills and experiences that are most relevant to a software development position."
+	chatSystemPrompt        = "You are chatting with a user profile-based assistant."
+)
+
+// errNotJSONResume reports a structured resume request answered with something else
+var errNotJSONResume = errors.New("reply is not a JSON Resume document")
+
+// jsonResumeInstruction asks for a structured reply; the fake provider answers
+// prompts containing it with a JSON Resume
+const jsonResumeInstruction = "Reply with a single JSON object in the jsonresume.org schema and nothing else."
+
+func generateResume(ctx context.Context, m *model, format string) tea.Cmd {
+	m.addLog(fmt.Sprintf("Starting resume generation as %s.", format))
 
-		client := openai.NewClient(apiKey)
-		ctx := context.Background()
+	// Snapshot everything the command needs so it never touches the model
+	current, inputData := m.profile.jsonResume(), m.profile.render()
+	llm, llmConfig, resumeConfig, send := m.llm, m.llmConfig, m.resumeConfig, m.sender()
+	prompt, outputFile := m.prompts.Resume, resumeOutputPath(m.paths.ResumeOutput, format)
 
-		inputData, err := prepareInputData(m)
+	return m.tagged(func() tea.Msg {
+		resume, err := composeResumeDocument(ctx, llm, llmConfig, resumeConfig, prompt, format, current, inputData)
+		if ctx.Err() != nil {
+			send(LogMsg("Resume generation cancelled."))
+			return ctx.Err()
+		}
 		if err != nil {
-			errMsg := fmt.Sprintf("Error preparing input data: %v", err)
-			m.addLog(errMsg)
+			errMsg := fmt.Sprintf("Error generating resume: %v", err)
+			send(LogMsg(errMsg))
 			return fmt.Errorf(errMsg)
 		}
 
-		req := openai.ChatCompletionRequest{
-			Model: "gpt-4",
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    "system",
-					Content: "You are a professional resume writer. You will not have all the context you need, but do the best you can use the context of the readmes and project to extrapolate and write good detailed prject sections. Make sure its structured like a resume and only shows the most prominent projects. Extrapolate all the other sections based on the info you have. Make sure to include the most relevant projects and skills.",
-				},
-				{
-					Role:    "user",
-					Content: fmt.Sprintf("Using the following data, generate a professional resume:\n\n%s", inputData),
-				},
-			},
-			MaxTokens:   1000,
-			Temperature: 0.7,
-		}
-
-		resp, err := client.CreateChatCompletion(ctx, req)
+		err = writeFileAtomic(outputFile, resume)
 		if err != nil {
-			errMsg := fmt.Sprintf("Error generating resume: %v", err)
-			m.addLog(errMsg)
+			errMsg := fmt.Sprintf("Error saving resume: %v", err)
+			send(LogMsg(errMsg))
 			return fmt.Errorf(errMsg)
 		}
 
-		if len(resp.Choices) == 0 {
-			errMsg := "No response from GPT-4"
-			m.addLog(errMsg)
+		successMsg := fmt.Sprintf("Resume generated and saved to '%s'", outputFile)
+		if format == formatLaTeX {
+			pdfFile, err := compileLaTeX(ctx, resumeConfig.LaTeXEngine, outputFile)
+			switch {
+			case err != nil:
+				send(LogMsg(fmt.Sprintf("Error compiling LaTeX: %v", err)))
+				successMsg += fmt.Sprintf(", but compiling it failed: %v", err)
+			case pdfFile != "":
+				successMsg += fmt.Sprintf(" and compiled to '%s'", pdfFile)
+			}
+		}
+		send(LogMsg(successMsg))
+		return successMsg
+	})
+}
+
+func (m *model) generateCoverLetter(ctx context.Context) tea.Cmd {
+	m.addLog("Starting cover letter generation.")
+
+	// Snapshot everything the command needs so it never touches the model
+	inputData := m.profile.render()
+	llm, llmConfig, send := m.llm, m.llmConfig, m.sender()
+	prompt, outputFile := m.prompts.CoverLetter, m.paths.CoverLetterOutput
+
+	return m.tagged(func() tea.Msg {
+		coverLetter, err := composeCoverLetter(ctx, llm, llmConfig, prompt, inputData, "")
+		if ctx.Err() != nil {
+			send(LogMsg("Cover letter generation cancelled."))
+			return ctx.Err()
+		}
+		if err != nil {
+			errMsg := fmt.Sprintf("Error generating cover letter: %v", err)
+			send(LogMsg(errMsg))
 			return fmt.Errorf(errMsg)
 		}
 
-		err = os.WriteFile("generated_resume.txt", []byte(resp.Choices[0].Message.Content), 0600)
+		err = writeFileAtomic(outputFile, []byte(coverLetter))
 		if err != nil {
-			errMsg := fmt.Sprintf("Error saving resume: %v", err)
-			m.addLog(errMsg)
+			errMsg := fmt.Sprintf("Error saving cover letter: %v", err)
+			send(LogMsg(errMsg))
 			return fmt.Errorf(errMsg)
 		}
 
-		successMsg := "Resume generated and saved to 'generated_resume.txt'"
-		m.addLog(successMsg)
+		successMsg := fmt.Sprintf("Cover letter generated and saved to '%s'", outputFile)
+		send(LogMsg(successMsg))
 		return successMsg
-	}
+	})
 }
 
-func (m *model) generateCoverLetter() tea.Msg {
-	m.addLog("Starting cover letter generation.")
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		errMsg := "OPENAI_API_KEY environment variable not set"
-		m.addLog(errMsg)
-		return fmt.Errorf(errMsg)
-	}
-
-	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+// composeResume asks the provider for a resume built from inputData
+func composeResume(ctx context.Context, llm LLMProvider, cfg LLMConfig, systemPrompt, inputData string) (string, error) {
+	return llm.Complete(ctx, CompletionRequest{
+		Messages: []ChatMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Using the following data, generate a professional resume:\n\n%s", inputData)},
+		},
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	})
+}
 
-	inputData, err := prepareInputData(m)
+// composeResumeDocument generates a resume in format from the profile as a
+// JSON Resume and as rendered prompt input. Plain text is the model's reply
+// as is; every other format is rendered from a structured reply, or from the
+// profile itself when the model does not reply with one.
+func composeResumeDocument(ctx context.Context, llm LLMProvider, cfg LLMConfig, resumeConfig ResumeConfig, systemPrompt, format string, current *JSONResume, inputData string) ([]byte, error) {
+	if format == formatText {
+		resume, err := composeResume(ctx, llm, cfg, systemPrompt, inputData)
+		return []byte(resume), err
+	}
+	resume, err := composeJSONResume(ctx, llm, cfg, systemPrompt, current, inputData)
+	if errors.Is(err, errNotJSONResume) {
+		if logger != nil {
+			logger.Printf("%s did not reply with a JSON Resume; rendering the profile as is", llm.Name())
+		}
+		resume, err = current, nil
+	}
 	if err != nil {
-		errMsg := fmt.Sprintf("Error preparing input data: %v", err)
-		m.addLog(errMsg)
-		return fmt.Errorf(errMsg)
+		return nil, err
 	}
+	return renderResume(resume, format, resumeConfig)
+}
 
-	req := openai.ChatCompletionRequest{
-		Model: "gpt-4",
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    "system",
-				Content: "You are a professional cover letter writer. Generate a compelling cover letter based on the provided information. Tailor the letter to highlight the candidate's skills and experiences that are most relevant to a software development position.",
-			},
-			{
-				Role:    "user",
-				Content: fmt.Sprintf("Using the following data, generate a professional cover letter:\n\n%s", inputData),
-			},
+// composeJSONResume asks the provider for a resume in the JSON Resume schema,
+// with the larger token budget a complete document needs. Contact details the
+// reply leaves out are filled in from the profile.
+func composeJSONResume(ctx context.Context, llm LLMProvider, cfg LLMConfig, systemPrompt string, profile *JSONResume, inputData string) (*JSONResume, error) {
+	current, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	reply, err := llm.Complete(ctx, CompletionRequest{
+		Messages: []ChatMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("Using the following data, generate a professional resume. %s\n\nProfile as JSON Resume:\n%s\n\nFull profile:\n%s", jsonResumeInstruction, current, inputData)},
 		},
-		MaxTokens:   1000,
-		Temperature: 0.7,
+		MaxTokens:   cfg.ResumeMaxTokens,
+		Temperature: cfg.Temperature,
+	})
+	if errors.Is(err, errTruncatedReply) {
+		return nil, fmt.Errorf("the JSON resume was cut off at %d tokens; raise llm.resume_max_tokens or AMALGIA_LLM_RESUME_MAX_TOKENS", cfg.ResumeMaxTokens)
 	}
-
-	resp, err := client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		errMsg := fmt.Sprintf("Error generating cover letter: %v", err)
-		m.addLog(errMsg)
-		return fmt.Errorf(errMsg)
+		return nil, err
 	}
 
-	if len(resp.Choices) == 0 {
-		errMsg := "No response from GPT-4"
-		m.addLog(errMsg)
-		return fmt.Errorf(errMsg)
-	}
-
-	err = os.WriteFile("generated_cover_letter.txt", []byte(resp.Choices[0].Message.Content), 0600)
+	resume, ok, err := parseJSONResume([]byte(extractJSON(reply)))
 	if err != nil {
-		errMsg := fmt.Sprintf("Error saving cover letter: %v", err)
-		m.addLog(errMsg)
-		return fmt.Errorf(errMsg)
+		return nil, err
 	}
-
-	successMsg := "Cover letter generated and saved to 'generated_cover_letter.txt'"
-	m.addLog(successMsg)
-	return successMsg
-}
-
-func prepareInputData(m *model) (string, error) {
-	var buffer bytes.Buffer
-
-	if len(m.selected) == 0 {
-		buffer.WriteString("No additional files provided.\n\n")
-	} else {
-		for _, file := range m.selected {
-			content, err := os.ReadFile(file)
-			if err != nil {
-				errMsg := fmt.Sprintf("Error reading file %s: %v", file, err)
-				m.addLog(errMsg)
-				return "", fmt.Errorf(errMsg)
-			}
-			buffer.WriteString(fmt.Sprintf("File: %s\n", filepath.Base(file)))
-			buffer.Write(content)
-			buffer.WriteString("\n\n")
-		}
+	if !ok {
+		return nil, errNotJSONResume
 	}
+	resume.fillBasics(profile.Basics)
+	return resume, nil
+}
 
-	// Include selected READMEs
-	selectedReadmeCount := 0
-	for name, selected := range m.selectedREADMEs {
-		if selected {
-			selectedReadmeCount++
-			content, ok := m.readmes[name]
-			if !ok {
-				errMsg := fmt.Sprintf("README content for %s not found", name)
-				m.addLog(errMsg)
-				return "", fmt.Errorf(errMsg)
-			}
-			buffer.WriteString(fmt.Sprintf("Project: %s\n", name))
-			buffer.WriteString(content)
-			buffer.WriteString("\n\n")
-		}
+// composeCoverLetter asks the provider for a cover letter, tailored to job when one is given
+func composeCoverLetter(ctx context.Context, llm LLMProvider, cfg LLMConfig, systemPrompt, inputData, job string) (string, error) {
+	prompt := fmt.Sprintf("Using the following data, generate a professional cover letter:\n\n%s", inputData)
+	if job != "" {
+		prompt += fmt.Sprintf("\n\nTailor the letter to this job description:\n\n%s", job)
 	}
+	return llm.Complete(ctx, CompletionRequest{
+		Messages: []ChatMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
+		},
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+	})
+}
 
-	if selectedReadmeCount == 0 {
-		buffer.WriteString("No GitHub README files selected.\n\n")
-	}
+// composeChatReply answers a single chat message about the profile
+func composeChatReply(ctx context.Context, llm LLMProvider, cfg LLMConfig, systemPrompt, profileData, userMessage string) (string, error) {
+	return llm.Complete(ctx, CompletionRequest{
+		Messages: []ChatMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: fmt.Sprintf("%s\n\nProfile:\n\n%s", systemPrompt, profileData)},
+			{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("User message: %s", userMessage)},
+		},
+		MaxTokens:   cfg.ChatMaxTokens,
+		Temperature: cfg.Temperature,
+	})
+}
 
-	return buffer.String(), nil
+// ChatReplyMsg delivers the assistant's answer, or the error that prevented one
+type ChatReplyMsg struct {
+	Reply string
+	Err   error
 }
 
 func (m *model) sendChatMessage() tea.Cmd {
 	userMessage := m.chatInput
 	m.chatHistory = append(m.chatHistory, "You: "+userMessage)
 	m.chatInput = "" // Clear the input after sending
+	ctx := m.newActionContext()
+	llm, llmConfig, prompt, profileData := m.llm, m.llmConfig, m.prompts.Chat, m.profile.render()
 
-	return func() tea.Msg {
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" {
-			errMsg := "OPENAI_API_KEY environment variable not set"
-			return fmt.Errorf(errMsg)
-		}
-
-		client := openai.NewClient(apiKey)
-		ctx := context.Background()
-
-		req := openai.ChatCompletionRequest{
-			Model: "gpt-4",
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    "system",
-					Content: "You are chatting with a user profile-based assistant.",
-				},
-				{
-					Role:    "user",
-					Content: fmt.Sprintf("User message: %s", userMessage),
-				},
-			},
-			MaxTokens:   500,
-			Temperature: 0.7,
+	return m.tagged(func() tea.Msg {
+		response, err := composeChatReply(ctx, llm, llmConfig, prompt, profileData, userMessage)
+		if ctx.Err() != nil {
+			return ChatReplyMsg{Err: ctx.Err()}
 		}
-
-		resp, err := client.CreateChatCompletion(ctx, req)
 		if err != nil {
-			return fmt.Errorf("Error during chat: %v", err)
+			return ChatReplyMsg{Err: fmt.Errorf("Error during chat: %v", err)}
 		}
 
-		if len(resp.Choices) == 0 {
-			return fmt.Errorf("No response from GPT-4")
-		}
+		return ChatReplyMsg{Reply: response}
+	})
+}
 
-		response := resp.Choices[0].Message.Content
-		m.chatHistory = append(m.chatHistory, "Assistant: "+response)
-		return nil
+// writeFileAtomic writes data to a temporary file and renames it into place,
+// so cancelled or failed writes never leave a truncated file behind
+func writeFileAtomic(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
 	}
+	return nil
 }
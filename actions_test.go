@@ -0,0 +1,128 @@
+// Filename: actions_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordingProvider answers every request with reply and keeps the requests
+type recordingProvider struct {
+	fakeProvider
+	reply    string
+	requests []CompletionRequest
+}
+
+func (p *recordingProvider) Complete(ctx context.Context, req CompletionRequest) (string, error) {
+	p.requests = append(p.requests, req)
+	return p.reply, nil
+}
+
+func TestComposeCoverLetterSeparatesJobDescription(t *testing.T) {
+	llm := &recordingProvider{reply: "Dear hiring manager"}
+	inputData := "# Contact\nName: Octo Cat"
+	if _, err := composeCoverLetter(context.Background(), llm, defaultLLMConfig(), coverLetterSystemPrompt, inputData, "Senior Go engineer"); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := llm.requests[0].Messages[1].Content
+	if !strings.Contains(prompt, "Name: Octo Cat\n\nTailor the letter to this job description:\n\nSenior Go engineer") {
+		t.Errorf("job description is not separated from the profile:\n%s", prompt)
+	}
+}
+
+func TestComposeJSONResumeUsesResumeTokenLimit(t *testing.T) {
+	llm := &recordingProvider{reply: testResumeReply}
+	cfg := defaultLLMConfig()
+	if _, err := composeJSONResume(context.Background(), llm, cfg, resumeSystemPrompt, &JSONResume{}, "# Contact"); err != nil {
+		t.Fatal(err)
+	}
+	if got := llm.requests[0].MaxTokens; got != cfg.ResumeMaxTokens {
+		t.Errorf("requested %d tokens, want the resume limit of %d", got, cfg.ResumeMaxTokens)
+	}
+}
+
+func TestComposeJSONResumeReportsTruncatedReply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"model":   "test-model",
+			"choices": []map[string]interface{}{{"index": 0, "finish_reason": "length", "message": map[string]string{"role": "assistant", "content": `{"basics":{"name":"Octo`}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := defaultLLMConfig()
+	cfg.Provider = providerOpenAICompatible
+	cfg.BaseURL = server.URL + "/v1"
+	llm, err := newLLMProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = composeJSONResume(context.Background(), llm, cfg, resumeSystemPrompt, &JSONResume{}, "# Contact")
+	if err == nil || !strings.Contains(err.Error(), "cut off at 3000 tokens") {
+		t.Errorf("got %v, want the truncated reply reported", err)
+	}
+}
+
+func TestComposeResumeDocumentWithFakeProvider(t *testing.T) {
+	llm, err := newFakeProvider(writeFakeFixtures(t, FakeFixtures{Default: "```json\n" + testResumeReply + "\n```"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := &JSONResume{}
+	current.Basics.Email = "octo@example.com"
+
+	out, err := composeResumeDocument(context.Background(), llm, defaultLLMConfig(), defaultResumeConfig(), resumeSystemPrompt, formatMarkdown, current, "# Contact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Octo Cat", "octo@example.com", "demo"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("resume does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestComposeResumeDocumentWithDefaultFakeProvider(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := &Profile{Contact: Contact{Name: "Octo Cat", Email: "octo@example.com"}}
+
+	cfg := defaultResumeConfig()
+	out, err := composeResumeDocument(context.Background(), llm, defaultLLMConfig(), cfg, resumeSystemPrompt, cfg.Format, profile.jsonResume(), profile.render())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Octo Cat", "octo@example.com", "[fake response "} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("resume does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestComposeResumeDocumentRendersProfileWithoutJSONReply(t *testing.T) {
+	llm := &recordingProvider{reply: "Octo Cat\nGo developer with ten years of experience."}
+	profile := &Profile{
+		Contact:    Contact{Name: "Octo Cat"},
+		Experience: []Experience{{Company: "Example", Title: "Engineer"}},
+	}
+
+	out, err := composeResumeDocument(context.Background(), llm, defaultLLMConfig(), defaultResumeConfig(), resumeSystemPrompt, formatHTML, profile.jsonResume(), profile.render())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Octo Cat", "Example", "Engineer"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("resume does not contain %q:\n%s", want, out)
+		}
+	}
+}
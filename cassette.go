@@ -0,0 +1,227 @@
+// Filename: cassette.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cassette modes
+const (
+	cassetteRecord = "record"
+	cassetteReplay = "replay"
+)
+
+// cassetteRedactedHeaders are never written to a cassette file
+var cassetteRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Openai-Organization"}
+
+// activeCassette is shared by the GitHub and OpenAI HTTP clients; nil when disabled
+var activeCassette *Cassette
+
+// CassetteInteraction is one recorded HTTP exchange
+type CassetteInteraction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHash    string      `json:"request_hash"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header"`
+	ResponseBody   string      `json:"response_body"`
+}
+
+// Cassette records HTTP exchanges to a file and replays them later
+type Cassette struct {
+	mode         string
+	path         string
+	mu           sync.Mutex
+	Interactions []CassetteInteraction `json:"interactions"`
+	used         []bool
+}
+
+// openCassette prepares a cassette for recording or loads one for replay
+func openCassette(mode, path string) (*Cassette, error) {
+	c := &Cassette{mode: mode, path: path}
+
+	switch mode {
+	case cassetteRecord:
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("creating cassette directory: %v", err)
+		}
+		return c, c.save()
+
+	case cassetteReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading cassette: %v", err)
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: %v", path, err)
+		}
+		c.used = make([]bool, len(c.Interactions))
+		return c, nil
+
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (expected %q or %q)", mode, cassetteRecord, cassetteReplay)
+	}
+}
+
+// cassetteFromConfig opens the configured cassette, or returns nil when disabled
+func cassetteFromConfig(cfg CassetteConfig) (*Cassette, error) {
+	if cfg.Mode == "" {
+		return nil, nil
+	}
+	return openCassette(cfg.Mode, cfg.Path)
+}
+
+// replaying reports whether requests are served from the cassette
+func (c *Cassette) replaying() bool {
+	return c != nil && c.mode == cassetteReplay
+}
+
+// save writes all recorded interactions; the caller must hold c.mu or own c
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// Close writes the interactions recorded so far. Recording keeps them in
+// memory, so Close must run before the process exits.
+func (c *Cassette) Close() error {
+	if c == nil || c.mode != cassetteRecord {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.save(); err != nil {
+		return fmt.Errorf("saving cassette %s: %v", c.path, err)
+	}
+	return nil
+}
+
+// Transport wraps next so that traffic is recorded to or replayed from the cassette
+func (c *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cassetteTransport{cassette: c, next: next}
+}
+
+// HTTPClient returns a client that uses the cassette, or base when disabled
+func (c *Cassette) HTTPClient(base *http.Client) *http.Client {
+	if c == nil {
+		return base
+	}
+	client := *base
+	client.Transport = c.Transport(base.Transport)
+	return &client
+}
+
+type cassetteTransport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+// requestHash identifies a request by method, URL and body
+func requestHash(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + url + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	url := req.URL.String()
+	hash := requestHash(req.Method, url, body)
+
+	if t.cassette.mode == cassetteReplay {
+		return t.cassette.replay(req, url, hash)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	for _, name := range cassetteRedactedHeaders {
+		header.Del(name)
+	}
+
+	c := t.cassette
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, CassetteInteraction{
+		Method:         req.Method,
+		URL:            url,
+		RequestHash:    hash,
+		RequestBody:    string(body),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: header,
+		ResponseBody:   string(respBody),
+	})
+	return resp, nil
+}
+
+// replay serves the first unused interaction with the same request hash.
+// Requests are never matched on the URL alone: every completion is a POST to
+// the same endpoint, so a changed prompt must fail instead of replaying the
+// answer to another one.
+func (c *Cassette) replay(req *http.Request, url, hash string) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	match := -1
+	for i, interaction := range c.Interactions {
+		if !c.used[i] && interaction.RequestHash == hash {
+			match = i
+			break
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("cassette %s has no recorded response for %s %s with this request body; record it again", c.path, req.Method, url)
+	}
+	c.used[match] = true
+
+	interaction := c.Interactions[match]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.ResponseBody))),
+		ContentLength: int64(len(interaction.ResponseBody)),
+		Request:       req,
+	}, nil
+}
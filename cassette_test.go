@@ -0,0 +1,167 @@
+// Filename: cassette_test.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testResumeReply is the structured resume the stand-in OpenAI server returns
+const testResumeReply = `{"basics":{"name":"Octo Cat","label":"Go developer"},"projects":[{"name":"demo","description":"A demo service","keywords":["Go"]}]}`
+
+// newCassetteTestServer stands in for GitHub Enterprise Server and an
+// OpenAI-compatible API, counting the requests it serves
+func newCassetteTestServer(t *testing.T, requests *int) *httptest.Server {
+	file := func(name, content string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":     "file",
+			"name":     name,
+			"path":     name,
+			"sha":      "sha-" + name,
+			"size":     len(content),
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+		}
+	}
+	routes := map[string]interface{}{
+		"/api/v3/user": map[string]string{"login": "octo"},
+		"/api/v3/user/repos": []map[string]interface{}{{
+			"id":          1,
+			"name":        "demo",
+			"full_name":   "octo/demo",
+			"owner":       map[string]string{"login": "octo"},
+			"description": "A demo service",
+			"html_url":    "https://github.example.com/octo/demo",
+			"pushed_at":   "2024-05-01T10:00:00Z",
+		}},
+		"/api/v3/repos/octo/demo/readme":          file("README.md", "# Demo\n\nA demo service written in Go.\n"),
+		"/api/v3/repos/octo/demo/languages":       map[string]int{"Go": 4096},
+		"/api/v3/repos/octo/demo/contents/":       []map[string]interface{}{file("go.mod", "")},
+		"/api/v3/repos/octo/demo/contents/go.mod": file("go.mod", "module example.com/demo\n\ngo 1.20\n"),
+		"/v1/chat/completions": map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"model":   "test-model",
+			"choices": []map[string]interface{}{{"index": 0, "finish_reason": "stop", "message": map[string]string{"role": "assistant", "content": testResumeReply}}},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fetchAndGenerate syncs the README of the stand-in repository into a new
+// directory and generates a Markdown resume from it
+func fetchAndGenerate(t *testing.T, baseURL, systemPrompt string) (FetchCompleteMsg, []byte, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	github := defaultGitHubConfig()
+	github.BaseURL = baseURL + "/api/v3/"
+	github.Workers = 1
+	github.MaxRetries = 0
+	github.Contributions = false
+	result, err := fetchREADMEs(ctx, github, t.TempDir(), func(tea.Msg) {})
+	if err != nil {
+		t.Fatalf("fetching READMEs: %v", err)
+	}
+
+	profile := &Profile{}
+	for _, name := range result.Names {
+		profile.addReadme(name, result.ReadmeSources[name], result.Readmes[name])
+	}
+	profile.setStack(result.Metadata)
+
+	llmConfig := defaultLLMConfig()
+	llmConfig.Provider = providerOpenAICompatible
+	llmConfig.BaseURL = baseURL + "/v1"
+	llmConfig.Model = "test-model"
+	llm, err := newLLMProvider(llmConfig)
+	if err != nil {
+		t.Fatalf("creating provider: %v", err)
+	}
+	resume, err := composeResumeDocument(ctx, llm, llmConfig, defaultResumeConfig(), systemPrompt, formatMarkdown, profile.jsonResume(), profile.render())
+	return result, resume, err
+}
+
+func TestCassetteReplaysFetchAndResume(t *testing.T) {
+	t.Cleanup(func() { activeCassette = nil })
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+
+	// Record against the stand-in servers
+	var requests int
+	server := newCassetteTestServer(t, &requests)
+	cassette, err := openCassette(cassetteRecord, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeCassette = cassette
+	recorded, recordedResume, err := fetchAndGenerate(t, server.URL, resumeSystemPrompt)
+	if err != nil {
+		t.Fatalf("generating resume while recording: %v", err)
+	}
+	if err := cassette.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != requests {
+		t.Fatalf("recorded %d interactions for %d requests", len(cassette.Interactions), requests)
+	}
+	if !strings.Contains(string(recordedResume), "Octo Cat") {
+		t.Fatalf("resume does not use the model's reply:\n%s", recordedResume)
+	}
+
+	// Replay without the servers or credentials
+	server.Close()
+	t.Setenv("GITHUB_TOKEN", "")
+	replay := func() (FetchCompleteMsg, []byte, error) {
+		cassette, err := openCassette(cassetteReplay, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		activeCassette = cassette
+		return fetchAndGenerate(t, server.URL, resumeSystemPrompt)
+	}
+	replayed, replayedResume, err := replay()
+	if err != nil {
+		t.Fatalf("generating resume on replay: %v", err)
+	}
+	if fmt.Sprint(replayed.Names) != fmt.Sprint(recorded.Names) || replayed.Readmes["demo"] != recorded.Readmes["demo"] {
+		t.Errorf("replayed READMEs %v differ from recorded %v", replayed.Readmes, recorded.Readmes)
+	}
+	if stack := stackNames(replayed.Metadata["demo"].Stack); !contains(stack, "Go") {
+		t.Errorf("replayed stack = %v, want Go from go.mod", stack)
+	}
+	if string(replayedResume) != string(recordedResume) {
+		t.Errorf("replayed resume differs:\n%s\nrecorded:\n%s", replayedResume, recordedResume)
+	}
+
+	// A changed prompt must not be served the answer to the recorded one
+	cassette, err = openCassette(cassetteReplay, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeCassette = cassette
+	_, _, err = fetchAndGenerate(t, server.URL, "A different system prompt")
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("changed prompt replayed with error %v, want a cassette miss", err)
+	}
+}
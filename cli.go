@@ -0,0 +1,545 @@
+// Filename: cli.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of the headless subcommands
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitPartial   = 3
+	exitCancelled = 130
+)
+
+// errUsage marks errors caused by invalid arguments
+var errUsage = errors.New("usage error")
+
+// errPartial marks commands that finished with some failures
+var errPartial = errors.New("completed with failures")
+
+// cliCommand is a headless subcommand
+type cliCommand struct {
+	name    string
+	usage   string
+	summary string
+	needLLM bool
+	run     func(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error
+}
+
+// cliCommands lists the subcommands in the order shown by help
+var cliCommands = []cliCommand{
+	{"fetch", "fetch [--json] [--quiet]", "Sync READMEs from GitHub, GitLab or Gitea", false, runFetchCommand},
+	{"scan", "scan [--json] [--quiet]", "Sync READMEs from local git repositories", false, runScanCommand},
+	{"resume", "resume [--format markdown|html|pdf|latex|jsonresume|text] [--theme name] [--latex-template name] [--latex-engine auto|none|cmd] [--readmes a,b|all] [--input file,...] [-o out.md] [--json]", "Generate a resume", true, runResumeCommand},
+	{"cover-letter", "cover-letter [--job job.txt] [--readmes a,b|all] [--input file,...] [-o out.md] [--json]", "Generate a cover letter", true, runCoverLetterCommand},
+	{"chat", "chat [--once \"question\"] [--json]", "Chat with your profile (reads questions from stdin without --once)", true, runChatCommand},
+	{"profile", "profile show | import file... | export [-o resume.json]", "Show, import into or export the profile as JSON Resume", false, runProfileCommand},
+	{"config", "config validate|show", "Check or print the effective configuration", false, runConfigCommand},
+}
+
+// runCLI dispatches a subcommand, writing its output to stdout and stderr,
+// and returns the process exit code
+func runCLI(cfg *Config, args []string, stdout, stderr io.Writer) int {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCLIUsage(stdout)
+		return exitOK
+	}
+
+	var command *cliCommand
+	for i := range cliCommands {
+		if cliCommands[i].name == args[0] {
+			command = &cliCommands[i]
+		}
+	}
+	if command == nil {
+		fmt.Fprintf(stderr, "amalgia: unknown command %q\n\n", args[0])
+		printCLIUsage(stderr)
+		return exitUsage
+	}
+
+	// The config command reports configuration problems itself
+	a := &app{Config: cfg}
+	if command.name != "config" {
+		var err error
+		a, err = loadApp(cfg, command.needLLM)
+		if err != nil {
+			fmt.Fprintf(stderr, "amalgia %s: %v\n", command.name, err)
+			return exitError
+		}
+	}
+	defer func() {
+		if err := activeCassette.Close(); err != nil {
+			fmt.Fprintf(stderr, "amalgia %s: %v\n", command.name, err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Printf("Running command %s.", command.name)
+	err := command.run(ctx, a, args[1:], stdout, stderr)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "amalgia %s: %v\nusage: amalgia %s\n", command.name, err, command.usage)
+		return exitUsage
+	case ctx.Err() != nil:
+		fmt.Fprintf(stderr, "amalgia %s: cancelled\n", command.name)
+		return exitCancelled
+	case errors.Is(err, errPartial):
+		fmt.Fprintf(stderr, "amalgia %s: %v\n", command.name, err)
+		return exitPartial
+	default:
+		logger.Printf("Command %s failed: %v", command.name, err)
+		fmt.Fprintf(stderr, "amalgia %s: %v\n", command.name, err)
+		return exitError
+	}
+}
+
+// printCLIUsage lists the available subcommands
+func printCLIUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: amalgia [command] [flags]")
+	fmt.Fprintln(w, "\nWithout a command the interactive TUI starts.\n\nCommands:")
+	for _, command := range cliCommands {
+		fmt.Fprintf(w, "  %-14s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintln(w, "\nRun 'amalgia <command> -h' for the flags of a command.")
+}
+
+// newFlagSet creates a flag set that reports errors instead of exiting
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseFlags wraps flag errors so they map to the usage exit code
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, fs.Args())
+	}
+	return nil
+}
+
+// writeJSON prints a machine-readable result
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// fetchResult is the JSON output of the fetch and scan commands
+type fetchResult struct {
+	Readmes       []string `json:"readmes"`
+	Fetched       int      `json:"fetched"`
+	Unchanged     int      `json:"unchanged"`
+	Failed        int      `json:"failed"`
+	FailedSources []string `json:"failed_sources,omitempty"`
+}
+
+func runFetchCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	return runSyncCommand(ctx, "fetch", args, stdout, stderr, func(send func(tea.Msg)) (FetchCompleteMsg, error) {
+		return fetchSources(ctx, a.Settings, send)
+	})
+}
+
+func runScanCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	return runSyncCommand(ctx, "scan", args, stdout, stderr, func(send func(tea.Msg)) (FetchCompleteMsg, error) {
+		return scanLocalREADMEs(ctx, a.Local, a.Paths.ReadmesDir, send)
+	})
+}
+
+// runSyncCommand runs a README sync, reporting progress to stderr and the
+// synced READMEs to stdout
+func runSyncCommand(ctx context.Context, name string, args []string, stdout, stderr io.Writer, sync func(send func(tea.Msg)) (FetchCompleteMsg, error)) error {
+	fs := newFlagSet(name, stderr)
+	jsonOutput := fs.Bool("json", false, "print the result as JSON")
+	quiet := fs.Bool("quiet", false, "do not print progress to stderr")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	total, processed := 0, 0
+	send := func(msg tea.Msg) {
+		switch msg := msg.(type) {
+		case LogMsg:
+			logger.Println(string(msg))
+		case FetchStartedMsg:
+			total = msg.Total
+		case ReadmeFetchedMsg:
+			processed++
+			if *quiet {
+				return
+			}
+			status := msg.Outcome
+			if msg.Err != nil {
+				status = "error: " + msg.Err.Error()
+			}
+			fmt.Fprintf(stderr, "[%d/%d] %s %s\n", processed, total, msg.Name, status)
+		case RateLimitMsg:
+			if !*quiet && !msg.WaitUntil.IsZero() {
+				fmt.Fprintln(stderr, msg.String())
+			}
+		}
+	}
+
+	result, err := sync(send)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		if err := writeJSON(stdout, fetchResult{
+			Readmes:       result.Names,
+			Fetched:       result.Fetched,
+			Unchanged:     result.Unchanged,
+			Failed:        result.Failed,
+			FailedSources: result.FailedSources,
+		}); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range result.Names {
+			fmt.Fprintln(stdout, name)
+		}
+	}
+
+	if len(result.FailedSources) > 0 {
+		return fmt.Errorf("%w: could not fetch from %s", errPartial, strings.Join(result.FailedSources, ", "))
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%w: %d README(s) could not be synced", errPartial, result.Failed)
+	}
+	return nil
+}
+
+// documentFlags are shared by the resume and cover-letter commands
+type documentFlags struct {
+	readmes    string
+	input      string
+	output     string
+	jsonOutput bool
+}
+
+func (f *documentFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.readmes, "readmes", "", "comma-separated README names from the last fetch or scan to add to the profile, or 'all'")
+	fs.StringVar(&f.input, "input", "", "comma-separated files to add to the profile, such as an existing resume")
+	fs.StringVar(&f.output, "o", "", "write the document to this file instead of stdout")
+	fs.BoolVar(&f.jsonOutput, "json", false, "print the result as JSON")
+}
+
+// profile loads the saved profile and adds the files and READMEs named by the
+// flags for this run only
+func (f *documentFlags) profile(a *app) (*Profile, error) {
+	profile, err := loadProfile(a.Paths.ProfileFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading profile: %v", err)
+	}
+
+	for _, file := range splitList(f.input) {
+		if err := profile.importFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	cached, err := loadCachedREADMEs(a.Paths.ReadmesDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading cached READMEs: %v", err)
+	}
+	if f.readmes != "" {
+		selected := cached.Names
+		if f.readmes != "all" {
+			selected = splitList(f.readmes)
+			sort.Strings(selected)
+		}
+		for _, name := range selected {
+			content, ok := cached.Readmes[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: README %q not found; run 'amalgia fetch' or 'amalgia scan' first", errUsage, name)
+			}
+			profile.addReadme(name, cached.ReadmeSources[name], content)
+		}
+	}
+
+	// Use the activity of the last fetch and scan, which the profile may predate
+	history, err := loadContributions(a.Paths.ReadmesDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading contribution history: %v", err)
+	}
+	if history != nil {
+		profile.setContributions(history)
+	}
+	profile.setLocalContributions(cached.Metadata)
+	profile.setStack(cached.Metadata)
+	return profile, nil
+}
+
+// documentResult is the JSON output of the document commands
+type documentResult struct {
+	Output  string `json:"output,omitempty"`
+	Content string `json:"content"`
+}
+
+// writeDocument saves or prints a generated document. Binary documents, which
+// callers only accept with -o, are left out of the JSON result.
+func (f *documentFlags) writeDocument(stdout, stderr io.Writer, content []byte, binary bool) error {
+	if f.output != "" {
+		if err := writeFileAtomic(f.output, content); err != nil {
+			return fmt.Errorf("saving %s: %v", f.output, err)
+		}
+		logger.Printf("Saved generated document to %s.", f.output)
+	}
+
+	switch {
+	case f.jsonOutput:
+		result := documentResult{Output: f.output}
+		if !binary {
+			result.Content = string(content)
+		}
+		return writeJSON(stdout, result)
+	case f.output != "":
+		fmt.Fprintf(stderr, "Saved to %s\n", f.output)
+		return nil
+	default:
+		_, err := fmt.Fprintln(stdout, strings.TrimRight(string(content), "\n"))
+		return err
+	}
+}
+
+func runResumeCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("resume", stderr)
+	var flags documentFlags
+	flags.register(fs)
+	resumeConfig := a.Resume
+	fs.StringVar(&resumeConfig.Format, "format", resumeConfig.Format, "output format: "+strings.Join(resumeFormatNames(), ", "))
+	fs.StringVar(&resumeConfig.Theme, "theme", resumeConfig.Theme, "theme of HTML and PDF output: "+strings.Join(resumeThemeNames(), ", "))
+	fs.StringVar(&resumeConfig.LaTeXTemplate, "latex-template", resumeConfig.LaTeXTemplate, "template of LaTeX output: "+strings.Join(latexTemplateNames(), ", "))
+	fs.StringVar(&resumeConfig.LaTeXEngine, "latex-engine", resumeConfig.LaTeXEngine, "TeX engine compiling LaTeX output saved with -o: auto, none or a command")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := resumeConfig.validate(); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	binary := resumeConfig.Format == formatPDF
+	if binary && flags.output == "" {
+		return fmt.Errorf("%w: the %s format needs -o", errUsage, formatPDF)
+	}
+
+	profile, err := flags.profile(a)
+	if err != nil {
+		return err
+	}
+	resume, err := composeResumeDocument(ctx, a.llm, a.LLM, resumeConfig, a.Prompts.Resume, resumeConfig.Format, profile.jsonResume(), profile.render())
+	if err != nil {
+		return fmt.Errorf("generating resume: %v", err)
+	}
+	if err := flags.writeDocument(stdout, stderr, resume, binary); err != nil {
+		return err
+	}
+	if resumeConfig.Format != formatLaTeX || flags.output == "" {
+		return nil
+	}
+	pdfFile, err := compileLaTeX(ctx, resumeConfig.LaTeXEngine, flags.output)
+	if err != nil {
+		return fmt.Errorf("%w: %s was saved but not compiled: %v", errPartial, flags.output, err)
+	}
+	if pdfFile != "" {
+		logger.Printf("Compiled %s to %s.", flags.output, pdfFile)
+		if !flags.jsonOutput {
+			fmt.Fprintf(stderr, "Compiled to %s\n", pdfFile)
+		}
+	}
+	return nil
+}
+
+func runCoverLetterCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("cover-letter", stderr)
+	var flags documentFlags
+	flags.register(fs)
+	jobFile := fs.String("job", "", "file containing the job description to tailor the letter to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var job string
+	if *jobFile != "" {
+		data, err := os.ReadFile(*jobFile)
+		if err != nil {
+			return fmt.Errorf("reading job description: %v", err)
+		}
+		job = string(data)
+	}
+
+	profile, err := flags.profile(a)
+	if err != nil {
+		return err
+	}
+	coverLetter, err := composeCoverLetter(ctx, a.llm, a.LLM, a.Prompts.CoverLetter, profile.render(), job)
+	if err != nil {
+		return fmt.Errorf("generating cover letter: %v", err)
+	}
+	return flags.writeDocument(stdout, stderr, []byte(coverLetter), false)
+}
+
+// chatResult is the JSON output of the chat command
+type chatResult struct {
+	Question string `json:"question"`
+	Reply    string `json:"reply"`
+}
+
+func runChatCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("chat", stderr)
+	once := fs.String("once", "", "ask a single question and exit")
+	jsonOutput := fs.Bool("json", false, "print replies as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	profile, err := loadProfile(a.Paths.ProfileFile)
+	if err != nil {
+		return fmt.Errorf("loading profile: %v", err)
+	}
+	profileData := profile.render()
+
+	ask := func(question string) error {
+		reply, err := composeChatReply(ctx, a.llm, a.LLM, a.Prompts.Chat, profileData, question)
+		if err != nil {
+			return fmt.Errorf("chat: %v", err)
+		}
+		if *jsonOutput {
+			return json.NewEncoder(stdout).Encode(chatResult{Question: question, Reply: reply})
+		}
+		_, err = fmt.Fprintln(stdout, reply)
+		return err
+	}
+
+	if *once != "" {
+		return ask(*once)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		question := strings.TrimSpace(scanner.Text())
+		if question == "" {
+			continue
+		}
+		if err := ask(question); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func runProfileCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: expected 'show', 'import' or 'export'", errUsage)
+	}
+	profile, err := loadProfile(a.Paths.ProfileFile)
+	if err != nil {
+		return fmt.Errorf("loading profile: %v", err)
+	}
+
+	switch args[0] {
+	case "show":
+		return writeJSON(stdout, profile)
+
+	case "import":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: expected files to import", errUsage)
+		}
+		for _, file := range args[1:] {
+			if err := profile.importFile(file); err != nil {
+				return err
+			}
+			fmt.Fprintf(stderr, "Imported %s\n", file)
+		}
+		if err := profile.save(a.Paths.ProfileFile); err != nil {
+			return fmt.Errorf("saving profile: %v", err)
+		}
+		logger.Printf("Imported %v into %s.", args[1:], a.Paths.ProfileFile)
+		return nil
+
+	case "export":
+		fs := newFlagSet("profile export", stderr)
+		output := fs.String("o", "", "write the JSON Resume to this file instead of stdout")
+		if err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		}
+		if *output == "" {
+			return writeJSON(stdout, profile.jsonResume())
+		}
+		if err := profile.exportJSONResume(*output); err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "Saved to %s\n", *output)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unknown profile command %q", errUsage, args[0])
+	}
+}
+
+func runConfigCommand(ctx context.Context, a *app, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected 'validate' or 'show'", errUsage)
+	}
+
+	switch args[0] {
+	case "validate":
+		if len(a.Files) == 0 {
+			fmt.Fprintln(stdout, "No config file found; using defaults.")
+		}
+		for _, file := range a.Files {
+			fmt.Fprintf(stdout, "Read %s\n", file)
+		}
+		if a.Env != "" {
+			fmt.Fprintf(stdout, "Env: %s\n", a.Env)
+		}
+		if err := a.validate(); err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(stdout, "  - %s\n", line)
+			}
+			return fmt.Errorf("configuration is invalid")
+		}
+		fmt.Fprintln(stdout, "Configuration is valid.")
+		return nil
+
+	case "show":
+		encoder := yaml.NewEncoder(stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(a.Settings); err != nil {
+			return err
+		}
+		return encoder.Close()
+
+	default:
+		return fmt.Errorf("%w: unknown config command %q", errUsage, args[0])
+	}
+}
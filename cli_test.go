@@ -0,0 +1,93 @@
+// Filename: cli_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runTestCLI runs a subcommand against a configuration in a temporary
+// directory that uses the fake provider
+func runTestCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestConfig(t, `
+llm:
+  provider: fake
+paths:
+  readmes_dir: `+filepath.Join(dir, "readmes")+`
+  profile_file: `+filepath.Join(dir, "profile.json")+`
+`)
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := runCLI(cfg, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunCLIUsageErrors(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantStderr string
+	}{
+		{[]string{"frobnicate"}, `unknown command "frobnicate"`},
+		{[]string{"resume", "--colour"}, "flag provided but not defined: -colour"},
+		{[]string{"resume", "extra"}, "unexpected arguments [extra]"},
+		{[]string{"resume", "--format", "docx"}, "usage: amalgia resume"},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := runTestCLI(t, tt.args...)
+		if code != exitUsage {
+			t.Errorf("%v: exit code %d, want %d", tt.args, code, exitUsage)
+		}
+		if stdout != "" || !strings.Contains(stderr, tt.wantStderr) {
+			t.Errorf("%v: stdout %q, stderr %q, want %q on stderr", tt.args, stdout, stderr, tt.wantStderr)
+		}
+	}
+
+	if code, stdout, _ := runTestCLI(t, "help"); code != exitOK || !strings.Contains(stdout, "Usage: amalgia") {
+		t.Errorf("help: exit code %d with %q", code, stdout)
+	}
+}
+
+func TestRunCLIResume(t *testing.T) {
+	code, stdout, stderr := runTestCLI(t, "resume")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "# ") || !strings.Contains(stdout, "[fake response ") {
+		t.Errorf("got %q, want the fake provider's resume as Markdown", stdout)
+	}
+}
+
+func TestRunCLIJSONOutput(t *testing.T) {
+	code, stdout, stderr := runTestCLI(t, "resume", "--format", "jsonresume", "--json")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout)
+	}
+	content, ok := result["content"].(string)
+	if len(result) != 1 || !ok {
+		t.Fatalf("got %v, want only the content without -o", result)
+	}
+	if _, isResume, err := parseJSONResume([]byte(content)); !isResume || err != nil {
+		t.Errorf("content is not a JSON Resume (%v): %s", err, content)
+	}
+
+	code, stdout, stderr = runTestCLI(t, "chat", "--once", "What do I do?", "--json")
+	if code != exitOK {
+		t.Fatalf("chat: exit code %d: %s", code, stderr)
+	}
+	var reply chatResult
+	if err := json.Unmarshal([]byte(stdout), &reply); err != nil || reply.Question != "What do I do?" || reply.Reply == "" {
+		t.Errorf("chat: got %+v (%v) from %q", reply, err, stdout)
+	}
+}
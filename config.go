@@ -0,0 +1,328 @@
+// Filename: config.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigFiles are looked up in the working directory, highest precedence last
+var projectConfigFiles = []string{".amalgia.yaml", "amalgia.yaml"}
+
+// PathsConfig holds the files and directories Amalgia reads and writes
+type PathsConfig struct {
+	ReadmesDir        string `yaml:"readmes_dir"`
+	ProfileFile       string `yaml:"profile_file"`
+	LogFile           string `yaml:"log_file"`
+	ResumeOutput      string `yaml:"resume_output"`
+	CoverLetterOutput string `yaml:"cover_letter_output"`
+	JSONResumeOutput  string `yaml:"json_resume_output"`
+}
+
+// PromptsConfig holds the system prompts of the AI actions
+type PromptsConfig struct {
+	Resume      string `yaml:"resume"`
+	CoverLetter string `yaml:"cover_letter"`
+	Chat        string `yaml:"chat"`
+}
+
+// CassetteConfig selects HTTP record/replay
+type CassetteConfig struct {
+	Mode string `yaml:"mode"` // Empty, record or replay
+	Path string `yaml:"path"`
+}
+
+// Settings is the effective configuration after files, env and environment variables are applied
+type Settings struct {
+	LLM      LLMConfig      `yaml:"llm"`
+	Sources  []string       `yaml:"sources"` // Services READMEs are fetched from
+	GitHub   GitHubConfig   `yaml:"github"`
+	GitLab   ForgeConfig    `yaml:"gitlab"`
+	Gitea    ForgeConfig    `yaml:"gitea"`
+	Local    LocalConfig    `yaml:"local"`
+	Resume   ResumeConfig   `yaml:"resume"`
+	Paths    PathsConfig    `yaml:"paths"`
+	Prompts  PromptsConfig  `yaml:"prompts"`
+	Cassette CassetteConfig `yaml:"cassette"`
+}
+
+// Config is the loaded configuration together with where it came from
+type Config struct {
+	Settings
+	Env   string   // Selected environment, empty for the base settings
+	Files []string // Config files that were read, lowest precedence first
+}
+
+// configFile is the on-disk format: base settings plus named environments
+// that override them. profile and profiles are accepted as aliases of env
+// and envs.
+type configFile struct {
+	Settings `yaml:",inline"`
+	Env      string               `yaml:"env"`
+	Envs     map[string]yaml.Node `yaml:"envs"`
+	Profile  string               `yaml:"profile"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// mergeAliases folds profile and profiles into env and envs
+func (f *configFile) mergeAliases() error {
+	if f.Env != "" && f.Profile != "" && f.Env != f.Profile {
+		return fmt.Errorf("env %q and profile %q select different environments", f.Env, f.Profile)
+	}
+	if f.Env == "" {
+		f.Env = f.Profile
+	}
+	for name, node := range f.Profiles {
+		if _, ok := f.Envs[name]; ok {
+			return fmt.Errorf("env %q is defined under both envs and profiles", name)
+		}
+		if f.Envs == nil {
+			f.Envs = make(map[string]yaml.Node)
+		}
+		f.Envs[name] = node
+	}
+	return nil
+}
+
+// envFromEnvironment returns the env selected by AMALGIA_ENV or its alias AMALGIA_PROFILE
+func envFromEnvironment() (string, error) {
+	env, profile := os.Getenv("AMALGIA_ENV"), os.Getenv("AMALGIA_PROFILE")
+	if env != "" && profile != "" && env != profile {
+		return "", fmt.Errorf("AMALGIA_ENV %q and AMALGIA_PROFILE %q select different environments", env, profile)
+	}
+	if env == "" {
+		return profile, nil
+	}
+	return env, nil
+}
+
+// defaultSettings returns the built-in configuration
+func defaultSettings() Settings {
+	return Settings{
+		LLM:     defaultLLMConfig(),
+		Sources: []string{sourceGitHub},
+		GitHub:  defaultGitHubConfig(),
+		GitLab:  defaultForgeConfig("https://gitlab.com", "GITLAB_TOKEN"),
+		Gitea:   defaultForgeConfig("", "GITEA_TOKEN"),
+		Local:   defaultLocalConfig(),
+		Resume:  defaultResumeConfig(),
+		Paths: PathsConfig{
+			ReadmesDir:        defaultReadmesDir,
+			ProfileFile:       defaultProfileFile,
+			LogFile:           "app.log",
+			ResumeOutput:      resumeOutputFile,
+			CoverLetterOutput: coverLetterOutputFile,
+			JSONResumeOutput:  jsonResumeOutputFile,
+		},
+		Prompts: PromptsConfig{
+			Resume:      resumeSystemPrompt,
+			CoverLetter: coverLetterSystemPrompt,
+			Chat:        chatSystemPrompt,
+		},
+		Cassette: CassetteConfig{
+			Path: filepath.Join("cassettes", "session.json"),
+		},
+	}
+}
+
+// configFilePaths lists the config files to read, lowest precedence first.
+// AMALGIA_CONFIG replaces the XDG and per-project lookup.
+func configFilePaths() []string {
+	if path := os.Getenv("AMALGIA_CONFIG"); path != "" {
+		return []string{path}
+	}
+
+	var paths []string
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "amalgia", "config.yaml"))
+	}
+	return append(paths, projectConfigFiles...)
+}
+
+// loadConfig merges the defaults, config files, selected env and environment variables
+func loadConfig() (*Config, error) {
+	cfg := &Config{Settings: defaultSettings()}
+
+	var files []configFile
+	for _, path := range configFilePaths() {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && os.Getenv("AMALGIA_CONFIG") == "" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading config: %v", err)
+		}
+
+		// Decode over the merged settings so files only override what they set
+		file := configFile{Settings: cfg.Settings}
+		if err := decodeStrict(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", path, err)
+		}
+		if err := file.mergeAliases(); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", path, err)
+		}
+		cfg.Settings = file.Settings
+		if file.Env != "" {
+			cfg.Env = file.Env
+		}
+		files = append(files, file)
+		cfg.Files = append(cfg.Files, path)
+	}
+
+	env, err := envFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	if env != "" {
+		cfg.Env = env
+	}
+	if cfg.Env != "" {
+		found := false
+		for i, file := range files {
+			node, ok := file.Envs[cfg.Env]
+			if !ok {
+				continue
+			}
+			found = true
+			// Node.Decode ignores unknown keys, so decode the environment's text
+			data, err := yaml.Marshal(&node)
+			if err == nil {
+				err = decodeStrict(data, &cfg.Settings)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parsing env %q in %s: %v", cfg.Env, cfg.Files[i], err)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("env %q is not defined in any config file", cfg.Env)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeStrict decodes a YAML document into v, rejecting keys v has no field for
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv applies the AMALGIA_* environment overrides
+func (s *Settings) applyEnv() error {
+	if err := s.LLM.applyEnv(); err != nil {
+		return err
+	}
+	if err := s.GitHub.applyEnv(); err != nil {
+		return err
+	}
+	if err := s.GitLab.applyEnv("GITLAB"); err != nil {
+		return err
+	}
+	if err := s.Gitea.applyEnv("GITEA"); err != nil {
+		return err
+	}
+	if err := s.Local.applyEnv(); err != nil {
+		return err
+	}
+	s.Resume.applyEnv()
+	if value := os.Getenv("AMALGIA_SOURCES"); value != "" {
+		s.Sources = splitList(value)
+	}
+
+	stringVars := map[string]*string{
+		"AMALGIA_READMES_DIR":         &s.Paths.ReadmesDir,
+		"AMALGIA_PROFILE_FILE":        &s.Paths.ProfileFile,
+		"AMALGIA_LOG_FILE":            &s.Paths.LogFile,
+		"AMALGIA_RESUME_OUTPUT":       &s.Paths.ResumeOutput,
+		"AMALGIA_COVER_LETTER_OUTPUT": &s.Paths.CoverLetterOutput,
+		"AMALGIA_JSON_RESUME_OUTPUT":  &s.Paths.JSONResumeOutput,
+		"AMALGIA_CASSETTE_MODE":       &s.Cassette.Mode,
+		"AMALGIA_CASSETTE":            &s.Cassette.Path,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	return nil
+}
+
+// validate reports every problem in the settings at once
+func (s Settings) validate() error {
+	var errs []error
+	if err := s.LLM.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("llm: %v", err))
+	}
+	if err := s.GitHub.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("github: %v", err))
+	}
+	if len(s.Sources) == 0 {
+		errs = append(errs, fmt.Errorf("sources must list at least one of %s, %s or %s", sourceGitHub, sourceGitLab, sourceGitea))
+	}
+	for _, source := range s.Sources {
+		var err error
+		switch source {
+		case sourceGitHub:
+		case sourceGitLab:
+			err = s.GitLab.validate()
+		case sourceGitea:
+			err = s.Gitea.validate()
+		default:
+			errs = append(errs, fmt.Errorf("invalid source %q (expected %s, %s or %s)", source, sourceGitHub, sourceGitLab, sourceGitea))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", source, err))
+		}
+	}
+	if err := s.Local.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("local: %v", err))
+	}
+	if err := s.Resume.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("resume: %v", err))
+	}
+
+	required := []struct{ key, value string }{
+		{"paths.readmes_dir", s.Paths.ReadmesDir},
+		{"paths.profile_file", s.Paths.ProfileFile},
+		{"paths.log_file", s.Paths.LogFile},
+		{"paths.resume_output", s.Paths.ResumeOutput},
+		{"paths.cover_letter_output", s.Paths.CoverLetterOutput},
+		{"paths.json_resume_output", s.Paths.JSONResumeOutput},
+		{"prompts.resume", s.Prompts.Resume},
+		{"prompts.cover_letter", s.Prompts.CoverLetter},
+		{"prompts.chat", s.Prompts.Chat},
+	}
+	for _, setting := range required {
+		if setting.value == "" {
+			errs = append(errs, fmt.Errorf("%s must not be empty", setting.key))
+		}
+	}
+
+	switch s.Cassette.Mode {
+	case "", cassetteRecord, cassetteReplay:
+	default:
+		errs = append(errs, fmt.Errorf("cassette: unknown mode %q", s.Cassette.Mode))
+	}
+
+	return errors.Join(errs...)
+}
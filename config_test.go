@@ -0,0 +1,103 @@
+// Filename: config_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestConfig makes path the only config file read by loadConfig
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AMALGIA_CONFIG", path)
+	return path
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	writeTestConfig(t, `
+llm:
+  model: gpt-4
+envs:
+  offline:
+    llm:
+      provider: fake
+`)
+	t.Setenv("AMALGIA_ENV", "offline")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Provider != providerFake || cfg.LLM.Model != "gpt-4" {
+		t.Errorf("llm = %+v, want the fake provider with the base model", cfg.LLM)
+	}
+}
+
+func TestLoadConfigProfilesAlias(t *testing.T) {
+	tests := []struct {
+		name, content, envVar string
+	}{
+		{"profiles selected by AMALGIA_ENV", "profiles:\n  offline:\n    llm:\n      provider: fake\n", "AMALGIA_ENV"},
+		{"envs selected by AMALGIA_PROFILE", "envs:\n  offline:\n    llm:\n      provider: fake\n", "AMALGIA_PROFILE"},
+		{"profiles selected by AMALGIA_PROFILE", "profiles:\n  offline:\n    llm:\n      provider: fake\n", "AMALGIA_PROFILE"},
+		{"profile key", "profile: offline\nprofiles:\n  offline:\n    llm:\n      provider: fake\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestConfig(t, tt.content)
+			if tt.envVar != "" {
+				t.Setenv(tt.envVar, "offline")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Env != "offline" || cfg.LLM.Provider != providerFake {
+				t.Errorf("env %q with provider %s, want offline with the fake provider", cfg.Env, cfg.LLM.Provider)
+			}
+		})
+	}
+
+	conflicts := []struct {
+		name, content string
+		env           map[string]string
+		want          string
+	}{
+		{"both keys", "envs:\n  offline: {}\nprofiles:\n  offline: {}\n", nil, "defined under both envs and profiles"},
+		{"both selectors", "env: work\nprofile: offline\n", nil, "select different environments"},
+		{"both variables", "envs:\n  offline: {}\n  work: {}\n", map[string]string{"AMALGIA_ENV": "work", "AMALGIA_PROFILE": "offline"}, "select different environments"},
+	}
+	for _, tt := range conflicts {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestConfig(t, tt.content)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	for name, test := range map[string]struct{ content, key string }{
+		"top level": {"llm:\n  modle: gpt-4\n", "modle"},
+		"env":       {"envs:\n  offline:\n    llm:\n      modle: gpt-4\n", "modle"},
+		"profile":   {"profiles:\n  offline:\n    llm:\n      modle: gpt-4\n", "modle"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			writeTestConfig(t, test.content)
+			t.Setenv("AMALGIA_ENV", "offline")
+			_, err := loadConfig()
+			if err == nil || !strings.Contains(err.Error(), test.key) {
+				t.Errorf("unknown key %s was accepted: %v", test.key, err)
+			}
+		})
+	}
+}
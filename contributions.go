@@ -0,0 +1,279 @@
+// Filename: contributions.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// contributionsFile caches the mined contribution history next to the READMEs
+const contributionsFile = ".contributions.json"
+
+// maxSearchPages is as deep as the GitHub search API pages (1000 results)
+const maxSearchPages = 10
+
+// RepoContributions is what the user did in one repository
+type RepoContributions struct {
+	Repo         string `json:"repo"` // owner/name, or the path of a local repository
+	Key          string `json:"key"`  // README name of the repository
+	External     bool   `json:"external,omitempty"`
+	Local        bool   `json:"local,omitempty"` // Read from a local clone rather than GitHub
+	Commits      int    `json:"commits,omitempty"`
+	PullRequests int    `json:"pull_requests,omitempty"` // Merged pull requests authored
+	Reviews      int    `json:"reviews,omitempty"`       // Pull requests of others reviewed
+	// Counts that are lower bounds because a search hit the 1000 result limit
+	CommitsAtLeast      bool      `json:"commits_at_least,omitempty"`
+	PullRequestsAtLeast bool      `json:"pull_requests_at_least,omitempty"`
+	ReviewsAtLeast      bool      `json:"reviews_at_least,omitempty"`
+	FirstActivity       time.Time `json:"first_activity"`
+	LastActivity        time.Time `json:"last_activity"`
+}
+
+// ContributionHistory is the user's activity across all repositories
+type ContributionHistory struct {
+	Login     string               `json:"login"`
+	Repos     []*RepoContributions `json:"repos"`
+	Truncated bool                 `json:"truncated,omitempty"` // A search hit the 1000 result limit
+	MinedAt   time.Time            `json:"mined_at"`
+}
+
+// loadContributions reads the cached history in dir, returning nil if none exists
+func loadContributions(dir string) (*ContributionHistory, error) {
+	data, err := os.ReadFile(filepath.Join(dir, contributionsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	history := &ContributionHistory{}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", contributionsFile, err)
+	}
+	return history, nil
+}
+
+// save writes the history to dir
+func (h *ContributionHistory) save(dir string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, contributionsFile), data)
+}
+
+// mineContributions searches GitHub for the commits, merged pull requests and
+// reviews of login, across the user's own and external repositories. The
+// search API stops at 1000 results, so when the commit search is cut short the
+// commits in owned, the user's repositories as owner/name, are counted from
+// their contributor statistics instead; the other counts are marked as lower
+// bounds.
+func mineContributions(ctx context.Context, client *github.Client, retrier *githubRetrier, login string, owned []string) (*ContributionHistory, error) {
+	history := &ContributionHistory{Login: login, MinedAt: time.Now()}
+	repos := make(map[string]*RepoContributions)
+	repoFor := func(fullName string) *RepoContributions {
+		repo := repos[fullName]
+		if repo == nil {
+			owner, name, _ := strings.Cut(fullName, "/")
+			repo = &RepoContributions{Repo: fullName, Key: name, External: !strings.EqualFold(owner, login)}
+			if repo.External {
+				repo.Key = fullName
+			}
+			repos[fullName] = repo
+		}
+		return repo
+	}
+	record := func(fullName string, at time.Time, count func(*RepoContributions)) {
+		if fullName == "" {
+			return
+		}
+		repo := repoFor(fullName)
+		count(repo)
+		repo.extendActivity(at)
+	}
+	// truncated marks the counts of every repository as lower bounds
+	truncated := func(mark func(*RepoContributions)) {
+		history.Truncated = true
+		for _, repo := range repos {
+			mark(repo)
+		}
+	}
+
+	opts := &github.SearchOptions{Sort: "author-date", Order: "desc", ListOptions: github.ListOptions{PerPage: 100}}
+	for page := 1; page <= maxSearchPages; page++ {
+		opts.Page = page
+		var result *github.CommitsSearchResult
+		resp, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+			result, resp, err = client.Search.Commits(ctx, "author:"+login, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("searching commits: %v", err)
+		}
+		for _, c := range result.Commits {
+			record(c.GetRepository().GetFullName(), c.GetCommit().GetAuthor().GetDate(), func(r *RepoContributions) { r.Commits++ })
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		if page == maxSearchPages {
+			truncated(func(r *RepoContributions) { r.CommitsAtLeast = true })
+			for _, fullName := range owned {
+				countOwnedCommits(ctx, client, retrier, login, repoFor(fullName))
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+
+	searches := []struct {
+		query string
+		count func(*RepoContributions)
+		mark  func(*RepoContributions)
+	}{
+		{"is:pr is:merged author:" + login, func(r *RepoContributions) { r.PullRequests++ }, func(r *RepoContributions) { r.PullRequestsAtLeast = true }},
+		{"is:pr reviewed-by:" + login + " -author:" + login, func(r *RepoContributions) { r.Reviews++ }, func(r *RepoContributions) { r.ReviewsAtLeast = true }},
+	}
+	for _, search := range searches {
+		opts := &github.SearchOptions{Sort: "created", Order: "desc", ListOptions: github.ListOptions{PerPage: 100}}
+		for page := 1; page <= maxSearchPages; page++ {
+			opts.Page = page
+			var result *github.IssuesSearchResult
+			resp, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+				result, resp, err = client.Search.Issues(ctx, search.query, opts)
+				return resp, err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("searching pull requests: %v", err)
+			}
+			for _, issue := range result.Issues {
+				at := issue.GetClosedAt()
+				if at.IsZero() {
+					at = issue.GetCreatedAt()
+				}
+				record(repoFromAPIURL(issue.GetRepositoryURL()), at, search.count)
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			if page == maxSearchPages {
+				truncated(search.mark)
+			}
+		}
+	}
+
+	for _, repo := range repos {
+		if repo.Commits > 0 || repo.PullRequests > 0 || repo.Reviews > 0 {
+			history.Repos = append(history.Repos, repo)
+		}
+	}
+	sort.Slice(history.Repos, func(i, j int) bool {
+		return history.Repos[i].Repo < history.Repos[j].Repo
+	})
+	return history, nil
+}
+
+// extendActivity widens the activity period of the repository to include at
+func (r *RepoContributions) extendActivity(at time.Time) {
+	if !at.IsZero() && (r.FirstActivity.IsZero() || at.Before(r.FirstActivity)) {
+		r.FirstActivity = at
+	}
+	if at.After(r.LastActivity) {
+		r.LastActivity = at
+	}
+}
+
+// countOwnedCommits sets the commits of login in repo from GitHub's
+// contributor statistics, which count every commit on the default branch.
+// The searched count is kept while GitHub is still computing them, or when
+// they cannot be read. A 202 is not waited for: computing the statistics of
+// a large repository can take minutes, and they are ready on the next fetch.
+func countOwnedCommits(ctx context.Context, client *github.Client, retrier *githubRetrier, login string, repo *RepoContributions) {
+	owner, name, _ := strings.Cut(repo.Repo, "/")
+	var stats []*github.ContributorStats
+	_, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+		stats, resp, err = client.Repositories.ListContributorsStats(ctx, owner, name)
+		var acceptedErr *github.AcceptedError
+		if errors.As(err, &acceptedErr) {
+			return resp, nil
+		}
+		return resp, err
+	})
+	if err != nil {
+		return
+	}
+	for _, contributor := range stats {
+		if !strings.EqualFold(contributor.GetAuthor().GetLogin(), login) {
+			continue
+		}
+		repo.Commits = contributor.GetTotal()
+		repo.CommitsAtLeast = false
+		for _, week := range contributor.Weeks {
+			if week.GetCommits() > 0 {
+				repo.extendActivity(week.GetWeek().Time)
+			}
+		}
+	}
+}
+
+// repoFromAPIURL turns "https://api.github.com/repos/owner/name" into "owner/name"
+func repoFromAPIURL(url string) string {
+	_, rest, found := strings.Cut(url, "/repos/")
+	if !found {
+		return ""
+	}
+	return rest
+}
+
+// summary describes the activity as a sentence for the resume prompt
+func (r *RepoContributions) summary() string {
+	var facts []string
+	if r.Commits > 0 {
+		facts = append(facts, fmt.Sprintf("Authored %s commit%s", atLeast(r.Commits, r.CommitsAtLeast), plural(r.Commits)))
+	}
+	if r.PullRequests > 0 {
+		facts = append(facts, fmt.Sprintf("Got %s pull request%s merged", atLeast(r.PullRequests, r.PullRequestsAtLeast), plural(r.PullRequests)))
+	}
+	if r.Reviews > 0 {
+		facts = append(facts, fmt.Sprintf("Reviewed %s pull request%s by others", atLeast(r.Reviews, r.ReviewsAtLeast), plural(r.Reviews)))
+	}
+	if len(facts) == 0 {
+		return ""
+	}
+	summary := strings.Join(facts, "; ")
+	if !r.FirstActivity.IsZero() {
+		first, last := r.FirstActivity.Format("Jan 2006"), r.LastActivity.Format("Jan 2006")
+		if first == last {
+			summary += " in " + first
+		} else {
+			summary += " between " + first + " and " + last
+		}
+	}
+	return summary
+}
+
+// atLeast formats a count, as a lower bound when the search for it was cut short
+func atLeast(n int, lowerBound bool) string {
+	if lowerBound {
+		return fmt.Sprintf("at least %d", n)
+	}
+	return fmt.Sprint(n)
+}
+
+// plural returns "s" unless n is one
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,107 @@
+// Filename: contributions_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestMineContributionsPastSearchLimit(t *testing.T) {
+	// Every search has more pages than the API returns
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body interface{}
+		switch r.URL.Path {
+		case "/api/v3/search/commits":
+			body = map[string]interface{}{"items": []map[string]interface{}{
+				{"repository": map[string]string{"full_name": "octo/demo"}, "commit": map[string]interface{}{"author": map[string]string{"date": "2024-05-01T10:00:00Z"}}},
+				{"repository": map[string]string{"full_name": "other/lib"}, "commit": map[string]interface{}{"author": map[string]string{"date": "2024-05-01T10:00:00Z"}}},
+			}}
+		case "/api/v3/search/issues":
+			body = map[string]interface{}{"items": []map[string]interface{}{
+				{"repository_url": server.URL + "/api/v3/repos/other/lib", "created_at": "2024-05-01T10:00:00Z"},
+			}}
+		case "/api/v3/repos/octo/demo/stats/contributors":
+			body = []map[string]interface{}{
+				{"author": map[string]string{"login": "someone"}, "total": 7},
+				{"author": map[string]string{"login": "Octo"}, "total": 2500, "weeks": []map[string]int{{"w": 1262304000, "c": 3}, {"w": 1263513600, "c": 0}}},
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/v3/search/") {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next"`, server.URL, r.URL.Path, maxSearchPages+1))
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultGitHubConfig()
+	cfg.MaxRetries = 0
+	history, err := mineContributions(context.Background(), client, newGitHubRetrier(cfg, func(RateLimitMsg) {}), "octo", []string{"octo/demo"})
+	if err != nil {
+		t.Fatalf("mining contributions: %v", err)
+	}
+	if !history.Truncated || len(history.Repos) != 2 {
+		t.Fatalf("got truncated %v with %d repositories, want the two searched ones", history.Truncated, len(history.Repos))
+	}
+
+	// The owned repository is counted from its contributor statistics
+	demo := history.Repos[0]
+	if demo.Repo != "octo/demo" || demo.Commits != 2500 || demo.CommitsAtLeast {
+		t.Errorf("got %+v, want exactly 2500 commits in octo/demo", demo)
+	}
+	if got := demo.FirstActivity.Year(); got != 2010 {
+		t.Errorf("first activity in %d, want the first week with commits in 2010", got)
+	}
+
+	// The external one only has the searched counts, as lower bounds
+	lib := history.Repos[1]
+	if lib.Commits != maxSearchPages || !lib.CommitsAtLeast || !lib.PullRequestsAtLeast || !lib.ReviewsAtLeast {
+		t.Errorf("got %+v, want every count of other/lib marked as a lower bound", lib)
+	}
+	want := "Authored at least 10 commits; Got at least 10 pull requests merged; Reviewed at least 10 pull requests by others"
+	if got := lib.summary(); !strings.HasPrefix(got, want) {
+		t.Errorf("summary %q, want it to start with %q", got, want)
+	}
+	if got := demo.summary(); !strings.HasPrefix(got, "Authored 2500 commits between") {
+		t.Errorf("summary %q, want the exact commit count", got)
+	}
+}
+
+func TestCountOwnedCommitsDoesNotWaitForStatistics(t *testing.T) {
+	// GitHub answers 202 while it computes the statistics
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &RepoContributions{Repo: "octo/demo", Commits: maxSearchPages, CommitsAtLeast: true}
+	countOwnedCommits(context.Background(), client, newTestRetrier(5, nil), "octo", repo)
+	if requests != 1 {
+		t.Errorf("got %d requests, want the 202 accepted without retrying", requests)
+	}
+	if repo.Commits != maxSearchPages || !repo.CommitsAtLeast {
+		t.Errorf("got %+v, want the searched count kept as a lower bound", repo)
+	}
+}
@@ -0,0 +1,35 @@
+// Filename: documents.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// extractDocumentText returns the text of an input file, converting formats
+// that are not plain text so no binary data reaches the prompt
+func extractDocumentText(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf":
+		return extractPDFText(path)
+	case ".docx":
+		return extractDOCXText(path)
+	case ".odt":
+		return extractODTText(path)
+	case ".doc":
+		return "", fmt.Errorf("legacy .doc files are not supported; save the file as .docx")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(content, 0) != -1 || !utf8.Valid(content) {
+		return "", fmt.Errorf("unsupported binary file format")
+	}
+	return string(content), nil
+}
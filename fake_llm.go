@@ -0,0 +1,139 @@
+// Filename: fake_llm.go
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fakeEmbeddingDimensions is the length of the vectors returned by the fake provider
+const fakeEmbeddingDimensions = 16
+
+// FakeFixtures is the on-disk format of scripted fake provider responses
+type FakeFixtures struct {
+	Default   string            `json:"default"`   // Response for prompts without a scripted entry
+	Responses map[string]string `json:"responses"` // Responses keyed by prompt hash
+}
+
+// fakeProvider is a deterministic, offline LLMProvider for tests and demos
+type fakeProvider struct {
+	fixtures FakeFixtures
+}
+
+// newFakeProvider loads scripted responses from fixturesPath, if one is given
+func newFakeProvider(fixturesPath string) (*fakeProvider, error) {
+	p := &fakeProvider{fixtures: FakeFixtures{Responses: map[string]string{}}}
+	if fixturesPath == "" {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(fixturesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading fake LLM fixtures: %v", err)
+	}
+	if err := json.Unmarshal(data, &p.fixtures); err != nil {
+		return nil, fmt.Errorf("parsing fake LLM fixtures %s: %v", fixturesPath, err)
+	}
+	if p.fixtures.Responses == nil {
+		p.fixtures.Responses = map[string]string{}
+	}
+	return p, nil
+}
+
+// promptHash identifies a request by the roles and contents of its messages
+func promptHash(messages []ChatMessage) string {
+	h := sha256.New()
+	for _, msg := range messages {
+		h.Write([]byte(msg.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(msg.Content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *fakeProvider) Name() string {
+	return providerFake
+}
+
+// Complete returns the scripted response for the prompt, or a canned one
+func (p *fakeProvider) Complete(ctx context.Context, req CompletionRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	hash := promptHash(req.Messages)
+	if response, ok := p.fixtures.Responses[hash]; ok {
+		return response, nil
+	}
+	if logger != nil {
+		logger.Printf("Fake LLM has no scripted response for prompt %s", hash)
+	}
+	if p.fixtures.Default != "" {
+		return p.fixtures.Default, nil
+	}
+
+	var lastUserMessage string
+	for _, msg := range req.Messages {
+		if msg.Role == "user" {
+			lastUserMessage = msg.Content
+		}
+	}
+	summary := strings.SplitN(strings.TrimSpace(lastUserMessage), "\n", 2)[0]
+	if runes := []rune(summary); len(runes) > 80 {
+		summary = string(runes[:80]) + "..."
+	}
+	response := fmt.Sprintf("[fake response %s]\n%s", hash[:12], summary)
+
+	// Structured requests get a minimal document, so their parsing runs offline
+	if strings.Contains(lastUserMessage, jsonResumeInstruction) {
+		data, err := json.Marshal(&JSONResume{
+			Schema: jsonResumeSchema,
+			Basics: JSONResumeBasics{Summary: response},
+		})
+		return string(data), err
+	}
+	return response, nil
+}
+
+// Stream delivers the Complete response word by word
+func (p *fakeProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	response, err := p.Complete(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if onDelta != nil {
+		for _, word := range strings.SplitAfter(response, " ") {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			onDelta(word)
+		}
+	}
+	return response, nil
+}
+
+// Embed derives a stable vector from the SHA-256 of each input
+func (p *fakeProvider) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		sum := sha256.Sum256([]byte(input))
+		vector := make([]float32, fakeEmbeddingDimensions)
+		for j := range vector {
+			word := binary.BigEndian.Uint16(sum[(j*2)%len(sum):])
+			vector[j] = float32(word)/32767.5 - 1
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
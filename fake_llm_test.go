@@ -0,0 +1,222 @@
+// Filename: fake_llm_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testPrompt is the request the fake provider tests script responses for
+var testPrompt = CompletionRequest{Messages: []ChatMessage{
+	{Role: "system", Content: "You write resumes."},
+	{Role: "user", Content: "Using the following data, generate a professional resume:\n\n# Contact"},
+}}
+
+// writeFakeFixtures writes fixtures to a temporary file and returns its path
+func writeFakeFixtures(t *testing.T, fixtures FakeFixtures) string {
+	t.Helper()
+	data, err := json.Marshal(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPromptHash(t *testing.T) {
+	hash := promptHash(testPrompt.Messages)
+	if len(hash) != 64 || hash != promptHash(testPrompt.Messages) {
+		t.Fatalf("hash %q is not a stable SHA-256", hash)
+	}
+
+	// Moving text between roles or messages changes the hash
+	for _, messages := range [][]ChatMessage{
+		{{Role: "user", Content: "You write resumes."}, testPrompt.Messages[1]},
+		{{Role: "system", Content: "You write"}, {Role: "system", Content: " resumes."}, testPrompt.Messages[1]},
+		testPrompt.Messages[:1],
+	} {
+		if promptHash(messages) == hash {
+			t.Errorf("%v hashes like the test prompt", messages)
+		}
+	}
+}
+
+func TestFakeProviderFixtures(t *testing.T) {
+	path := writeFakeFixtures(t, FakeFixtures{
+		Default:   "default reply",
+		Responses: map[string]string{promptHash(testPrompt.Messages): "scripted resume"},
+	})
+	llm, err := newFakeProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if got, err := llm.Complete(ctx, testPrompt); err != nil || got != "scripted resume" {
+		t.Errorf("scripted prompt: got %q, %v", got, err)
+	}
+	other := CompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "Something else"}}}
+	if got, err := llm.Complete(ctx, other); err != nil || got != "default reply" {
+		t.Errorf("unscripted prompt: got %q, %v", got, err)
+	}
+}
+
+func TestFakeProviderFixtureErrors(t *testing.T) {
+	if _, err := newFakeProvider(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("a missing fixtures file was accepted")
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFakeProvider(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("got %v, want a parse error naming the file", err)
+	}
+
+	// A file without responses still answers with the default
+	llm, err := newFakeProvider(writeFakeFixtures(t, FakeFixtures{Default: "only default"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := llm.Complete(context.Background(), testPrompt); got != "only default" {
+		t.Errorf("got %q, want the default", got)
+	}
+}
+
+func TestFakeProviderCannedReply(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := llm.Complete(context.Background(), testPrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[fake response " + promptHash(testPrompt.Messages)[:12] + "]\nUsing the following data, generate a professional resume:"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	long := CompletionRequest{Messages: []ChatMessage{{Role: "user", Content: strings.Repeat("x", 100)}}}
+	got, _ = llm.Complete(context.Background(), long)
+	if !strings.HasSuffix(got, "\n"+strings.Repeat("x", 80)+"...") {
+		t.Errorf("long prompt summary not shortened: %q", got)
+	}
+}
+
+func TestFakeProviderStream(t *testing.T) {
+	llm, err := newFakeProvider(writeFakeFixtures(t, FakeFixtures{Default: "three word reply"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		var deltas []string
+		got, err := llm.Stream(context.Background(), testPrompt, func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "three word reply" || !reflect.DeepEqual(deltas, []string{"three ", "word ", "reply"}) {
+			t.Errorf("run %d: got %q in %q", i, got, deltas)
+		}
+	}
+}
+
+func TestFakeProviderEmbed(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{"Go developer", "Rust developer", "Go developer"}
+	first, err := llm.Embed(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := llm.Embed(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Error("embeddings differ between calls")
+	}
+	if len(first) != len(inputs) || len(first[0]) != fakeEmbeddingDimensions {
+		t.Fatalf("got %d vectors of %d dimensions", len(first), len(first[0]))
+	}
+	if !reflect.DeepEqual(first[0], first[2]) || reflect.DeepEqual(first[0], first[1]) {
+		t.Error("embeddings do not follow the input text")
+	}
+	for _, v := range first[0] {
+		if v < -1 || v > 1 {
+			t.Errorf("component %v is outside [-1, 1]", v)
+		}
+	}
+}
+
+func TestFakeProviderCancelled(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := llm.Complete(ctx, testPrompt); err != context.Canceled {
+		t.Errorf("Complete: got %v", err)
+	}
+	if _, err := llm.Stream(ctx, testPrompt, nil); err != context.Canceled {
+		t.Errorf("Stream: got %v", err)
+	}
+	if _, err := llm.Embed(ctx, []string{"x"}); err != context.Canceled {
+		t.Errorf("Embed: got %v", err)
+	}
+}
+
+func TestFakeProviderCannedJSONResume(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := CompletionRequest{Messages: []ChatMessage{
+		{Role: "system", Content: "You write resumes."},
+		{Role: "user", Content: "Using the following data, generate a professional resume. " + jsonResumeInstruction + "\n\n# Contact"},
+	}}
+
+	reply, err := llm.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resume, ok, err := parseJSONResume([]byte(reply))
+	if err != nil || !ok {
+		t.Fatalf("reply %q is not a JSON Resume: %v", reply, err)
+	}
+	if !strings.HasPrefix(resume.Basics.Summary, "[fake response "+promptHash(req.Messages)[:12]+"]") {
+		t.Errorf("summary = %q", resume.Basics.Summary)
+	}
+}
+
+func TestFakeProviderCannedReplyKeepsRunes(t *testing.T) {
+	llm, err := newFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := CompletionRequest{Messages: []ChatMessage{{Role: "user", Content: strings.Repeat("é", 100)}}}
+	got, _ := llm.Complete(context.Background(), req)
+	if !utf8.ValidString(got) || !strings.HasSuffix(got, "\n"+strings.Repeat("é", 80)+"...") {
+		t.Errorf("summary not cut after 80 characters: %q", got)
+	}
+}
@@ -0,0 +1,533 @@
+// Filename: forge.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// errNoReadme is returned by repoSource.readme for repositories without a README
+var errNoReadme = errors.New("no README")
+
+// repoSource lists a user's repositories on a code hosting service other than
+// GitHub and reads their READMEs, so they can be synced like GitHub's
+type repoSource interface {
+	// login returns the name of the authenticated user
+	login(ctx context.Context) (string, error)
+	// repos lists the repositories of the authenticated user
+	repos(ctx context.Context) ([]*forgeRepo, error)
+	// readme returns the README of repo and its blob SHA, or errNoReadme
+	readme(ctx context.Context, repo *forgeRepo) (string, string, error)
+	// languages records the language breakdown of repo in md, as sizes in
+	// md.Languages or, when the service reports no sizes, md.LanguageShares
+	languages(ctx context.Context, repo *forgeRepo, md *RepoMetadata) error
+	// list returns the names of the files and directories in dir on the
+	// default branch, "" being the top of the repository
+	list(ctx context.Context, repo *forgeRepo, dir string) ([]string, []string, error)
+	// file returns the content of the file at path on the default branch
+	file(ctx context.Context, repo *forgeRepo, path string) (string, error)
+}
+
+// forgeRepo is a repository listed by a repoSource
+type forgeRepo struct {
+	ID       string // API identifier of the repository
+	Owner    string // User, group or organization path
+	Name     string
+	FullName string // Owner and name as shown by the service
+	Branch   string // Default branch
+	Fork     bool
+	Archived bool
+	Readme   string // README path when the listing includes it
+	Metadata *RepoMetadata
+}
+
+// ForgeConfig controls which repositories are fetched from a GitLab or
+// Gitea/Forgejo instance
+type ForgeConfig struct {
+	BaseURL         string `yaml:"base_url"`         // Instance URL
+	TokenEnv        string `yaml:"token_env"`        // Environment variable holding the access token
+	OwnedOnly       bool   `yaml:"owned_only"`       // Skip repositories owned by groups, organizations or other users
+	IncludeForks    bool   `yaml:"include_forks"`    // Include forked repositories
+	IncludeArchived bool   `yaml:"include_archived"` // Include archived repositories
+	Workers         int    `yaml:"workers"`          // Concurrent README downloads
+	MaxRetries      int    `yaml:"max_retries"`      // Retries for server errors and rate limits
+	Stack           bool   `yaml:"stack"`            // Analyze manifests for the tech stack
+	CABundle        string `yaml:"ca_bundle"`        // PEM file of extra trusted certificate authorities
+}
+
+// defaultForgeConfig lists every repository the user owns on the instance at baseURL
+func defaultForgeConfig(baseURL, tokenEnv string) ForgeConfig {
+	return ForgeConfig{
+		BaseURL:         baseURL,
+		TokenEnv:        tokenEnv,
+		OwnedOnly:       true,
+		IncludeForks:    true,
+		IncludeArchived: true,
+		Workers:         4,
+		MaxRetries:      5,
+		Stack:           true,
+	}
+}
+
+// applyEnv applies the AMALGIA_<PREFIX>_* environment overrides
+func (cfg *ForgeConfig) applyEnv(prefix string) error {
+	stringVars := map[string]*string{
+		"AMALGIA_" + prefix + "_BASE_URL":  &cfg.BaseURL,
+		"AMALGIA_" + prefix + "_TOKEN_ENV": &cfg.TokenEnv,
+		"AMALGIA_" + prefix + "_CA_BUNDLE": &cfg.CABundle,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+
+	boolVars := map[string]*bool{
+		"AMALGIA_" + prefix + "_OWNED_ONLY":       &cfg.OwnedOnly,
+		"AMALGIA_" + prefix + "_INCLUDE_FORKS":    &cfg.IncludeForks,
+		"AMALGIA_" + prefix + "_INCLUDE_ARCHIVED": &cfg.IncludeArchived,
+		"AMALGIA_" + prefix + "_STACK":            &cfg.Stack,
+	}
+	for name, target := range boolVars {
+		if value := os.Getenv(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			*target = b
+		}
+	}
+
+	intVars := map[string]*int{
+		"AMALGIA_" + prefix + "_WORKERS":     &cfg.Workers,
+		"AMALGIA_" + prefix + "_MAX_RETRIES": &cfg.MaxRetries,
+	}
+	for name, target := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			*target = n
+		}
+	}
+	return nil
+}
+
+// validate checks the settings needed to fetch from the instance
+func (cfg ForgeConfig) validate() error {
+	if cfg.BaseURL == "" {
+		return fmt.Errorf("base URL must be set")
+	}
+	if u, err := url.Parse(cfg.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q (expected an http or https URL)", cfg.BaseURL)
+	}
+	if cfg.TokenEnv == "" {
+		return fmt.Errorf("token_env must name an environment variable")
+	}
+	if cfg.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1, got %d", cfg.Workers)
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative, got %d", cfg.MaxRetries)
+	}
+	return nil
+}
+
+// forgeClient sends JSON API requests to a GitLab or Gitea instance
+type forgeClient struct {
+	baseURL *url.URL // API root, ending in a slash
+	http    *http.Client
+	auth    func(req *http.Request)
+	backoff backoff
+	source  string             // Source the instance serves, named in rate-limit reports
+	report  func(RateLimitMsg) // Receives rate-limit status; may be nil
+}
+
+// newForgeClient creates a client for the API at apiPath below cfg.BaseURL
+func newForgeClient(cfg ForgeConfig, source, apiPath string, auth func(req *http.Request)) (*forgeClient, error) {
+	transport, err := newTransport(cfg.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/") + apiPath)
+	if err != nil {
+		return nil, err
+	}
+	return &forgeClient{
+		baseURL: baseURL,
+		http:    &http.Client{Transport: activeCassette.Transport(transport), Timeout: githubRequestTimeout},
+		auth:    auth,
+		backoff: newBackoff(cfg.MaxRetries),
+		source:  source,
+	}, nil
+}
+
+// forgeHTTPError is an unsuccessful API response
+type forgeHTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *forgeHTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// get decodes the JSON response to a GET of path into v, retrying server
+// errors and rate limits. The response headers are returned for paging.
+func (c *forgeClient) get(ctx context.Context, path string, query url.Values, v interface{}) (http.Header, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	u := c.baseURL.ResolveReference(ref)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	var header http.Header
+	var retryAfter time.Duration
+	err = c.backoff.retry(ctx, func() (err error) {
+		header, retryAfter, err = c.getOnce(ctx, u.String(), v)
+		c.reportRate(header, time.Time{}, false)
+		return err
+	}, func(err error, attempt int) (time.Duration, bool) {
+		var httpErr *forgeHTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode != http.StatusTooManyRequests && httpErr.StatusCode < http.StatusInternalServerError {
+			return 0, false
+		}
+		wait := retryAfter
+		if wait <= 0 {
+			wait = c.backoff.delay(attempt)
+		}
+		if httpErr != nil && httpErr.StatusCode == http.StatusTooManyRequests {
+			c.reportRate(header, time.Now().Add(wait), true)
+		}
+		return wait, true
+	})
+	return header, err
+}
+
+// reportRate forwards the rate-limit headers of a response to the progress
+// view. GitLab sends RateLimit-*, Gitea and proxies in front of it X-RateLimit-*.
+// Pauses are reported even when the server sent no limit headers.
+func (c *forgeClient) reportRate(header http.Header, waitUntil time.Time, paused bool) {
+	if c.report == nil {
+		return
+	}
+	value := func(name string) int64 {
+		for _, key := range []string{"RateLimit-" + name, "X-RateLimit-" + name} {
+			if n, err := strconv.ParseInt(header.Get(key), 10, 64); err == nil {
+				return n
+			}
+		}
+		return 0
+	}
+	msg := RateLimitMsg{
+		Source:    c.source,
+		Limit:     int(value("Limit")),
+		Remaining: int(value("Remaining")),
+		WaitUntil: waitUntil,
+	}
+	if reset := value("Reset"); reset > 0 {
+		msg.Reset = time.Unix(reset, 0)
+	}
+	if msg.Limit == 0 && !paused {
+		return
+	}
+	c.report(msg)
+}
+
+// getOnce sends a single request, returning how long the server asked to wait on failure
+func (c *forgeClient) getOnce(ctx context.Context, rawURL string, v interface{}) (http.Header, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	c.auth(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return resp.Header, retryAfter, &forgeHTTPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.Header, 0, fmt.Errorf("decoding response: %v", err)
+	}
+	return resp.Header, 0, nil
+}
+
+// isNotFound reports whether err is a 404 response
+func isNotFound(err error) bool {
+	var httpErr *forgeHTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
+// newRepoSource creates the client for source from its configuration
+// and passes rate-limit status to report
+func newRepoSource(source string, cfg ForgeConfig, report func(RateLimitMsg)) (repoSource, error) {
+	token := os.Getenv(cfg.TokenEnv)
+	if token == "" && !activeCassette.replaying() {
+		return nil, fmt.Errorf("%s environment variable not set", cfg.TokenEnv)
+	}
+	switch source {
+	case sourceGitLab:
+		src, err := newGitLabSource(cfg, token)
+		if err != nil {
+			return nil, err
+		}
+		src.client.report = report
+		return src, nil
+	case sourceGitea:
+		src, err := newGiteaSource(cfg, token)
+		if err != nil {
+			return nil, err
+		}
+		src.client.report = report
+		return src, nil
+	}
+	return nil, fmt.Errorf("unknown repository source %q", source)
+}
+
+// fetchForgeREADMEs syncs the READMEs of the repositories the user has on a
+// GitLab or Gitea/Forgejo instance, the same way fetchREADMEs does for GitHub
+func fetchForgeREADMEs(ctx context.Context, source string, cfg ForgeConfig, readmesDir string, send func(tea.Msg)) (FetchCompleteMsg, error) {
+	logf := func(format string, args ...interface{}) {
+		send(LogMsg(fmt.Sprintf(format, args...)))
+	}
+	fail := func(format string, args ...interface{}) (FetchCompleteMsg, error) {
+		errMsg := fmt.Sprintf(format, args...)
+		send(LogMsg(errMsg))
+		return FetchCompleteMsg{}, fmt.Errorf(errMsg)
+	}
+
+	logf("Starting to fetch READMEs from %s at %s.", source, cfg.BaseURL)
+	src, err := newRepoSource(source, cfg, func(msg RateLimitMsg) {
+		send(msg)
+	})
+	if err != nil {
+		return fail("Error creating %s client: %v", source, err)
+	}
+	login, err := src.login(ctx)
+	if err != nil {
+		return fail("Error getting %s user: %v", source, err)
+	}
+	repos, err := src.repos(ctx)
+	if err != nil {
+		return fail("Error listing %s repositories: %v", source, err)
+	}
+
+	if err := os.MkdirAll(readmesDir, os.ModePerm); err != nil {
+		return fail("Failed to create directory '%s': %v", readmesDir, err)
+	}
+	index, err := loadSyncIndex(readmesDir)
+	if err != nil {
+		return fail("Error loading sync index: %v", err)
+	}
+
+	var included []*forgeRepo
+	for _, repo := range repos {
+		if (repo.Fork && !cfg.IncludeForks) || (repo.Archived && !cfg.IncludeArchived) || (cfg.OwnedOnly && !strings.EqualFold(repo.Owner, login)) {
+			continue
+		}
+		included = append(included, repo)
+	}
+	results := newReadmeResults(readmesDir, index, send)
+	results.start(len(included))
+
+	type readmeJob struct {
+		repo  *forgeRepo
+		name  string
+		entry *SyncEntry
+	}
+	jobs := make(chan readmeJob, len(included))
+	for _, repo := range included {
+		name := forgeReadmeKey(source, repo, login)
+		results.list(name)
+		jobs <- readmeJob{repo: repo, name: name, entry: index.Entries[name]}
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				content, updated, outcome, err := syncForgeReadme(ctx, src, source, readmesDir, job.name, job.repo, job.entry, cfg.Stack, results.logf)
+				results.record(job.name, content, updated, outcome, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() == nil {
+		for _, name := range pruneSyncIndex(readmesDir, index, results.listed, source) {
+			logf("Removed README for deleted repository: %s", name)
+		}
+	}
+	if err := index.save(readmesDir); err != nil {
+		logf("Error saving sync index: %v", err)
+	}
+	if ctx.Err() != nil {
+		logf("README fetch cancelled after %d of %d repositories.", results.fetched+results.unchanged, results.total)
+		return FetchCompleteMsg{}, ctx.Err()
+	}
+	logf("README sync from %s finished: %d fetched, %d unchanged, %d failed.", source, results.fetched, results.unchanged, results.failed)
+
+	sort.Strings(results.names)
+	return FetchCompleteMsg{
+		Sources:       []string{source},
+		Readmes:       results.contents,
+		Metadata:      results.metadata,
+		ReadmeSources: results.sources,
+		Names:         results.names,
+		Fetched:       results.fetched,
+		Unchanged:     results.unchanged,
+		Failed:        results.failed,
+	}, nil
+}
+
+// forgeReadmeKey names a repository's README after the source, qualifying
+// repositories the user does not own. The "@" prefix cannot start a GitHub
+// owner or repository name, so the keys never collide with GitHub's.
+func forgeReadmeKey(source string, repo *forgeRepo, login string) string {
+	if strings.EqualFold(repo.Owner, login) {
+		return "@" + source + "/" + repo.Name
+	}
+	return "@" + source + "/" + repo.FullName
+}
+
+// syncForgeReadme stores the README and metadata of a repository. Nothing is
+// requested for repositories without activity since the last sync, and the
+// README file is only rewritten when its blob changed.
+func syncForgeReadme(ctx context.Context, src repoSource, source, readmesDir, name string, repo *forgeRepo, entry *SyncEntry, analyzeStack bool, logf func(string, ...interface{})) (string, *SyncEntry, string, error) {
+	cached := func() (string, bool) {
+		if entry == nil {
+			return "", false
+		}
+		content, err := os.ReadFile(filepath.Join(readmesDir, entry.File))
+		return string(content), err == nil
+	}
+	metadata := *repo.Metadata
+	updated := &SyncEntry{
+		FullName:  repo.FullName,
+		File:      readmeFilename(name),
+		Source:    source,
+		PushedAt:  metadata.PushedAt,
+		FetchedAt: time.Now(),
+		Metadata:  &metadata,
+	}
+	// Manifests are only read again after new activity
+	analyze := func() {
+		if !analyzeStack {
+			return
+		}
+		if previous := entry.unchangedMetadata(metadata.PushedAt); previous != nil && previous.Stack != nil {
+			metadata.Stack = previous.Stack
+			return
+		}
+		if stack, err := forgeStack(ctx, src, repo); err == nil {
+			metadata.Stack = stack
+		} else if ctx.Err() == nil {
+			logf("Error analyzing manifests of %s: %v", name, err)
+		}
+	}
+
+	if entry != nil && entry.PushedAt.Equal(metadata.PushedAt) {
+		if content, ok := cached(); ok {
+			if entry.Metadata != nil {
+				metadata.Languages = entry.Metadata.Languages
+				metadata.LanguageShares = entry.Metadata.LanguageShares
+			}
+			analyze()
+			updated.File, updated.SHA, updated.FetchedAt = entry.File, entry.SHA, entry.FetchedAt
+			return content, updated, syncUnchanged, nil
+		}
+	}
+
+	content, sha, err := src.readme(ctx, repo)
+	if errors.Is(err, errNoReadme) {
+		return "", nil, syncMissing, nil
+	}
+	if err != nil {
+		return "", nil, "", err
+	}
+	updated.SHA = sha
+
+	if err := src.languages(ctx, repo, &metadata); err != nil && ctx.Err() == nil {
+		// The README is still worth keeping without the language breakdown
+		logf("Error fetching languages of %s: %v", name, err)
+	}
+	analyze()
+
+	if entry != nil && entry.SHA == sha {
+		if _, ok := cached(); ok {
+			updated.File = entry.File
+			return content, updated, syncUnchanged, nil
+		}
+	}
+	if err := writeFileAtomic(filepath.Join(readmesDir, updated.File), []byte(content)); err != nil {
+		return "", nil, "", fmt.Errorf("writing README to file: %v", err)
+	}
+	return content, updated, syncFetched, nil
+}
+
+// forgeStack analyzes the manifests on the default branch of a repository
+func forgeStack(ctx context.Context, src repoSource, repo *forgeRepo) ([]StackEvidence, error) {
+	list := func(dir string) ([]string, []string, error) {
+		files, dirs, err := src.list(ctx, repo, dir)
+		if isNotFound(err) {
+			return nil, nil, nil
+		}
+		return files, dirs, err
+	}
+	read := func(path string) (string, error) {
+		return src.file(ctx, repo, path)
+	}
+	files, err := collectManifests(list, read)
+	if err != nil {
+		return nil, err
+	}
+	return detectStack(files), nil
+}
+
+// readmeRank orders README file names by preference; -1 for other files
+func readmeRank(name string) int {
+	for i, readme := range graphQLReadmeAliases {
+		if name == readme.path {
+			return i
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(name), "readme") {
+		return len(graphQLReadmeAliases)
+	}
+	return -1
+}
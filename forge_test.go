@@ -0,0 +1,408 @@
+// Filename: forge_test.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// newForgeTestServer serves handler as a GitLab or Gitea API and returns the
+// configuration of an instance pointing at it
+func newForgeTestServer(t *testing.T, handler http.HandlerFunc) ForgeConfig {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return defaultForgeConfig(server.URL, "FORGE_TEST_TOKEN")
+}
+
+func TestGitLabReposPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		nextPage  func(page int) string // X-Next-Page of each page
+		pageSizes []int
+		want      int
+	}{
+		{"follows X-Next-Page", func(page int) string { return strconv.Itoa(page + 1) }, []int{gitLabPageSize, gitLabPageSize, 3}, 2*gitLabPageSize + 3},
+		{"stops without X-Next-Page", func(int) string { return "" }, []int{gitLabPageSize, gitLabPageSize}, gitLabPageSize},
+		{"stops at a short page", func(page int) string { return strconv.Itoa(page + 1) }, []int{gitLabPageSize, 1, gitLabPageSize}, gitLabPageSize + 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v4/projects" || r.Header.Get("PRIVATE-TOKEN") != "token" {
+					http.NotFound(w, r)
+					return
+				}
+				requests++
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page < 1 || page > len(test.pageSizes) {
+					t.Errorf("requested page %d of %d", page, len(test.pageSizes))
+					writeJSON(w, []gitLabProject{})
+					return
+				}
+				projects := make([]gitLabProject, test.pageSizes[page-1])
+				for i := range projects {
+					projects[i].ID = page*1000 + i
+				}
+				if next := test.nextPage(page); next != "" {
+					w.Header().Set("X-Next-Page", next)
+				}
+				writeJSON(w, projects)
+			})
+			src, err := newGitLabSource(cfg, "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			repos, err := src.repos(context.Background())
+			if err != nil {
+				t.Fatalf("listing projects: %v", err)
+			}
+			if len(repos) != test.want {
+				t.Errorf("got %d projects in %d requests, want %d", len(repos), requests, test.want)
+			}
+		})
+	}
+}
+
+func TestGiteaReposPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxItems  int // The instance's MAX_RESPONSE_ITEMS
+		total     int
+		withTotal bool // Whether X-Total-Count is sent
+		want      int  // Requests made
+	}{
+		{"stops at X-Total-Count", giteaPageSize, 2*giteaPageSize + 3, true, 3},
+		{"lower MAX_RESPONSE_ITEMS with X-Total-Count", 20, 45, true, 3},
+		{"lower MAX_RESPONSE_ITEMS without X-Total-Count", 20, 45, false, 4},
+		{"no repositories", giteaPageSize, 0, true, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/user/repos" {
+					http.NotFound(w, r)
+					return
+				}
+				requests++
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				if limit > test.maxItems {
+					limit = test.maxItems
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				var repos []giteaRepository
+				for i := (page - 1) * limit; i < page*limit && i < test.total; i++ {
+					repos = append(repos, giteaRepository{ID: i})
+				}
+				if test.withTotal {
+					w.Header().Set("X-Total-Count", strconv.Itoa(test.total))
+				}
+				writeJSON(w, append([]giteaRepository{}, repos...))
+			})
+			src, err := newGiteaSource(cfg, "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			repos, err := src.repos(context.Background())
+			if err != nil {
+				t.Fatalf("listing repositories: %v", err)
+			}
+			if len(repos) != test.total || requests != test.want {
+				t.Errorf("got %d repositories in %d requests, want %d in %d", len(repos), requests, test.total, test.want)
+			}
+		})
+	}
+}
+
+func TestGitLabProjectReadmePath(t *testing.T) {
+	tests := []struct {
+		readmeURL, branch, want string
+	}{
+		{"https://gitlab.example.com/group/sub/project/-/blob/main/README.md", "main", "README.md"},
+		{"https://gitlab.example.com/group/project/-/blob/release/v1/docs/README.rst", "release/v1", "docs/README.rst"},
+		{"https://gitlab.example.com/group/project/-/blob/main/README.md", "develop", ""},
+		{"", "main", ""},
+	}
+	for _, test := range tests {
+		repo := gitLabProject{ReadmeURL: test.readmeURL, DefaultBranch: test.branch}.repo()
+		if repo.Readme != test.want {
+			t.Errorf("readme_url %q on %s gave path %q, want %q", test.readmeURL, test.branch, repo.Readme, test.want)
+		}
+	}
+}
+
+func TestGitLabLanguagesAreShares(t *testing.T) {
+	cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/42/languages" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]float64{"Go": 75.5, "Shell": 24.5})
+	})
+	src, err := newGitLabSource(cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := &RepoMetadata{}
+	if err := src.languages(context.Background(), &forgeRepo{ID: "42"}, md); err != nil {
+		t.Fatal(err)
+	}
+	// Percentages are not sizes, so they are kept apart from GitHub's bytes of code
+	if md.Languages != nil || md.LanguageShares["Go"] != 75.5 {
+		t.Errorf("got sizes %v and shares %v, want only the shares", md.Languages, md.LanguageShares)
+	}
+	if shares := md.languageShares(); len(shares) != 2 || shares[0] != "Go 76%" || shares[1] != "Shell 24%" {
+		t.Errorf("got %q", shares)
+	}
+}
+
+func TestGiteaReadmeRanking(t *testing.T) {
+	var requested string
+	cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/octo/demo/contents/":
+			var entries []giteaContent
+			for _, name := range []string{"README.de.md", "main.go", "readme.md", "README.txt", "README.md"} {
+				entries = append(entries, giteaContent{Name: name, Type: "file"})
+			}
+			entries = append(entries, giteaContent{Name: "README", Type: "dir"})
+			writeJSON(w, entries)
+		case "/api/v1/repos/octo/demo/contents/README.md":
+			requested = r.URL.Path
+			writeJSON(w, giteaContent{Name: "README.md", Type: "file", SHA: "abc", Encoding: "base64", Content: base64.StdEncoding.EncodeToString([]byte("# Demo\n"))})
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+	src, err := newGiteaSource(cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, sha, err := src.readme(context.Background(), &forgeRepo{Owner: "octo", Name: "demo", Branch: "main"})
+	if err != nil {
+		t.Fatalf("reading README: %v", err)
+	}
+	if requested == "" || content != "# Demo\n" || sha != "abc" {
+		t.Errorf("got %q with SHA %q, want README.md preferred over the other READMEs", content, sha)
+	}
+}
+
+func TestForgeClientRetriesServerErrors(t *testing.T) {
+	attempts := 0
+	cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		if attempts == 2 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, map[string]string{"login": "octo"})
+	})
+	cfg.MaxRetries = 2
+	src, err := newGiteaSource(cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.client.backoff.baseDelay = time.Millisecond
+	login, err := src.login(context.Background())
+	if err != nil || login != "octo" || attempts != 3 {
+		t.Errorf("got %q, %v after %d attempts, want octo after 3", login, err, attempts)
+	}
+
+	// Client errors are not retried
+	attempts = 0
+	cfg = newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	})
+	src, err = newGiteaSource(cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.login(context.Background()); err == nil || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want the 401 after one", err, attempts)
+	}
+}
+
+func TestForgeClientReportsRateLimits(t *testing.T) {
+	reset := time.Now().Add(time.Minute).Truncate(time.Second)
+	attempts := 0
+	cfg := newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("RateLimit-Limit", "600")
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if attempts == 1 {
+			w.Header().Set("RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("RateLimit-Remaining", "599")
+		writeJSON(w, map[string]string{"username": "octo"})
+	})
+	t.Setenv("FORGE_TEST_TOKEN", "token")
+	var reports []RateLimitMsg
+	src, err := newRepoSource(sourceGitLab, cfg, func(msg RateLimitMsg) {
+		reports = append(reports, msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(*gitLabSource).client.backoff.baseDelay = time.Millisecond
+	if _, err := src.login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want the 429, its pause and the success: %+v", len(reports), reports)
+	}
+	for _, msg := range reports {
+		if msg.Source != sourceGitLab || msg.Limit != 600 || !msg.Reset.Equal(reset) {
+			t.Errorf("got %+v, want GitLab's limit of 600 resetting at %v", msg, reset)
+		}
+	}
+	if pause := reports[1]; pause.Remaining != 0 || pause.WaitUntil.IsZero() {
+		t.Errorf("got %+v, want the pause reported", pause)
+	}
+	if last := reports[2]; last.Remaining != 599 || !last.WaitUntil.IsZero() {
+		t.Errorf("got %+v, want the remaining requests after the pause", last)
+	}
+
+	// A 429 without limit headers still reports the pause
+	reports = nil
+	attempts = 0
+	cfg = newForgeTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, map[string]string{"login": "octo"})
+	})
+	src, err = newRepoSource(sourceGitea, cfg, func(msg RateLimitMsg) {
+		reports = append(reports, msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.(*giteaSource).client.backoff.baseDelay = time.Millisecond
+	if _, err := src.login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Source != sourceGitea || reports[0].WaitUntil.IsZero() {
+		t.Errorf("got %+v, want one Gitea pause", reports)
+	}
+}
+
+// fakeRepoSource serves a single README and counts the calls made for it
+type fakeRepoSource struct {
+	content, sha string
+	calls        map[string]int
+}
+
+func (s *fakeRepoSource) login(context.Context) (string, error) { return "octo", nil }
+
+func (s *fakeRepoSource) repos(context.Context) ([]*forgeRepo, error) { return nil, nil }
+
+func (s *fakeRepoSource) readme(context.Context, *forgeRepo) (string, string, error) {
+	s.calls["readme"]++
+	return s.content, s.sha, nil
+}
+
+func (s *fakeRepoSource) languages(_ context.Context, _ *forgeRepo, md *RepoMetadata) error {
+	s.calls["languages"]++
+	md.Languages = map[string]int{"Go": 100}
+	return nil
+}
+
+func (s *fakeRepoSource) list(context.Context, *forgeRepo, string) ([]string, []string, error) {
+	s.calls["list"]++
+	return nil, nil, nil
+}
+
+func (s *fakeRepoSource) file(context.Context, *forgeRepo, string) (string, error) {
+	s.calls["file"]++
+	return "", nil
+}
+
+func TestForgeReadmeKey(t *testing.T) {
+	owned := &forgeRepo{Owner: "Octo", Name: "site", FullName: "Octo/site"}
+	shared := &forgeRepo{Owner: "team", Name: "site", FullName: "team/site"}
+	if got := forgeReadmeKey(sourceGitLab, owned, "octo"); got != "@gitlab/site" {
+		t.Errorf("owned: got %q, want @gitlab/site", got)
+	}
+	if got := forgeReadmeKey(sourceGitea, shared, "octo"); got != "@gitea/team/site" {
+		t.Errorf("shared: got %q, want @gitea/team/site", got)
+	}
+
+	// A GitHub user called gitlab owning a repository called site
+	githubKey := readmeKey(&github.Repository{Name: github.String("site"), Owner: &github.User{Login: github.String("gitlab")}}, "octo")
+	if key := forgeReadmeKey(sourceGitLab, owned, "octo"); key == githubKey || readmeFilename(key) == readmeFilename(githubKey) {
+		t.Errorf("GitLab key %q collides with GitHub key %q", key, githubKey)
+	}
+}
+
+func TestSyncForgeReadmeSkipsUnchanged(t *testing.T) {
+	pushedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	name := "@gitea/demo"
+	file := readmeFilename(name)
+	if err := os.WriteFile(filepath.Join(dir, file), []byte("# Cached\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entry := &SyncEntry{FullName: "octo/demo", File: file, Source: sourceGitea, SHA: "abc", PushedAt: pushedAt, Metadata: &RepoMetadata{PushedAt: pushedAt, Stack: []StackEvidence{{Name: "Go"}}}}
+	repo := func(pushedAt time.Time) *forgeRepo {
+		return &forgeRepo{Owner: "octo", Name: "demo", FullName: "octo/demo", Branch: "main", Metadata: &RepoMetadata{PushedAt: pushedAt}}
+	}
+	logf := func(format string, args ...interface{}) { t.Errorf(format, args...) }
+	readFile := func() string {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// No activity since the last sync: nothing is requested
+	src := &fakeRepoSource{content: "# Demo\n", sha: "abc", calls: make(map[string]int)}
+	content, updated, outcome, err := syncForgeReadme(context.Background(), src, sourceGitea, dir, name, repo(pushedAt), entry, true, logf)
+	if err != nil || outcome != syncUnchanged || content != "# Cached\n" || len(src.calls) != 0 {
+		t.Errorf("got %q, %s, %v with calls %v, want the cached README without requests", content, outcome, err, src.calls)
+	}
+	if updated == nil || len(updated.Metadata.Stack) != 1 {
+		t.Errorf("got %+v, want the cached stack kept", updated)
+	}
+
+	// New activity with the same README blob: the file is left alone
+	later := pushedAt.Add(time.Hour)
+	_, updated, outcome, err = syncForgeReadme(context.Background(), src, sourceGitea, dir, name, repo(later), entry, false, logf)
+	if err != nil || outcome != syncUnchanged || src.calls["readme"] != 1 || readFile() != "# Cached\n" {
+		t.Errorf("got %s, %v with calls %v, want an unchanged README not rewritten", outcome, err, src.calls)
+	}
+	if updated == nil || !updated.PushedAt.Equal(later) || updated.Metadata.Languages["Go"] != 100 {
+		t.Errorf("got %+v, want the new push time and languages recorded", updated)
+	}
+
+	// A changed blob is written
+	src.content, src.sha = "# Demo v2\n", "def"
+	_, updated, outcome, err = syncForgeReadme(context.Background(), src, sourceGitea, dir, name, repo(later), entry, false, logf)
+	if err != nil || outcome != syncFetched || updated.SHA != "def" || readFile() != "# Demo v2\n" {
+		t.Errorf("got %s, %v, want the changed README written", outcome, err)
+	}
+}
@@ -0,0 +1,202 @@
+// Filename: gitea.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giteaPageSize is the number of repositories requested per page. Instances
+// with a lower MAX_RESPONSE_ITEMS return fewer, so short pages do not end the listing.
+const giteaPageSize = 50
+
+// giteaSource reads repositories from a Gitea or Forgejo instance, such as
+// Codeberg, through the API v1 both share
+type giteaSource struct {
+	client *forgeClient
+}
+
+// newGiteaSource creates a source authenticated with an access token
+func newGiteaSource(cfg ForgeConfig, token string) (*giteaSource, error) {
+	client, err := newForgeClient(cfg, sourceGitea, "/api/v1/", func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &giteaSource{client: client}, nil
+}
+
+func (s *giteaSource) login(ctx context.Context) (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, err := s.client.get(ctx, "user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Login, nil
+}
+
+// giteaRepository is the subset of a repository listing entry that is synced
+type giteaRepository struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Owner struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description"`
+	HTMLURL       string    `json:"html_url"`
+	Website       string    `json:"website"`
+	DefaultBranch string    `json:"default_branch"`
+	Topics        []string  `json:"topics"`
+	StarsCount    int       `json:"stars_count"`
+	ForksCount    int       `json:"forks_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+}
+
+// repos pages through the repositories the user owns or has access to;
+// ownership is filtered by the caller. Paging stops once X-Total-Count
+// repositories were listed or, without the header, at an empty page.
+func (s *giteaSource) repos(ctx context.Context) ([]*forgeRepo, error) {
+	query := url.Values{"limit": {strconv.Itoa(giteaPageSize)}}
+	var repos []*forgeRepo
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var listed []giteaRepository
+		header, err := s.client.get(ctx, "user/repos", query, &listed)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range listed {
+			repos = append(repos, &forgeRepo{
+				ID:       strconv.Itoa(r.ID),
+				Owner:    r.Owner.Login,
+				Name:     r.Name,
+				FullName: r.FullName,
+				Branch:   r.DefaultBranch,
+				Fork:     r.Fork,
+				Archived: r.Archived,
+				Metadata: &RepoMetadata{
+					Description: r.Description,
+					URL:         r.HTMLURL,
+					Homepage:    r.Website,
+					Topics:      r.Topics,
+					Stars:       r.StarsCount,
+					Forks:       r.ForksCount,
+					CreatedAt:   r.CreatedAt,
+					PushedAt:    r.UpdatedAt,
+				},
+			})
+		}
+		if len(listed) == 0 {
+			return repos, nil
+		}
+		if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil && len(repos) >= total {
+			return repos, nil
+		}
+	}
+}
+
+// giteaContent is an entry of the contents API
+type giteaContent struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // file or dir
+	SHA      string `json:"sha"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// readme looks for the preferred README among the files at the top of the default branch
+func (s *giteaSource) readme(ctx context.Context, repo *forgeRepo) (string, string, error) {
+	files, _, err := s.list(ctx, repo, "")
+	if isNotFound(err) {
+		// Empty repositories have no tree
+		return "", "", errNoReadme
+	}
+	if err != nil {
+		return "", "", err
+	}
+	found := ""
+	for _, name := range files {
+		if rank := readmeRank(name); rank >= 0 && (found == "" || rank < readmeRank(found)) {
+			found = name
+		}
+	}
+	if found == "" {
+		return "", "", errNoReadme
+	}
+	return s.getFile(ctx, repo, found)
+}
+
+func (s *giteaSource) file(ctx context.Context, repo *forgeRepo, path string) (string, error) {
+	content, _, err := s.getFile(ctx, repo, path)
+	return content, err
+}
+
+// contentsPath is the contents API endpoint of path in repo
+func (s *giteaSource) contentsPath(repo *forgeRepo, path string) string {
+	escaped := ""
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			escaped += "/" + url.PathEscape(segment)
+		}
+	}
+	return fmt.Sprintf("repos/%s/%s/contents%s", url.PathEscape(repo.Owner), url.PathEscape(repo.Name), escaped)
+}
+
+// getFile returns the content and blob SHA of the file at path on the default branch
+func (s *giteaSource) getFile(ctx context.Context, repo *forgeRepo, path string) (string, string, error) {
+	var file giteaContent
+	if _, err := s.client.get(ctx, s.contentsPath(repo, path), url.Values{"ref": {repo.Branch}}, &file); err != nil {
+		return "", "", err
+	}
+	if file.Encoding != "base64" {
+		return file.Content, file.SHA, nil
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return string(content), file.SHA, nil
+}
+
+func (s *giteaSource) list(ctx context.Context, repo *forgeRepo, dir string) ([]string, []string, error) {
+	endpoint := s.contentsPath(repo, dir)
+	if dir == "" {
+		endpoint += "/"
+	}
+	var entries []giteaContent
+	if _, err := s.client.get(ctx, endpoint, url.Values{"ref": {repo.Branch}}, &entries); err != nil {
+		return nil, nil, err
+	}
+	var files, dirs []string
+	for _, entry := range entries {
+		switch entry.Type {
+		case "file":
+			files = append(files, entry.Name)
+		case "dir":
+			dirs = append(dirs, entry.Name)
+		}
+	}
+	return files, dirs, nil
+}
+
+// languages reads the bytes of code per language
+func (s *giteaSource) languages(ctx context.Context, repo *forgeRepo, md *RepoMetadata) error {
+	var languages map[string]int
+	if _, err := s.client.get(ctx, fmt.Sprintf("repos/%s/%s/languages", url.PathEscape(repo.Owner), url.PathEscape(repo.Name)), nil, &languages); err != nil {
+		return err
+	}
+	md.Languages = languages
+	return nil
+}
@@ -3,10 +3,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,138 +20,467 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// githubRequestTimeout bounds a single GitHub HTTP request
+const githubRequestTimeout = 60 * time.Second
+
+// GitHub API backends used to fetch READMEs
+const (
+	githubBackendREST    = "rest"
+	githubBackendGraphQL = "graphql"
+)
+
+// defaultReadmesDir is where fetched READMEs and the sync index are stored by default
+const defaultReadmesDir = "readmes"
+
+// FetchStartedMsg is sent once the repositories to process are known
+type FetchStartedMsg struct {
+	Total int
+}
+
+// ReadmeFetchedMsg is sent after each repository has been processed
+type ReadmeFetchedMsg struct {
+	Name    string
+	Outcome string // One of the sync* outcomes, empty on error
+	Err     error
+}
+
 // FetchCompleteMsg is sent when all READMEs have been processed
-type FetchCompleteMsg struct{}
-
-// fetchGitHubREADMEs fetches README files from your GitHub repositories
-func (m *model) fetchGitHubREADMEs() tea.Cmd {
-	return func() tea.Msg {
-		m.addLog("Starting to fetch GitHub READMEs.")
-		token := os.Getenv("GITHUB_TOKEN")
-		if token == "" {
-			errMsg := "GITHUB_TOKEN environment variable not set"
-			m.addLog(errMsg)
-			return fmt.Errorf(errMsg)
+type FetchCompleteMsg struct {
+	Sources       []string                 // Sources synced; READMEs from others are kept
+	FailedSources []string                 // Sources that could not be synced
+	Readmes       map[string]string        // README contents by name
+	Metadata      map[string]*RepoMetadata // Repository metadata by README name
+	ReadmeSources map[string]string        // Source of each README by name, as stored in the sync index
+	Contributions *ContributionHistory     // Mined activity; nil when disabled or failed
+	Names         []string                 // Sorted README names
+	Fetched       int
+	Unchanged     int
+	Failed        int
+}
+
+// GitHubConfig controls which repositories are fetched from GitHub
+type GitHubConfig struct {
+	Visibility      string   `yaml:"visibility"`       // all, public or private
+	Affiliation     []string `yaml:"affiliation"`      // owner, collaborator and/or organization_member
+	IncludeForks    bool     `yaml:"include_forks"`    // Include forked repositories
+	IncludeArchived bool     `yaml:"include_archived"` // Include archived repositories
+	Orgs            []string `yaml:"orgs"`             // Organizations whose repositories are listed explicitly
+	Workers         int      `yaml:"workers"`          // Concurrent README downloads
+	MaxRetries      int      `yaml:"max_retries"`      // Retries for server errors and rate limits
+	Contributions   bool     `yaml:"contributions"`    // Mine commits, pull requests and reviews after fetching
+	Stack           bool     `yaml:"stack"`            // Analyze manifests for the tech stack
+	Backend         string   `yaml:"backend"`          // rest, or graphql to fetch repositories in batches
+	BaseURL         string   `yaml:"base_url"`         // GitHub Enterprise Server API URL; empty for github.com
+	UploadURL       string   `yaml:"upload_url"`       // GitHub Enterprise Server upload URL; defaults to base_url
+	CABundle        string   `yaml:"ca_bundle"`        // PEM file of extra trusted certificate authorities
+}
+
+// defaultGitHubConfig lists every repository owned by the authenticated user
+func defaultGitHubConfig() GitHubConfig {
+	return GitHubConfig{
+		Visibility:      "all",
+		Affiliation:     []string{"owner"},
+		IncludeForks:    true,
+		IncludeArchived: true,
+		Workers:         4,
+		MaxRetries:      5,
+		Contributions:   true,
+		Stack:           true,
+		Backend:         githubBackendREST,
+	}
+}
+
+// applyEnv applies AMALGIA_GITHUB_* environment overrides
+func (cfg *GitHubConfig) applyEnv() error {
+	if value := os.Getenv("AMALGIA_GITHUB_VISIBILITY"); value != "" {
+		cfg.Visibility = value
+	}
+	if value := os.Getenv("AMALGIA_GITHUB_AFFILIATION"); value != "" {
+		cfg.Affiliation = splitList(value)
+	}
+	if value := os.Getenv("AMALGIA_GITHUB_ORGS"); value != "" {
+		cfg.Orgs = splitList(value)
+	}
+	if value := os.Getenv("AMALGIA_GITHUB_BACKEND"); value != "" {
+		cfg.Backend = value
+	}
+	stringVars := map[string]*string{
+		"AMALGIA_GITHUB_BASE_URL":   &cfg.BaseURL,
+		"AMALGIA_GITHUB_UPLOAD_URL": &cfg.UploadURL,
+		"AMALGIA_GITHUB_CA_BUNDLE":  &cfg.CABundle,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
 		}
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
+	boolVars := map[string]*bool{
+		"AMALGIA_GITHUB_INCLUDE_FORKS":    &cfg.IncludeForks,
+		"AMALGIA_GITHUB_INCLUDE_ARCHIVED": &cfg.IncludeArchived,
+		"AMALGIA_GITHUB_CONTRIBUTIONS":    &cfg.Contributions,
+		"AMALGIA_GITHUB_STACK":            &cfg.Stack,
+	}
+	for name, target := range boolVars {
+		if value := os.Getenv(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			*target = b
+		}
+	}
 
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		tc := oauth2.NewClient(ctx, ts)
-		client := github.NewClient(tc)
+	intVars := map[string]*int{
+		"AMALGIA_GITHUB_WORKERS":     &cfg.Workers,
+		"AMALGIA_GITHUB_MAX_RETRIES": &cfg.MaxRetries,
+	}
+	for name, target := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			*target = n
+		}
+	}
 
-		user, _, err := client.Users.Get(ctx, "")
-		if err != nil {
-			errMsg := fmt.Sprintf("Error getting user: %v", err)
-			m.addLog(errMsg)
-			return fmt.Errorf(errMsg)
+	return nil
+}
+
+// validate checks the values GitHub accepts for visibility and affiliation
+func (cfg GitHubConfig) validate() error {
+	switch cfg.Visibility {
+	case "all", "public", "private":
+	default:
+		return fmt.Errorf("invalid GitHub visibility %q (expected all, public or private)", cfg.Visibility)
+	}
+	for _, affiliation := range cfg.Affiliation {
+		switch affiliation {
+		case "owner", "collaborator", "organization_member":
+		default:
+			return fmt.Errorf("invalid GitHub affiliation %q (expected owner, collaborator or organization_member)", affiliation)
+		}
+	}
+	switch cfg.Backend {
+	case githubBackendREST, githubBackendGraphQL:
+	default:
+		return fmt.Errorf("invalid GitHub backend %q (expected %s or %s)", cfg.Backend, githubBackendREST, githubBackendGraphQL)
+	}
+	for _, setting := range []struct{ name, value string }{{"base URL", cfg.BaseURL}, {"upload URL", cfg.UploadURL}} {
+		if setting.value == "" {
+			continue
+		}
+		if u, err := url.Parse(setting.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid GitHub %s %q (expected an http or https URL)", setting.name, setting.value)
 		}
+	}
+	if cfg.UploadURL != "" && cfg.BaseURL == "" {
+		return fmt.Errorf("GitHub upload URL is set without a base URL")
+	}
+	if cfg.Workers < 1 {
+		return fmt.Errorf("GitHub workers must be at least 1, got %d", cfg.Workers)
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("GitHub max retries must not be negative, got %d", cfg.MaxRetries)
+	}
+	return nil
+}
+
+// includes reports whether a listed repository passes the fork and archive filters
+func (cfg GitHubConfig) includes(repo *github.Repository) bool {
+	if repo.GetFork() && !cfg.IncludeForks {
+		return false
+	}
+	if repo.GetArchived() && !cfg.IncludeArchived {
+		return false
+	}
+	return true
+}
 
-		readmesDir := "readmes"
-		if err := os.MkdirAll(readmesDir, os.ModePerm); err != nil {
-			errMsg := fmt.Sprintf("Failed to create directory '%s': %v", readmesDir, err)
-			m.addLog(errMsg)
-			return fmt.Errorf(errMsg)
+// listRepositories pages through the user's repositories and those of the configured orgs
+func listRepositories(ctx context.Context, client *github.Client, retrier *githubRetrier, cfg GitHubConfig) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	seen := make(map[string]bool)
+	add := func(page []*github.Repository) {
+		for _, repo := range page {
+			if seen[repo.GetFullName()] || !cfg.includes(repo) {
+				continue
+			}
+			seen[repo.GetFullName()] = true
+			repos = append(repos, repo)
 		}
+	}
 
-		repos, _, err := client.Repositories.List(ctx, "", &github.RepositoryListOptions{
-			Visibility:  "public",
-			Affiliation: "owner",
+	opts := &github.RepositoryListOptions{
+		Visibility:  cfg.Visibility,
+		Affiliation: strings.Join(cfg.Affiliation, ","),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var page []*github.Repository
+		resp, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+			page, resp, err = client.Repositories.List(ctx, "", opts)
+			return resp, err
 		})
 		if err != nil {
-			errMsg := fmt.Sprintf("Error listing repositories: %v", err)
-			m.addLog(errMsg)
-			return fmt.Errorf(errMsg)
+			return nil, err
 		}
+		add(page)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
 
-		m.totalRepos = len(repos)
-		m.fetchedCount = 0
-		m.failedCount = 0
+	for _, org := range cfg.Orgs {
+		orgOpts := &github.RepositoryListByOrgOptions{
+			Type:        cfg.Visibility,
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+		for {
+			var page []*github.Repository
+			resp, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+				page, resp, err = client.Repositories.ListByOrg(ctx, org, orgOpts)
+				return resp, err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("listing repositories of %s: %v", org, err)
+			}
+			add(page)
+			if resp.NextPage == 0 {
+				break
+			}
+			orgOpts.Page = resp.NextPage
+		}
+	}
 
-		readmeContents := make(map[string]string)
-		var readmeNames []string
-		var mu sync.Mutex
-		var wg sync.WaitGroup
+	return repos, nil
+}
 
-		progressChan := make(chan tea.Msg)
+// readmeKey names a repository's README, qualifying repositories the user does not own
+func readmeKey(repo *github.Repository, login string) string {
+	if owner := repo.GetOwner().GetLogin(); owner != "" && owner != login {
+		return owner + "/" + repo.GetName()
+	}
+	return repo.GetName()
+}
 
-		// Goroutines to fetch READMEs concurrently
-		for _, repo := range repos {
-			wg.Add(1)
-			go func(repo *github.Repository) {
-				defer wg.Done()
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					readme, resp, err := client.Repositories.GetReadme(ctx, *user.Login, *repo.Name, nil)
-					if err != nil {
-						if resp != nil && resp.StatusCode == http.StatusNotFound {
-							// No README found; skip silently
-							progressChan <- FetchProgressMsg{}
-							return
-						}
-						errMsg := fmt.Sprintf("Error fetching README for %s: %v", *repo.Name, err)
-						m.addLog(errMsg)
-						mu.Lock()
-						m.failedCount++
-						mu.Unlock()
-						progressChan <- FetchProgressMsg{}
-						return
-					}
+// readmeFilename is the file a README is saved to inside the readmes directory
+func readmeFilename(key string) string {
+	return fmt.Sprintf("%s_README.md", strings.ReplaceAll(key, "/", "__"))
+}
 
-					content, err := readme.GetContent()
-					if err != nil {
-						errMsg := fmt.Sprintf("Error decoding README for %s: %v", *repo.Name, err)
-						m.addLog(errMsg)
-						mu.Lock()
-						m.failedCount++
-						mu.Unlock()
-						progressChan <- FetchProgressMsg{}
-						return
-					}
+// newGitHubClient creates a client for github.com, or for the GitHub
+// Enterprise Server at cfg.BaseURL, trusting cfg.CABundle in addition to the
+// system certificates
+func newGitHubClient(ctx context.Context, cfg GitHubConfig, token string) (*github.Client, error) {
+	transport, err := newTransport(cfg.CABundle)
+	if err != nil {
+		return nil, err
+	}
 
-					filename := filepath.Join(readmesDir, fmt.Sprintf("%s_README.md", *repo.Name))
-					err = os.WriteFile(filename, []byte(content), 0600)
-					if err != nil {
-						errMsg := fmt.Sprintf("Error writing README for %s to file: %v", *repo.Name, err)
-						m.addLog(errMsg)
-						mu.Lock()
-						m.failedCount++
-						mu.Unlock()
-						progressChan <- FetchProgressMsg{}
-						return
-					}
+	// oauth2 builds on the HTTP client stored in the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	tc := oauth2.NewClient(ctx, ts)
+	tc.Transport = activeCassette.Transport(tc.Transport)
+	tc.Timeout = githubRequestTimeout
 
-					mu.Lock()
-					readmeContents[*repo.Name] = content
-					readmeNames = append(readmeNames, *repo.Name)
-					m.fetchedCount++
-					mu.Unlock()
+	if cfg.BaseURL == "" {
+		return github.NewClient(tc), nil
+	}
+	uploadURL := cfg.UploadURL
+	if uploadURL == "" {
+		uploadURL = cfg.BaseURL
+	}
+	return github.NewEnterpriseClient(cfg.BaseURL, uploadURL, tc)
+}
 
-					m.addLog(fmt.Sprintf("Fetched README for repository: %s", *repo.Name))
-					progressChan <- FetchProgressMsg{}
-				}
-			}(repo)
-		}
+// newTransport is the default HTTP transport, trusting the certificates in
+// caBundle in addition to the system ones
+func newTransport(caBundle string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundle == "" {
+		return transport, nil
+	}
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caBundle)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
 
-		// Wait for all fetches to complete
-		go func() {
-			wg.Wait()
-			close(progressChan)
-		}()
+// fetchREADMEs syncs the READMEs of all configured repositories. Progress is
+// reported through send, and the final README set is returned to the caller.
+func fetchREADMEs(ctx context.Context, cfg GitHubConfig, readmesDir string, send func(tea.Msg)) (FetchCompleteMsg, error) {
+	logf := func(format string, args ...interface{}) {
+		send(LogMsg(fmt.Sprintf(format, args...)))
+	}
+	fail := func(format string, args ...interface{}) (FetchCompleteMsg, error) {
+		errMsg := fmt.Sprintf(format, args...)
+		send(LogMsg(errMsg))
+		return FetchCompleteMsg{}, fmt.Errorf(errMsg)
+	}
+
+	logf("Starting to fetch GitHub READMEs.")
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && !activeCassette.replaying() {
+		return fail("GITHUB_TOKEN environment variable not set")
+	}
+
+	client, err := newGitHubClient(ctx, cfg, token)
+	if err != nil {
+		return fail("Error creating GitHub client: %v", err)
+	}
+
+	retrier := newGitHubRetrier(cfg, func(msg RateLimitMsg) {
+		send(msg)
+	})
+
+	var user *github.User
+	_, err = retrier.do(ctx, func() (resp *github.Response, err error) {
+		user, resp, err = client.Users.Get(ctx, "")
+		return resp, err
+	})
+	if err != nil {
+		return fail("Error getting user: %v", err)
+	}
 
-		// Process progress updates
-		for msg := range progressChan {
-			// Send message to the program
-			m.program.Send(msg)
+	if err := os.MkdirAll(readmesDir, os.ModePerm); err != nil {
+		return fail("Failed to create directory '%s': %v", readmesDir, err)
+	}
+
+	index, err := loadSyncIndex(readmesDir)
+	if err != nil {
+		return fail("Error loading sync index: %v", err)
+	}
+
+	results := newReadmeResults(readmesDir, index, send)
+	if cfg.Backend == githubBackendGraphQL {
+		err = syncGraphQL(ctx, client, retrier, cfg, user.GetLogin(), readmesDir, results)
+	} else {
+		err = syncREST(ctx, client, retrier, cfg, user.GetLogin(), readmesDir, results)
+	}
+	if err != nil {
+		return fail("Error listing repositories: %v", err)
+	}
+
+	// Keep the READMEs completed before a cancellation, but only prune
+	// repositories after a run that saw every one of them
+	if ctx.Err() == nil {
+		for _, name := range pruneSyncIndex(readmesDir, index, results.listed, sourceGitHub) {
+			logf("Removed README for deleted repository: %s", name)
+		}
+	}
+	if err := index.save(readmesDir); err != nil {
+		logf("Error saving sync index: %v", err)
+	}
+	if ctx.Err() != nil {
+		logf("README fetch cancelled after %d of %d repositories.", results.fetched+results.unchanged, results.total)
+		return FetchCompleteMsg{}, ctx.Err()
+	}
+	logf("README sync finished: %d fetched, %d unchanged, %d failed.", results.fetched, results.unchanged, results.failed)
+
+	// Contribution history is extra evidence; the READMEs are kept if mining fails
+	var history *ContributionHistory
+	if cfg.Contributions {
+		logf("Mining contribution history for %s.", user.GetLogin())
+		var owned []string
+		for _, name := range results.names {
+			entry := index.Entries[name]
+			if owner, _, _ := strings.Cut(entry.FullName, "/"); entry.source() == sourceGitHub && strings.EqualFold(owner, user.GetLogin()) {
+				owned = append(owned, entry.FullName)
+			}
 		}
+		history, err = mineContributions(ctx, client, retrier, user.GetLogin(), owned)
+		if ctx.Err() != nil {
+			return FetchCompleteMsg{}, ctx.Err()
+		}
+		if err != nil {
+			logf("Error mining contribution history: %v", err)
+		} else if err := history.save(readmesDir); err != nil {
+			logf("Error saving contribution history: %v", err)
+		} else {
+			logf("Found contributions to %d repositories.", len(history.Repos))
+			if history.Truncated {
+				logf("Contribution search hit GitHub's 1000 result limit; counts outside your own repositories are lower bounds.")
+			}
+		}
+	}
 
-		// After all goroutines are done, send a fetch complete message
-		m.readmes = readmeContents
-		m.readmeList = readmeNames
+	sort.Strings(results.names)
+	return FetchCompleteMsg{
+		Sources:       []string{sourceGitHub},
+		Readmes:       results.contents,
+		Metadata:      results.metadata,
+		ReadmeSources: results.sources,
+		Contributions: history,
+		Names:         results.names,
+		Fetched:       results.fetched,
+		Unchanged:     results.unchanged,
+		Failed:        results.failed,
+	}, nil
+}
 
-		return FetchCompleteMsg{}
+// syncREST lists the repositories through the REST API and downloads their
+// READMEs and metadata with a bounded worker pool, one repository at a time
+func syncREST(ctx context.Context, client *github.Client, retrier *githubRetrier, cfg GitHubConfig, login, readmesDir string, results *readmeResults) error {
+	repos, err := listRepositories(ctx, client, retrier, cfg)
+	if err != nil {
+		return err
 	}
+	results.start(len(repos))
+
+	// Queue every repository for the bounded worker pool
+	type readmeJob struct {
+		repo  *github.Repository
+		name  string
+		entry *SyncEntry
+	}
+	jobs := make(chan readmeJob, len(repos))
+	for _, repo := range repos {
+		name := readmeKey(repo, login)
+		results.list(name)
+		jobs <- readmeJob{repo: repo, name: name, entry: results.index.Entries[name]}
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+
+				content, updated, outcome, err := syncReadme(ctx, client, retrier, readmesDir, job.name, job.repo, job.entry)
+				if err == nil && outcome != syncMissing {
+					metadata, err := fetchRepoMetadata(ctx, client, retrier, job.repo, job.entry, cfg.Stack)
+					if err != nil && ctx.Err() == nil {
+						// The README is still worth keeping without the language breakdown
+						results.logf("Error fetching metadata for %s: %v", job.name, err)
+					}
+					copied := *updated
+					copied.Metadata = metadata
+					updated = &copied
+				}
+				results.record(job.name, content, updated, outcome, err)
+			}
+		}()
+	}
+
+	// Wait for all fetches to complete
+	wg.Wait()
+	return nil
 }
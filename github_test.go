@@ -0,0 +1,201 @@
+// Filename: github_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestListRepositories(t *testing.T) {
+	repo := func(fullName string, fork, archived bool) map[string]interface{} {
+		return map[string]interface{}{"full_name": fullName, "fork": fork, "archived": archived}
+	}
+	// Two pages of the user's repositories and one of the organization's,
+	// which repeats a repository the user can see too
+	pages := map[string][][]map[string]interface{}{
+		"/api/v3/user/repos": {
+			{repo("octo/app", false, false), repo("octo/fork", true, false)},
+			{repo("octo/old", false, true), repo("acme/shared", false, false)},
+		},
+		"/api/v3/orgs/acme/repos": {
+			{repo("acme/shared", false, false), repo("acme/api", false, false), repo("acme/fork", true, false)},
+		},
+	}
+	queries := make(map[string][]url.Values)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listed, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		queries[r.URL.Path] = append(queries[r.URL.Path], r.URL.Query())
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		if page < len(listed) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next"`, server.URL, r.URL.Path, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(listed[page-1])
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultGitHubConfig()
+	cfg.Visibility = "public"
+	cfg.Affiliation = []string{"owner", "organization_member"}
+	cfg.Orgs = []string{"acme"}
+	cfg.IncludeForks = false
+	cfg.IncludeArchived = false
+
+	repos, err := listRepositories(context.Background(), client, newTestRetrier(0, nil), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.GetFullName())
+	}
+	if want := []string{"octo/app", "acme/shared", "acme/api"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+
+	userQueries := queries["/api/v3/user/repos"]
+	if len(userQueries) != 2 {
+		t.Fatalf("got %d requests for the user's repositories, want both pages", len(userQueries))
+	}
+	if q := userQueries[0]; q.Get("visibility") != "public" || q.Get("affiliation") != "owner,organization_member" || q.Get("per_page") != "100" {
+		t.Errorf("user query = %v", q)
+	}
+	if got := userQueries[1].Get("page"); got != "2" {
+		t.Errorf("second request for page %q, want 2", got)
+	}
+	if orgQueries := queries["/api/v3/orgs/acme/repos"]; len(orgQueries) != 1 || orgQueries[0].Get("type") != "public" {
+		t.Errorf("organization queries = %v", orgQueries)
+	}
+
+	// Forks and archived repositories are kept by default
+	cfg = defaultGitHubConfig()
+	queries = make(map[string][]url.Values)
+	repos, err = listRepositories(context.Background(), client, newTestRetrier(0, nil), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 4 {
+		t.Errorf("got %d repositories, want all 4 of the user", len(repos))
+	}
+	if len(queries["/api/v3/orgs/acme/repos"]) != 0 {
+		t.Error("an organization that is not configured was listed")
+	}
+}
+
+func TestListRepositoriesReportsOrganization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/user/repos" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, "[]")
+			return
+		}
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultGitHubConfig()
+	cfg.Orgs = []string{"missing"}
+	_, err = listRepositories(context.Background(), client, newTestRetrier(0, nil), cfg)
+	if err == nil || !strings.Contains(err.Error(), "listing repositories of missing") {
+		t.Errorf("got %v, want the organization named", err)
+	}
+}
+
+func TestNewGitHubClientCABundle(t *testing.T) {
+	var auth string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"login": "octo"}`)
+	}))
+	// The handshake rejected without the bundle is expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultGitHubConfig()
+	cfg.BaseURL = server.URL + "/api/v3/"
+	cfg.MaxRetries = 0
+
+	// The test server's certificate is only trusted through the bundle
+	client, err := newGitHubClient(context.Background(), cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Users.Get(context.Background(), ""); err == nil {
+		t.Error("the server was trusted without the CA bundle")
+	}
+
+	cfg.CABundle = bundle
+	client, err = newGitHubClient(context.Background(), cfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := client.Users.Get(context.Background(), "")
+	if err != nil || user.GetLogin() != "octo" {
+		t.Fatalf("got %v, %v, want octo", user, err)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want the token", auth)
+	}
+
+	// GitLab and Gitea share the transport
+	forgeCfg := defaultForgeConfig(server.URL, "FORGE_TEST_TOKEN")
+	forgeCfg.CABundle = bundle
+	src, err := newGiteaSource(forgeCfg, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login, err := src.login(context.Background()); err != nil {
+		t.Errorf("got %q, %v through the CA bundle", login, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for bundle, want := range map[string]string{
+		filepath.Join(dir, "missing.pem"): "reading CA bundle",
+		invalid:                           "no certificates found in CA bundle",
+	} {
+		cfg.CABundle = bundle
+		if _, err := newGitHubClient(context.Background(), cfg, "token"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", filepath.Base(bundle), err, want)
+		}
+	}
+}
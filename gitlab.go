@@ -0,0 +1,205 @@
+// Filename: gitlab.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitLabPageSize is the number of projects per listing request, GitLab's maximum
+const gitLabPageSize = 100
+
+// gitLabSource reads projects from gitlab.com or a self-managed GitLab instance
+// through the REST API v4
+type gitLabSource struct {
+	client *forgeClient
+	owned  bool
+}
+
+// newGitLabSource creates a source authenticated with a personal access token
+func newGitLabSource(cfg ForgeConfig, token string) (*gitLabSource, error) {
+	client, err := newForgeClient(cfg, sourceGitLab, "/api/v4/", func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gitLabSource{client: client, owned: cfg.OwnedOnly}, nil
+}
+
+func (s *gitLabSource) login(ctx context.Context) (string, error) {
+	var user struct {
+		Username string `json:"username"`
+	}
+	if _, err := s.client.get(ctx, "user", nil, &user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// gitLabProject is the subset of a project listing entry that is synced
+type gitLabProject struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	Path              string    `json:"path"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Description       string    `json:"description"`
+	WebURL            string    `json:"web_url"`
+	ReadmeURL         string    `json:"readme_url"`
+	DefaultBranch     string    `json:"default_branch"`
+	Topics            []string  `json:"topics"`
+	TagList           []string  `json:"tag_list"` // Topics before GitLab 14.0
+	StarCount         int       `json:"star_count"`
+	ForksCount        int       `json:"forks_count"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	Archived          bool      `json:"archived"`
+	ForkedFrom        *struct{} `json:"forked_from_project"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+// repos pages through the projects the user owns, or is a member of
+func (s *gitLabSource) repos(ctx context.Context) ([]*forgeRepo, error) {
+	query := url.Values{
+		"per_page":   {strconv.Itoa(gitLabPageSize)},
+		"order_by":   {"id"},
+		"sort":       {"asc"},
+		"membership": {"true"},
+	}
+	if s.owned {
+		query.Set("owned", "true")
+	}
+
+	var repos []*forgeRepo
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var projects []gitLabProject
+		header, err := s.client.get(ctx, "projects", query, &projects)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			repos = append(repos, p.repo())
+		}
+		// X-Next-Page is left out for very large listings, so also stop at a short page
+		if header.Get("X-Next-Page") == "" || len(projects) < gitLabPageSize {
+			return repos, nil
+		}
+	}
+}
+
+// repo converts the listing entry
+func (p gitLabProject) repo() *forgeRepo {
+	topics := p.Topics
+	if len(topics) == 0 {
+		topics = p.TagList
+	}
+	repo := &forgeRepo{
+		ID:       strconv.Itoa(p.ID),
+		Owner:    p.Namespace.FullPath,
+		Name:     p.Path,
+		FullName: p.PathWithNamespace,
+		Branch:   p.DefaultBranch,
+		Fork:     p.ForkedFrom != nil,
+		Archived: p.Archived,
+		Metadata: &RepoMetadata{
+			Description: p.Description,
+			URL:         p.WebURL,
+			Topics:      topics,
+			Stars:       p.StarCount,
+			Forks:       p.ForksCount,
+			CreatedAt:   p.CreatedAt,
+			PushedAt:    p.LastActivityAt,
+		},
+	}
+	// readme_url is https://host/group/project/-/blob/<branch>/<path>
+	if _, path, found := strings.Cut(p.ReadmeURL, "/-/blob/"+p.DefaultBranch+"/"); found {
+		repo.Readme = path
+	}
+	return repo
+}
+
+func (s *gitLabSource) readme(ctx context.Context, repo *forgeRepo) (string, string, error) {
+	if repo.Readme == "" || repo.Branch == "" {
+		return "", "", errNoReadme
+	}
+	content, blobID, err := s.getFile(ctx, repo, repo.Readme)
+	if isNotFound(err) {
+		return "", "", errNoReadme
+	}
+	return content, blobID, err
+}
+
+func (s *gitLabSource) file(ctx context.Context, repo *forgeRepo, path string) (string, error) {
+	content, _, err := s.getFile(ctx, repo, path)
+	return content, err
+}
+
+// getFile returns the content and blob ID of the file at path on the default branch
+func (s *gitLabSource) getFile(ctx context.Context, repo *forgeRepo, path string) (string, string, error) {
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+		BlobID   string `json:"blob_id"`
+	}
+	endpoint := fmt.Sprintf("projects/%s/repository/files/%s", repo.ID, url.PathEscape(path))
+	if _, err := s.client.get(ctx, endpoint, url.Values{"ref": {repo.Branch}}, &file); err != nil {
+		return "", "", err
+	}
+	if file.Encoding != "base64" {
+		return file.Content, file.BlobID, nil
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return string(content), file.BlobID, nil
+}
+
+// list reads the first page of the repository tree, enough for the top-level
+// manifests and CI directories
+func (s *gitLabSource) list(ctx context.Context, repo *forgeRepo, dir string) ([]string, []string, error) {
+	if repo.Branch == "" {
+		// Projects without commits have no default branch
+		return nil, nil, nil
+	}
+	var entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"` // blob or tree
+	}
+	query := url.Values{"ref": {repo.Branch}, "per_page": {strconv.Itoa(gitLabPageSize)}}
+	if dir != "" {
+		query.Set("path", dir)
+	}
+	if _, err := s.client.get(ctx, fmt.Sprintf("projects/%s/repository/tree", repo.ID), query, &entries); err != nil {
+		return nil, nil, err
+	}
+	var files, dirs []string
+	for _, entry := range entries {
+		switch entry.Type {
+		case "blob":
+			files = append(files, entry.Name)
+		case "tree":
+			dirs = append(dirs, entry.Name)
+		}
+	}
+	return files, dirs, nil
+}
+
+// languages reads the percentages GitLab reports instead of sizes
+func (s *gitLabSource) languages(ctx context.Context, repo *forgeRepo, md *RepoMetadata) error {
+	var percentages map[string]float64
+	if _, err := s.client.get(ctx, fmt.Sprintf("projects/%s/languages", repo.ID), nil, &percentages); err != nil {
+		return err
+	}
+	md.LanguageShares = percentages
+	return nil
+}
@@ -0,0 +1,463 @@
+// Filename: graphql.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// graphQLPageSize is the number of repositories, READMEs included, per query
+const graphQLPageSize = 50
+
+// graphQLReadmeAliases are the README paths tried on each repository's default
+// branch, in order of preference
+var graphQLReadmeAliases = []struct {
+	alias, path string
+}{
+	{"readmeMd", "README.md"},
+	{"readmeLower", "readme.md"},
+	{"readmeTitle", "Readme.md"},
+	{"readmeMarkdown", "README.markdown"},
+	{"readmeRst", "README.rst"},
+	{"readmeTxt", "README.txt"},
+	{"readmePlain", "README"},
+}
+
+// graphQLManifestPaths are the manifests and CI directories read from each
+// repository's default branch in the same query as its README, when the
+// stack is analyzed. They are aliased as manifest0, manifest1 and so on.
+var graphQLManifestPaths = append(append([]string{}, manifestFiles...), workflowDirs...)
+
+// graphQLObjectFields selects a blob, or the blobs of a tree
+const graphQLObjectFields = `__typename ... on Blob { byteSize text } ... on Tree { entries { name object { __typename ... on Blob { byteSize text } } } }`
+
+// graphQLRepositoryFields selects everything synced for a repository
+var graphQLRepositoryFields = func() string {
+	fields := `
+		name
+		nameWithOwner
+		owner { login }
+		url
+		description
+		homepageUrl
+		isFork
+		isArchived
+		stargazerCount
+		forkCount
+		createdAt
+		pushedAt
+		licenseInfo { spdxId name }
+		repositoryTopics(first: 20) { nodes { topic { name } } }
+		languages(first: 20, orderBy: {field: SIZE, direction: DESC}) { edges { size node { name } } }`
+	for _, readme := range graphQLReadmeAliases {
+		fields += fmt.Sprintf("\n\t\t%s: object(expression: \"HEAD:%s\") { ... on Blob { oid text isTruncated } }", readme.alias, readme.path)
+	}
+	for i, path := range graphQLManifestPaths {
+		fields += fmt.Sprintf("\n\t\tmanifest%d: object(expression: \"HEAD:%s\") @include(if: $stack) { %s }", i, path, graphQLObjectFields)
+	}
+	return fields
+}()
+
+// graphQLConnectionFields selects a page of a repository connection
+var graphQLConnectionFields = `(first: $pageSize, after: $cursor, privacy: $privacy, isFork: $isFork, orderBy: {field: NAME, direction: ASC}) {
+	totalCount
+	pageInfo { hasNextPage endCursor }
+	nodes {` + graphQLRepositoryFields + `
+	}
+}`
+
+// graphQLViewerQuery pages through the repositories of the authenticated user
+var graphQLViewerQuery = `query($pageSize: Int!, $stack: Boolean!, $cursor: String, $privacy: RepositoryPrivacy, $isFork: Boolean, $affiliations: [RepositoryAffiliation]) {
+	viewer {
+		repositories` + strings.Replace(graphQLConnectionFields, "privacy: $privacy,", "privacy: $privacy, affiliations: $affiliations, ownerAffiliations: $affiliations,", 1) + `
+	}
+}`
+
+// graphQLOrgQuery pages through the repositories of an organization
+var graphQLOrgQuery = `query($login: String!, $pageSize: Int!, $stack: Boolean!, $cursor: String, $privacy: RepositoryPrivacy, $isFork: Boolean) {
+	organization(login: $login) {
+		repositories` + graphQLConnectionFields + `
+	}
+}`
+
+// graphQLBlob is a README looked up by expression; nil when the path does not exist
+type graphQLBlob struct {
+	OID         string  `json:"oid"`
+	Text        *string `json:"text"` // nil for binary files
+	IsTruncated bool    `json:"isTruncated"`
+}
+
+// graphQLObject is a manifest or CI directory looked up by expression; nil
+// when the path does not exist
+type graphQLObject struct {
+	Typename string  `json:"__typename"`
+	ByteSize int     `json:"byteSize"`
+	Text     *string `json:"text"` // nil for binary files
+	Entries  []struct {
+		Name   string
+		Object *graphQLObject
+	}
+}
+
+// content returns the text of a blob, or nothing for binary and generated files
+func (o *graphQLObject) content() string {
+	if o.Text == nil || o.ByteSize > maxManifestSize {
+		return ""
+	}
+	return *o.Text
+}
+
+// graphQLRepository is a repository node of graphQLRepositoryFields
+type graphQLRepository struct {
+	Name           string
+	NameWithOwner  string
+	Owner          struct{ Login string }
+	URL            string
+	Description    string
+	HomepageURL    string
+	IsFork         bool
+	IsArchived     bool
+	StargazerCount int
+	ForkCount      int
+	CreatedAt      time.Time
+	PushedAt       time.Time
+	LicenseInfo    *struct {
+		SpdxID string
+		Name   string
+	}
+	RepositoryTopics struct {
+		Nodes []struct{ Topic struct{ Name string } }
+	}
+	Languages struct {
+		Edges []struct {
+			Size int
+			Node struct{ Name string }
+		}
+	}
+	Readmes   map[string]*graphQLBlob   `json:"-"`
+	Manifests map[string]*graphQLObject `json:"-"` // By path in graphQLManifestPaths
+}
+
+// UnmarshalJSON decodes the fixed fields and collects the README aliases
+func (r *graphQLRepository) UnmarshalJSON(data []byte) error {
+	type plain graphQLRepository
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Readmes = make(map[string]*graphQLBlob)
+	for _, readme := range graphQLReadmeAliases {
+		var blob *graphQLBlob
+		if value, ok := raw[readme.alias]; ok {
+			if err := json.Unmarshal(value, &blob); err != nil {
+				return err
+			}
+		}
+		r.Readmes[readme.alias] = blob
+	}
+	r.Manifests = make(map[string]*graphQLObject)
+	for i, path := range graphQLManifestPaths {
+		var object *graphQLObject
+		if value, ok := raw[fmt.Sprintf("manifest%d", i)]; ok {
+			if err := json.Unmarshal(value, &object); err != nil {
+				return err
+			}
+		}
+		if object != nil {
+			r.Manifests[path] = object
+		}
+	}
+	return nil
+}
+
+// manifestContents returns the manifests and CI workflows found in the
+// repository by path, as collectManifests does through REST
+func (r *graphQLRepository) manifestContents() map[string]string {
+	contents := make(map[string]string)
+	for path, object := range r.Manifests {
+		switch {
+		case object.Typename == "Blob" && isManifest(path):
+			contents[path] = object.content()
+		case object.Typename == "Tree" && contains(workflowDirs, path):
+			for _, entry := range object.Entries {
+				if entry.Object == nil || entry.Object.Typename != "Blob" {
+					continue
+				}
+				if strings.HasSuffix(entry.Name, ".yml") || strings.HasSuffix(entry.Name, ".yaml") {
+					contents[path+"/"+entry.Name] = entry.Object.content()
+				}
+			}
+		}
+	}
+	return contents
+}
+
+// readme returns the first README variant present in the repository, or nil
+func (r *graphQLRepository) readme() *graphQLBlob {
+	for _, readme := range graphQLReadmeAliases {
+		if blob := r.Readmes[readme.alias]; blob != nil && blob.Text != nil {
+			return blob
+		}
+	}
+	return nil
+}
+
+// restRepository is the subset of the REST representation the sync needs
+func (r *graphQLRepository) restRepository() *github.Repository {
+	return &github.Repository{
+		Name:     github.String(r.Name),
+		FullName: github.String(r.NameWithOwner),
+		Owner:    &github.User{Login: github.String(r.Owner.Login)},
+		PushedAt: &github.Timestamp{Time: r.PushedAt},
+	}
+}
+
+// metadata converts the node into the metadata stored in the sync index
+func (r *graphQLRepository) metadata() *RepoMetadata {
+	md := &RepoMetadata{
+		Description: r.Description,
+		URL:         r.URL,
+		Homepage:    r.HomepageURL,
+		Stars:       r.StargazerCount,
+		Forks:       r.ForkCount,
+		CreatedAt:   r.CreatedAt,
+		PushedAt:    r.PushedAt,
+		Languages:   make(map[string]int),
+	}
+	if r.LicenseInfo != nil {
+		md.License = r.LicenseInfo.SpdxID
+		if md.License == "" || md.License == "NOASSERTION" {
+			md.License = r.LicenseInfo.Name
+		}
+	}
+	for _, node := range r.RepositoryTopics.Nodes {
+		md.Topics = append(md.Topics, node.Topic.Name)
+	}
+	for _, edge := range r.Languages.Edges {
+		md.Languages[edge.Node.Name] = edge.Size
+	}
+	return md
+}
+
+// graphQLConnection is a page of repositories
+type graphQLConnection struct {
+	TotalCount int
+	PageInfo   struct {
+		HasNextPage bool
+		EndCursor   string
+	}
+	Nodes []*graphQLRepository
+}
+
+// graphQLError is an entry of the errors array of a GraphQL response
+type graphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// queryGraphQL runs a GraphQL query through the REST client, so the same
+// authentication, cassette, rate-limit reporting and retries apply
+func queryGraphQL(ctx context.Context, client *github.Client, retrier *githubRetrier, query string, variables map[string]interface{}, data interface{}) error {
+	body := map[string]interface{}{"query": query, "variables": variables}
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	_, err := retrier.do(ctx, func() (*github.Response, error) {
+		// The request body is consumed by each attempt. GraphQL is served at
+		// /graphql on github.com and at /api/graphql next to /api/v3 on GHES.
+		req, err := client.NewRequest(http.MethodPost, "../graphql", body)
+		if err != nil {
+			return nil, err
+		}
+		result.Data, result.Errors = nil, nil
+		resp, err := client.Do(ctx, req, &result)
+		if err != nil {
+			return resp, err
+		}
+		for _, e := range result.Errors {
+			// The GraphQL rate limit is reported with a 200 status
+			if e.Type == "RATE_LIMITED" {
+				return resp, &github.RateLimitError{Rate: resp.Rate, Response: resp.Response, Message: e.Message}
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL error: %s", strings.Join(messages, "; "))
+	}
+	return json.Unmarshal(result.Data, data)
+}
+
+// syncGraphQL pages through the repositories with GraphQL, getting READMEs,
+// languages, topics and manifests in the same queries instead of several
+// calls per repository
+func syncGraphQL(ctx context.Context, client *github.Client, retrier *githubRetrier, cfg GitHubConfig, login, readmesDir string, results *readmeResults) error {
+	variables := map[string]interface{}{"pageSize": graphQLPageSize, "stack": cfg.Stack}
+	switch cfg.Visibility {
+	case "public", "private":
+		variables["privacy"] = strings.ToUpper(cfg.Visibility)
+	}
+	if !cfg.IncludeForks {
+		variables["isFork"] = false
+	}
+
+	seen := make(map[string]bool)
+	page := func(query string, variables map[string]interface{}, connection func(data json.RawMessage) (*graphQLConnection, error)) error {
+		started := false
+		for cursor := ""; ; {
+			variables["cursor"] = nil
+			if cursor != "" {
+				variables["cursor"] = cursor
+			}
+			var data json.RawMessage
+			if err := queryGraphQL(ctx, client, retrier, query, variables, &data); err != nil {
+				return err
+			}
+			repos, err := connection(data)
+			if err != nil {
+				return fmt.Errorf("decoding GraphQL response: %v", err)
+			}
+			if !started {
+				results.start(repos.TotalCount)
+				started = true
+			}
+
+			for _, repo := range repos.Nodes {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if seen[repo.NameWithOwner] || (repo.IsArchived && !cfg.IncludeArchived) {
+					results.skip()
+					continue
+				}
+				seen[repo.NameWithOwner] = true
+				syncGraphQLRepository(ctx, client, retrier, login, readmesDir, repo, cfg.Stack, results)
+			}
+
+			if !repos.PageInfo.HasNextPage {
+				return nil
+			}
+			cursor = repos.PageInfo.EndCursor
+		}
+	}
+
+	var affiliations []string
+	for _, affiliation := range cfg.Affiliation {
+		affiliations = append(affiliations, strings.ToUpper(affiliation))
+	}
+	viewerVariables := copyVariables(variables)
+	viewerVariables["affiliations"] = affiliations
+	err := page(graphQLViewerQuery, viewerVariables, func(data json.RawMessage) (*graphQLConnection, error) {
+		var viewer struct {
+			Viewer struct{ Repositories graphQLConnection }
+		}
+		err := json.Unmarshal(data, &viewer)
+		return &viewer.Viewer.Repositories, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, org := range cfg.Orgs {
+		orgVariables := copyVariables(variables)
+		orgVariables["login"] = org
+		err := page(graphQLOrgQuery, orgVariables, func(data json.RawMessage) (*graphQLConnection, error) {
+			var organization struct {
+				Organization *struct{ Repositories graphQLConnection }
+			}
+			if err := json.Unmarshal(data, &organization); err != nil {
+				return nil, err
+			}
+			if organization.Organization == nil {
+				return nil, fmt.Errorf("organization %s not found", org)
+			}
+			return &organization.Organization.Repositories, nil
+		})
+		if err != nil {
+			return fmt.Errorf("listing repositories of %s: %v", org, err)
+		}
+	}
+	return nil
+}
+
+// syncGraphQLRepository stores the README and metadata of a repository from a
+// GraphQL page. A README is only rewritten when its blob changed.
+func syncGraphQLRepository(ctx context.Context, client *github.Client, retrier *githubRetrier, login, readmesDir string, repo *graphQLRepository, analyzeStack bool, results *readmeResults) {
+	name := readmeKey(repo.restRepository(), login)
+	results.list(name)
+	entry := results.index.Entries[name]
+
+	blob := repo.readme()
+	if blob == nil {
+		results.record(name, "", nil, syncMissing, nil)
+		return
+	}
+
+	metadata := repo.metadata()
+	if analyzeStack {
+		metadata.Stack = detectStack(repo.manifestContents())
+	}
+	if blob.IsTruncated {
+		// Large READMEs are cut off in GraphQL responses; download them in full
+		content, updated, outcome, err := syncReadme(ctx, client, retrier, readmesDir, name, repo.restRepository(), entry)
+		if err == nil && updated != nil {
+			copied := *updated
+			copied.Metadata = metadata
+			updated = &copied
+		}
+		results.record(name, content, updated, outcome, err)
+		return
+	}
+
+	content := *blob.Text
+	updated := &SyncEntry{
+		FullName:  repo.NameWithOwner,
+		File:      readmeFilename(name),
+		SHA:       blob.OID,
+		PushedAt:  repo.PushedAt,
+		FetchedAt: time.Now(),
+		Metadata:  metadata,
+	}
+	if entry != nil && entry.SHA == blob.OID {
+		if _, err := os.Stat(filepath.Join(readmesDir, entry.File)); err == nil {
+			updated.File, updated.ETag, updated.FetchedAt = entry.File, entry.ETag, entry.FetchedAt
+			results.record(name, content, updated, syncUnchanged, nil)
+			return
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(readmesDir, updated.File), []byte(content)); err != nil {
+		results.record(name, "", nil, "", fmt.Errorf("writing README to file: %v", err))
+		return
+	}
+	results.record(name, content, updated, syncFetched, nil)
+}
+
+// copyVariables returns a copy of GraphQL query variables
+func copyVariables(variables map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(variables))
+	for key, value := range variables {
+		copied[key] = value
+	}
+	return copied
+}
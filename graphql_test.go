@@ -0,0 +1,140 @@
+// Filename: graphql_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-github/v45/github"
+)
+
+func TestSyncGraphQLReadsManifestsInQuery(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/api/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Variables["stack"] != true {
+			t.Errorf("got variables %v (%v), want the stack included", request.Variables, err)
+		}
+		text := func(s string) map[string]interface{} {
+			return map[string]interface{}{"__typename": "Blob", "byteSize": len(s), "text": s}
+		}
+		node := map[string]interface{}{
+			"name":          "demo",
+			"nameWithOwner": "octo/demo",
+			"owner":         map[string]string{"login": "octo"},
+			"pushedAt":      "2024-05-01T10:00:00Z",
+			"createdAt":     "2024-01-01T10:00:00Z",
+			"readmeMd":      map[string]interface{}{"oid": "abc", "text": "# Demo\n"},
+			"manifest0":     text("module example.com/demo\n\ngo 1.20\n"),
+			fmt.Sprintf("manifest%d", len(manifestFiles)): map[string]interface{}{
+				"__typename": "Tree",
+				"entries": []map[string]interface{}{
+					{"name": "ci.yml", "object": text("jobs:\n  release:\n    steps:\n      - uses: goreleaser/goreleaser-action@v5\n")},
+					{"name": "notes.txt", "object": text("uses: hashicorp/setup-terraform")},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"viewer": map[string]interface{}{"repositories": map[string]interface{}{
+				"totalCount": 1,
+				"pageInfo":   map[string]interface{}{"hasNextPage": false},
+				"nodes":      []interface{}{node},
+			}},
+		}})
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultGitHubConfig()
+	cfg.Backend = githubBackendGraphQL
+	cfg.MaxRetries = 0
+	dir := t.TempDir()
+	results := newReadmeResults("", &SyncIndex{Entries: make(map[string]*SyncEntry)}, func(tea.Msg) {})
+	if err := syncGraphQL(context.Background(), client, newGitHubRetrier(cfg, func(RateLimitMsg) {}), cfg, "octo", dir, results); err != nil {
+		t.Fatalf("syncing: %v", err)
+	}
+
+	if len(paths) != 1 {
+		t.Errorf("got requests %v, want the single GraphQL query", paths)
+	}
+	md := results.metadata["demo"]
+	if md == nil {
+		t.Fatalf("no metadata synced for demo")
+	}
+	found := make(map[string]bool)
+	for _, evidence := range md.Stack {
+		found[evidence.Name] = true
+	}
+	if !found["Go"] || !found["GitHub Actions"] || !found["GoReleaser"] || found["Terraform"] {
+		t.Errorf("got stack %+v, want Go, GitHub Actions and GoReleaser from go.mod and the workflow only", md.Stack)
+	}
+}
+
+func TestGraphQLManifestContents(t *testing.T) {
+	alias := func(path string) string {
+		for i, p := range graphQLManifestPaths {
+			if p == path {
+				return fmt.Sprintf("manifest%d", i)
+			}
+		}
+		t.Fatalf("%s is not read through GraphQL", path)
+		return ""
+	}
+	blob := func(s string, size int) map[string]interface{} {
+		return map[string]interface{}{"__typename": "Blob", "byteSize": size, "text": s}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"name":            "demo",
+		alias("go.mod"):   blob("module example.com/demo\n", 24),
+		alias("Makefile"): nil,
+		// Binary and generated files are left empty
+		alias("Dockerfile"):   map[string]interface{}{"__typename": "Blob", "byteSize": 10, "text": nil},
+		alias("package.json"): blob("{}", maxManifestSize+1),
+		// A directory where a manifest file was expected is skipped
+		alias("Cargo.toml"): map[string]interface{}{"__typename": "Tree", "entries": []interface{}{}},
+		alias(".github/workflows"): map[string]interface{}{
+			"__typename": "Tree",
+			"entries": []map[string]interface{}{
+				{"name": "ci.yml", "object": blob("on: push\n", 9)},
+				{"name": "release.yaml", "object": blob("on: tag\n", 8)},
+				{"name": "README.md", "object": blob("# CI\n", 5)},
+				{"name": "shared", "object": map[string]interface{}{"__typename": "Tree"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repo graphQLRepository
+	if err := json.Unmarshal(data, &repo); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"go.mod":                         "module example.com/demo\n",
+		"Dockerfile":                     "",
+		"package.json":                   "",
+		".github/workflows/ci.yml":       "on: push\n",
+		".github/workflows/release.yaml": "on: tag\n",
+	}
+	if got := repo.manifestContents(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+// Filename: jsonresume.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonResumeSchema is the schema URL written to exported resumes
+const jsonResumeSchema = "https://raw.githubusercontent.com/jsonresume/resume-schema/v1.0.0/schema.json"
+
+// JSONResume is a resume in the jsonresume.org schema. Sections the profile has
+// no place for (awards, publications, languages, ...) are not represented.
+type JSONResume struct {
+	Schema       string                  `json:"$schema,omitempty"`
+	Basics       JSONResumeBasics        `json:"basics"`
+	Work         []JSONResumeWork        `json:"work,omitempty"`
+	Education    []JSONResumeEducation   `json:"education,omitempty"`
+	Skills       []JSONResumeSkill       `json:"skills,omitempty"`
+	Certificates []JSONResumeCertificate `json:"certificates,omitempty"`
+	Projects     []JSONResumeProject     `json:"projects,omitempty"`
+}
+
+// JSONResumeBasics is the basics section
+type JSONResumeBasics struct {
+	Name     string              `json:"name,omitempty"`
+	Label    string              `json:"label,omitempty"`
+	Email    string              `json:"email,omitempty"`
+	Phone    string              `json:"phone,omitempty"`
+	URL      string              `json:"url,omitempty"`
+	Summary  string              `json:"summary,omitempty"`
+	Location *JSONResumeLocation `json:"location,omitempty"`
+	Profiles []JSONResumeProfile `json:"profiles,omitempty"`
+}
+
+// JSONResumeLocation is basics.location
+type JSONResumeLocation struct {
+	Address     string `json:"address,omitempty"`
+	PostalCode  string `json:"postalCode,omitempty"`
+	City        string `json:"city,omitempty"`
+	CountryCode string `json:"countryCode,omitempty"`
+	Region      string `json:"region,omitempty"`
+}
+
+// JSONResumeProfile is a social network profile
+type JSONResumeProfile struct {
+	Network  string `json:"network,omitempty"`
+	Username string `json:"username,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// JSONResumeWork is an entry of the work section
+type JSONResumeWork struct {
+	Name       string   `json:"name,omitempty"`
+	Position   string   `json:"position,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	StartDate  string   `json:"startDate,omitempty"`
+	EndDate    string   `json:"endDate,omitempty"`
+	Summary    string   `json:"summary,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// JSONResumeEducation is an entry of the education section
+type JSONResumeEducation struct {
+	Institution string `json:"institution,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Area        string `json:"area,omitempty"`
+	StudyType   string `json:"studyType,omitempty"`
+	StartDate   string `json:"startDate,omitempty"`
+	EndDate     string `json:"endDate,omitempty"`
+}
+
+// JSONResumeSkill is an entry of the skills section
+type JSONResumeSkill struct {
+	Name     string   `json:"name,omitempty"`
+	Level    string   `json:"level,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// JSONResumeCertificate is an entry of the certificates section
+type JSONResumeCertificate struct {
+	Name   string `json:"name,omitempty"`
+	Date   string `json:"date,omitempty"`
+	Issuer string `json:"issuer,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+// JSONResumeProject is an entry of the projects section
+type JSONResumeProject struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Highlights  []string `json:"highlights,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	URL         string   `json:"url,omitempty"`
+}
+
+// parseJSONResume decodes data as a JSON Resume. It reports false when data is
+// not a JSON object with any of the schema's top-level sections.
+func parseJSONResume(data []byte) (*JSONResume, bool, error) {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, false, nil
+	}
+	if sections["basics"] == nil && sections["work"] == nil && sections["education"] == nil {
+		return nil, false, nil
+	}
+
+	resume := &JSONResume{}
+	if err := json.Unmarshal(data, resume); err != nil {
+		return nil, true, fmt.Errorf("invalid JSON Resume: %v", err)
+	}
+	return resume, true, nil
+}
+
+// profile converts the resume into a profile
+func (r *JSONResume) profile() *Profile {
+	b := r.Basics
+	p := &Profile{
+		Contact: Contact{
+			Name:     b.Name,
+			Headline: b.Label,
+			Email:    b.Email,
+			Phone:    b.Phone,
+		},
+		Summary: b.Summary,
+	}
+	if b.Location != nil {
+		p.Contact.Location = joinNonEmpty(", ", b.Location.City, b.Location.Region, b.Location.CountryCode)
+	}
+	if b.URL != "" {
+		p.Links = append(p.Links, Link{Label: "Website", URL: b.URL})
+	}
+	for _, profile := range b.Profiles {
+		if profile.URL != "" {
+			p.Links = append(p.Links, Link{Label: profile.Network, URL: profile.URL})
+		}
+	}
+
+	for _, w := range r.Work {
+		p.Experience = append(p.Experience, Experience{
+			Company:    w.Name,
+			Title:      w.Position,
+			Location:   w.Location,
+			StartDate:  w.StartDate,
+			EndDate:    w.EndDate,
+			Summary:    w.Summary,
+			Highlights: w.Highlights,
+		})
+	}
+	for _, e := range r.Education {
+		p.Education = append(p.Education, Education{
+			Institution: e.Institution,
+			Degree:      e.StudyType,
+			Field:       e.Area,
+			StartDate:   e.StartDate,
+			EndDate:     e.EndDate,
+		})
+	}
+	for _, s := range r.Skills {
+		if s.Name != "" {
+			p.Skills = append(p.Skills, s.Name)
+		}
+		p.Skills = append(p.Skills, s.Keywords...)
+	}
+	for _, c := range r.Certificates {
+		p.Certifications = append(p.Certifications, Certification{
+			Name:      c.Name,
+			Authority: c.Issuer,
+			URL:       c.URL,
+			StartDate: c.Date,
+		})
+	}
+	for _, project := range r.Projects {
+		p.Projects = append(p.Projects, Project{
+			Name:         project.Name,
+			Description:  project.Description,
+			URL:          project.URL,
+			Technologies: project.Keywords,
+			Highlights:   project.Highlights,
+			Source:       sourceManual,
+		})
+	}
+	return p
+}
+
+// jsonResume converts the profile into the JSON Resume schema
+func (p *Profile) jsonResume() *JSONResume {
+	c := p.Contact
+	r := &JSONResume{
+		Schema: jsonResumeSchema,
+		Basics: JSONResumeBasics{
+			Name:    c.Name,
+			Label:   c.Headline,
+			Email:   c.Email,
+			Phone:   c.Phone,
+			Summary: p.Summary,
+		},
+	}
+	if c.Location != "" {
+		r.Basics.Location = &JSONResumeLocation{City: c.Location}
+	}
+	for _, link := range p.Links {
+		if strings.EqualFold(link.Label, "website") && r.Basics.URL == "" {
+			r.Basics.URL = link.URL
+			continue
+		}
+		r.Basics.Profiles = append(r.Basics.Profiles, JSONResumeProfile{Network: link.Label, URL: link.URL})
+	}
+
+	for _, e := range p.Experience {
+		r.Work = append(r.Work, JSONResumeWork{
+			Name:       e.Company,
+			Position:   e.Title,
+			Location:   e.Location,
+			StartDate:  e.StartDate,
+			EndDate:    e.EndDate,
+			Summary:    e.Summary,
+			Highlights: e.Highlights,
+		})
+	}
+	for _, e := range p.Education {
+		r.Education = append(r.Education, JSONResumeEducation{
+			Institution: e.Institution,
+			StudyType:   e.Degree,
+			Area:        e.Field,
+			StartDate:   e.StartDate,
+			EndDate:     e.EndDate,
+		})
+	}
+	for _, skill := range p.Skills {
+		r.Skills = append(r.Skills, JSONResumeSkill{Name: skill})
+	}
+	// Detected skills are grouped by category, leaving out those listed by hand
+	for _, category := range stackCategories {
+		var keywords []string
+		for _, skill := range p.Stack {
+			if skill.Category == category.name && !containsFold(p.Skills, skill.Name) {
+				keywords = append(keywords, skill.Name)
+			}
+		}
+		if len(keywords) > 0 {
+			r.Skills = append(r.Skills, JSONResumeSkill{Name: category.title, Keywords: keywords})
+		}
+	}
+	for _, c := range p.Certifications {
+		r.Certificates = append(r.Certificates, JSONResumeCertificate{
+			Name:   c.Name,
+			Date:   c.StartDate,
+			Issuer: c.Authority,
+			URL:    c.URL,
+		})
+	}
+	for _, project := range p.Projects {
+		keywords := project.Technologies
+		if len(keywords) == 0 {
+			keywords = p.projectStack(project.Name)
+		}
+		r.Projects = append(r.Projects, JSONResumeProject{
+			Name:        project.Name,
+			Description: project.Description,
+			URL:         project.URL,
+			Keywords:    keywords,
+			Highlights:  project.Highlights,
+		})
+	}
+	return r
+}
+
+// exportJSONResume writes the profile as a JSON Resume file
+func (p *Profile) exportJSONResume(path string) error {
+	data, err := json.MarshalIndent(p.jsonResume(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("saving %s: %v", path, err)
+	}
+	return nil
+}
+
+// fillBasics copies contact details the resume left out from the exported
+// profile, so a generated resume never loses how to reach the candidate
+func (r *JSONResume) fillBasics(exported JSONResumeBasics) {
+	if r.Basics.Name == "" {
+		r.Basics.Name = exported.Name
+	}
+	if r.Basics.Email == "" {
+		r.Basics.Email = exported.Email
+	}
+	if r.Basics.Phone == "" {
+		r.Basics.Phone = exported.Phone
+	}
+	if r.Basics.URL == "" {
+		r.Basics.URL = exported.URL
+	}
+	if r.Basics.Location == nil {
+		r.Basics.Location = exported.Location
+	}
+	if len(r.Basics.Profiles) == 0 {
+		r.Basics.Profiles = exported.Profiles
+	}
+	if r.Schema == "" {
+		r.Schema = jsonResumeSchema
+	}
+}
+
+// extractJSON returns the JSON object in a model reply, dropping any Markdown code fence
+func extractJSON(reply string) string {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start == -1 || end < start {
+		return reply
+	}
+	return reply[start : end+1]
+}
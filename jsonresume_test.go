@@ -0,0 +1,122 @@
+// Filename: jsonresume_test.go
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testJSONResume = `{
+  "$schema": "https://raw.githubusercontent.com/jsonresume/resume-schema/v1.0.0/schema.json",
+  "basics": {
+    "name": "Jane Doe",
+    "label": "Backend engineer",
+    "email": "jane@example.com",
+    "phone": "+49 30 1234567",
+    "url": "https://jane.dev",
+    "summary": "Builds APIs, mostly in Go.",
+    "location": {"city": "Berlin"},
+    "profiles": [{"network": "GitHub", "url": "https://github.com/jane"}]
+  },
+  "work": [{
+    "name": "Example",
+    "position": "Engineer",
+    "location": "Berlin",
+    "startDate": "2020-01",
+    "summary": "Platform team.",
+    "highlights": ["Built the billing platform"]
+  }],
+  "education": [{"institution": "TU Berlin", "area": "Computer Science", "studyType": "BSc", "startDate": "2014", "endDate": "2018"}],
+  "skills": [{"name": "Go"}, {"name": "Kubernetes"}],
+  "certificates": [{"name": "CKA", "date": "2022-05", "issuer": "CNCF", "url": "https://cncf.io/cka"}],
+  "projects": [{"name": "amalgia", "description": "Resume generator", "url": "https://github.com/jane/amalgia", "keywords": ["Go"], "highlights": ["TUI"]}]
+}`
+
+func TestJSONResumeRoundTrip(t *testing.T) {
+	resume, ok, err := parseJSONResume([]byte(testJSONResume))
+	if err != nil || !ok {
+		t.Fatalf("parseJSONResume: ok = %v, err = %v", ok, err)
+	}
+
+	p := resume.profile()
+	if p.Contact.Name != "Jane Doe" || p.Contact.Headline != "Backend engineer" || p.Contact.Location != "Berlin" {
+		t.Errorf("contact = %+v", p.Contact)
+	}
+	wantLinks := []Link{{Label: "Website", URL: "https://jane.dev"}, {Label: "GitHub", URL: "https://github.com/jane"}}
+	if !reflect.DeepEqual(p.Links, wantLinks) {
+		t.Errorf("links = %+v, want %+v", p.Links, wantLinks)
+	}
+	if len(p.Education) != 1 || p.Education[0].Degree != "BSc" || p.Education[0].Field != "Computer Science" {
+		t.Errorf("education = %+v", p.Education)
+	}
+	if len(p.Certifications) != 1 || p.Certifications[0].Authority != "CNCF" || p.Certifications[0].StartDate != "2022-05" {
+		t.Errorf("certifications = %+v", p.Certifications)
+	}
+	if len(p.Projects) != 1 || p.Projects[0].Source != sourceManual || !reflect.DeepEqual(p.Projects[0].Technologies, []string{"Go"}) {
+		t.Errorf("projects = %+v", p.Projects)
+	}
+
+	// Converting back gives the document that was imported
+	var want JSONResume
+	if err := json.Unmarshal([]byte(testJSONResume), &want); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.jsonResume(); !reflect.DeepEqual(*got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("round trip changed the resume:\n%s", gotJSON)
+	}
+}
+
+func TestJSONResumeProfileFlattensSections(t *testing.T) {
+	resume := &JSONResume{
+		Basics: JSONResumeBasics{
+			Location: &JSONResumeLocation{City: "Berlin", Region: "BE", CountryCode: "DE"},
+			Profiles: []JSONResumeProfile{{Network: "Mastodon", Username: "jane"}},
+		},
+		Skills: []JSONResumeSkill{{Name: "Languages", Keywords: []string{"Go", "Rust"}}},
+	}
+
+	p := resume.profile()
+	if p.Contact.Location != "Berlin, BE, DE" {
+		t.Errorf("location = %q", p.Contact.Location)
+	}
+	// Profiles without a URL have nothing to link to
+	if len(p.Links) != 0 {
+		t.Errorf("links = %+v", p.Links)
+	}
+	if !reflect.DeepEqual(p.Skills, []string{"Languages", "Go", "Rust"}) {
+		t.Errorf("skills = %q", p.Skills)
+	}
+}
+
+func TestParseJSONResume(t *testing.T) {
+	for _, data := range []string{`not json`, `[]`, `{"name": "Jane"}`} {
+		if _, ok, err := parseJSONResume([]byte(data)); ok || err != nil {
+			t.Errorf("%s: ok = %v, err = %v, want not a JSON Resume", data, ok, err)
+		}
+	}
+	if _, ok, err := parseJSONResume([]byte(`{"basics": {"name": 42}}`)); !ok || err == nil {
+		t.Errorf("malformed basics: ok = %v, err = %v, want an error", ok, err)
+	}
+}
+
+func TestFillBasics(t *testing.T) {
+	exported := (&Profile{
+		Contact: Contact{Name: "Jane Doe", Email: "jane@example.com", Phone: "+49 30 1234567", Location: "Berlin"},
+		Links:   []Link{{Label: "Website", URL: "https://jane.dev"}, {Label: "GitHub", URL: "https://github.com/jane"}},
+	}).jsonResume().Basics
+
+	generated := &JSONResume{Basics: JSONResumeBasics{Name: "J. Doe", Label: "Engineer"}}
+	generated.fillBasics(exported)
+
+	want := exported
+	want.Name, want.Label = "J. Doe", "Engineer"
+	want.Summary = ""
+	if !reflect.DeepEqual(generated.Basics, want) {
+		t.Errorf("basics = %+v, want %+v", generated.Basics, want)
+	}
+	if generated.Schema != jsonResumeSchema {
+		t.Errorf("schema = %q", generated.Schema)
+	}
+}
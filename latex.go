@@ -0,0 +1,336 @@
+// Filename: latex.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Settings of resume.latex_engine besides the name of an engine
+const (
+	latexEngineAuto = "auto" // The first engine of latexEngines that is installed
+	latexEngineNone = "none" // Only write the .tex file
+)
+
+// latexEngines are the TeX engines tried by latexEngineAuto, in order
+var latexEngines = []string{"xelatex", "lualatex", "pdflatex"}
+
+// latexCompileTimeout bounds a TeX run, which can hang on a broken template
+const latexCompileTimeout = 2 * time.Minute
+
+// latexReplacer escapes the characters LaTeX treats specially in text
+var latexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+	`<`, `\textless{}`,
+	`>`, `\textgreater{}`,
+	`|`, `\textbar{}`,
+)
+
+// latexParagraphs matches blank lines between paragraphs
+var latexParagraphs = regexp.MustCompile(`\n\s*\n\s*`)
+
+// latexEscape makes text safe to use in a LaTeX document, keeping paragraph breaks
+func latexEscape(text string) string {
+	text = latexReplacer.Replace(strings.TrimSpace(text))
+	text = latexParagraphs.ReplaceAllString(text, `\par `)
+	return strings.ReplaceAll(text, "\n", " ")
+}
+
+// latexURLReplacer escapes the characters \href needs escaped in a URL
+var latexURLReplacer = strings.NewReplacer(`\`, ``, `%`, `\%`, `#`, `\#`, `{`, `\{`, `}`, `\}`)
+
+// latexURL makes a URL safe to use in \href and \url
+func latexURL(url string) string {
+	return latexURLReplacer.Replace(url)
+}
+
+// latexDates formats a date range with an en dash, treating a missing end as present
+func latexDates(start, end string) string {
+	if start == "" {
+		return latexEscape(end)
+	}
+	if end == "" {
+		end = "Present"
+	}
+	return latexEscape(start) + "--" + latexEscape(end)
+}
+
+// splitName splits a full name into given names and the family name, as moderncv expects
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return name, ""
+	}
+	return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+}
+
+// latexTemplateFuncs are available in the LaTeX templates, which use << and >>
+// as delimiters since braces are everywhere in LaTeX
+var latexTemplateFuncs = texttemplate.FuncMap{
+	"tex":          latexEscape,
+	"url":          latexURL,
+	"dates":        latexDates,
+	"contacts":     resumeContacts,
+	"location":     resumeLocation,
+	"displayURL":   displayURL,
+	"join":         strings.Join,
+	"joinNonEmpty": joinNonEmpty,
+	"firstName":    func(name string) string { first, _ := splitName(name); return first },
+	"lastName":     func(name string) string { _, last := splitName(name); return last },
+}
+
+// latexTemplates are the bundled LaTeX templates by name
+var latexTemplates = map[string]string{
+	"moderncv": latexModernCVTemplate,
+	"awesome":  latexAwesomeTemplate,
+	"simple":   latexSimpleTemplate,
+}
+
+// latexTemplateNames returns the bundled template names in a stable order
+func latexTemplateNames() []string {
+	return []string{"moderncv", "awesome", "simple"}
+}
+
+// latexModernCVTemplate uses the moderncv class in its classic style
+const latexModernCVTemplate = `\documentclass[11pt,a4paper,sans]{moderncv}
+\moderncvstyle{classic}
+\moderncvcolor{blue}
+\usepackage[utf8]{inputenc}
+\usepackage[scale=0.8]{geometry}
+
+\name{<<tex (firstName .Basics.Name)>>}{<<tex (lastName .Basics.Name)>>}
+<<with .Basics.Label>>\title{<<tex .>>}
+<<end>><<with location .Basics.Location>>\address{<<tex .>>}{}{}
+<<end>><<with .Basics.Phone>>\phone[mobile]{<<tex .>>}
+<<end>><<with .Basics.Email>>\email{<<tex .>>}
+<<end>><<with .Basics.URL>>\homepage{<<tex (displayURL .)>>}
+<<end>><<with .Basics.Profiles>>\extrainfo{<<range $i, $p := .>><<if $i>> \textbullet{} <<end>><<if $p.URL>>\href{<<url $p.URL>>}{<<tex (displayURL $p.URL)>>}<<else>><<tex (joinNonEmpty ": " $p.Network $p.Username)>><<end>><<end>>}
+<<end>>
+\begin{document}
+\makecvtitle
+<<with .Basics.Summary>>
+\section{Summary}
+\cvitem{}{<<tex .>>}
+<<end>><<with .Work>>
+\section{Experience}
+<<range .>>\cventry{<<dates .StartDate .EndDate>>}{<<tex .Position>>}{<<if .URL>>\href{<<url .URL>>}{<<tex .Name>>}<<else>><<tex .Name>><<end>>}{<<tex .Location>>}{}{<<tex .Summary>><<with .Highlights>>
+\begin{itemize}
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}<<end>>}
+<<end>><<end>><<with .Projects>>
+\section{Projects}
+<<range .>>\cventry{}{<<if .URL>>\href{<<url .URL>>}{<<tex .Name>>}<<else>><<tex .Name>><<end>>}{<<tex (join .Keywords ", ")>>}{}{}{<<tex .Description>><<with .Highlights>>
+\begin{itemize}
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}<<end>>}
+<<end>><<end>><<with .Skills>>
+\section{Skills}
+<<range .>><<if .Keywords>>\cvitem{<<tex .Name>>}{<<tex (join .Keywords ", ")>><<with .Level>> (<<tex .>>)<<end>>}<<else>>\cvitem{}{<<tex .Name>><<with .Level>> (<<tex .>>)<<end>>}<<end>>
+<<end>><<end>><<with .Education>>
+\section{Education}
+<<range .>>\cventry{<<dates .StartDate .EndDate>>}{<<tex (joinNonEmpty " in " .StudyType .Area)>>}{<<tex .Institution>>}{}{}{}
+<<end>><<end>><<with .Certificates>>
+\section{Certificates}
+<<range .>>\cvitem{<<tex .Date>>}{<<tex (joinNonEmpty ", " .Name .Issuer)>>}
+<<end>><<end>>
+\end{document}
+`
+
+// latexAwesomeTemplate follows the look of Awesome CV with standard packages only
+const latexAwesomeTemplate = `\documentclass[11pt,a4paper]{article}
+\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+\usepackage[margin=1.6cm]{geometry}
+\usepackage[hidelinks]{hyperref}
+\usepackage{xcolor}
+\usepackage{enumitem}
+\usepackage{titlesec}
+
+\definecolor{awesome}{HTML}{DC3522}
+\definecolor{darktext}{HTML}{414141}
+\definecolor{graytext}{HTML}{5D5D5D}
+\renewcommand{\familydefault}{\sfdefault}
+\pagestyle{empty}
+\setlength{\parindent}{0pt}
+\setlist[itemize]{leftmargin=1.5em,itemsep=1pt,topsep=2pt}
+\titleformat{\section}{\color{awesome}\Large\bfseries}{}{0pt}{}[{\color{graytext}\titlerule}]
+\titlespacing*{\section}{0pt}{10pt}{6pt}
+\newcommand{\cventry}[4]{\par\textbf{\color{darktext}#1}\hfill{\small\color{awesome}#2}\par{\small\itshape\color{graytext}#3}\hfill{\small\itshape\color{graytext}#4}\par}
+
+\begin{document}
+\begin{center}
+{\fontsize{30}{36}\selectfont\color{graytext}<<tex (firstName .Basics.Name)>> \textbf{\color{darktext}<<tex (lastName .Basics.Name)>>}}\par\medskip
+<<with .Basics.Label>>{\small\scshape\color{awesome}<<tex .>>}\par\medskip
+<<end>><<with contacts .Basics>>{\small\color{graytext}<<range $i, $c := .>><<if $i>> \textbar{} <<end>><<if $c.URL>>\href{<<url $c.URL>>}{<<tex $c.Text>>}<<else>><<tex $c.Text>><<end>><<end>>}
+<<end>>\end{center}
+<<with .Basics.Summary>>
+\section{Summary}
+{\color{darktext}<<tex .>>}
+<<end>><<with .Work>>
+\section{Experience}
+<<range .>>\cventry{<<tex .Position>>}{<<dates .StartDate .EndDate>>}{<<tex .Name>>}{<<tex .Location>>}
+<<with .Summary>>{\small <<tex .>>}\par
+<<end>><<with .Highlights>>\begin{itemize}\small
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}
+<<end>>\medskip
+<<end>><<end>><<with .Projects>>
+\section{Projects}
+<<range .>>\cventry{<<if .URL>>\href{<<url .URL>>}{<<tex .Name>>}<<else>><<tex .Name>><<end>>}{}{<<tex (join .Keywords ", ")>>}{}
+<<with .Description>>{\small <<tex .>>}\par
+<<end>><<with .Highlights>>\begin{itemize}\small
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}
+<<end>>\medskip
+<<end>><<end>><<with .Skills>>
+\section{Skills}
+<<range .>>\textbf{\color{darktext}<<tex .Name>>}<<with .Level>> (<<tex .>>)<<end>><<with .Keywords>>: <<tex (join . ", ")>><<end>>\par
+<<end>><<end>><<with .Education>>
+\section{Education}
+<<range .>>\cventry{<<tex (joinNonEmpty " in " .StudyType .Area)>>}{<<dates .StartDate .EndDate>>}{<<tex .Institution>>}{}
+<<end>><<end>><<with .Certificates>>
+\section{Certificates}
+<<range .>>\cventry{<<tex .Name>>}{<<tex .Date>>}{<<tex .Issuer>>}{}
+<<end>><<end>>
+\end{document}
+`
+
+// latexSimpleTemplate is a plain article that compiles with any TeX installation
+const latexSimpleTemplate = `\documentclass[11pt,a4paper]{article}
+\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+\usepackage[margin=2cm]{geometry}
+\usepackage[hidelinks]{hyperref}
+\usepackage{enumitem}
+
+\pagestyle{empty}
+\setlength{\parindent}{0pt}
+\setlist[itemize]{itemsep=0pt,topsep=2pt}
+
+\begin{document}
+{\LARGE\bfseries <<tex .Basics.Name>>}\par
+<<with .Basics.Label>>{\large <<tex .>>}\par
+<<end>><<with contacts .Basics>>\smallskip
+<<range $i, $c := .>><<if $i>> \textbullet{} <<end>><<if $c.URL>>\href{<<url $c.URL>>}{<<tex $c.Text>>}<<else>><<tex $c.Text>><<end>><<end>>\par
+<<end>><<with .Basics.Summary>>
+\section*{Summary}
+<<tex .>>
+<<end>><<with .Work>>
+\section*{Experience}
+<<range .>>\textbf{<<tex (joinNonEmpty ", " .Position .Name)>>} \hfill <<dates .StartDate .EndDate>>\par
+<<with .Location>>\textit{<<tex .>>}\par
+<<end>><<with .Summary>><<tex .>>\par
+<<end>><<with .Highlights>>\begin{itemize}
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}
+<<end>>\medskip
+<<end>><<end>><<with .Projects>>
+\section*{Projects}
+<<range .>>\textbf{<<if .URL>>\href{<<url .URL>>}{<<tex .Name>>}<<else>><<tex .Name>><<end>>}<<with .Keywords>> \textit{(<<tex (join . ", ")>>)}<<end>>\par
+<<with .Description>><<tex .>>\par
+<<end>><<with .Highlights>>\begin{itemize}
+<<range .>>\item <<tex .>>
+<<end>>\end{itemize}
+<<end>>\medskip
+<<end>><<end>><<with .Skills>>
+\section*{Skills}
+<<range .>>\textbf{<<tex .Name>>}<<with .Level>> (<<tex .>>)<<end>><<with .Keywords>>: <<tex (join . ", ")>><<end>>\par
+<<end>><<end>><<with .Education>>
+\section*{Education}
+<<range .>>\textbf{<<tex (joinNonEmpty ", " (joinNonEmpty " in " .StudyType .Area) .Institution)>>} \hfill <<dates .StartDate .EndDate>>\par
+<<end>><<end>><<with .Certificates>>
+\section*{Certificates}
+<<range .>><<tex (joinNonEmpty ", " .Name .Issuer .Date)>>\par
+<<end>><<end>>
+\end{document}
+`
+
+// renderResumeLaTeX renders the resume with the configured LaTeX template, or
+// resume.tex.tmpl in the templates directory
+func renderResumeLaTeX(r *JSONResume, cfg ResumeConfig) ([]byte, error) {
+	text, err := resumeTemplate(cfg, "resume.tex.tmpl", latexTemplates[cfg.LaTeXTemplate])
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := texttemplate.New("resume.tex.tmpl").Delims("<<", ">>").Funcs(latexTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing LaTeX template: %v", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r); err != nil {
+		return nil, fmt.Errorf("rendering LaTeX: %v", err)
+	}
+	return blankLines.ReplaceAll(b.Bytes(), []byte("\n\n")), nil
+}
+
+// findLaTeXEngine returns the path of the configured engine, or "" when .tex
+// files are not to be compiled or no engine is installed
+func findLaTeXEngine(engine string) string {
+	candidates := []string{engine}
+	switch engine {
+	case latexEngineNone:
+		return ""
+	case latexEngineAuto:
+		candidates = latexEngines
+	}
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// compileLaTeX runs the configured engine on texFile, writing the PDF and the
+// engine's auxiliary files next to it. It returns the PDF's path, or "" when
+// no engine is configured or installed.
+func compileLaTeX(ctx context.Context, engine, texFile string) (string, error) {
+	path := findLaTeXEngine(engine)
+	if path == "" {
+		if engine != latexEngineNone && engine != latexEngineAuto {
+			return "", fmt.Errorf("TeX engine %q not found", engine)
+		}
+		return "", nil
+	}
+
+	// The engine runs in the directory of texFile, so relative paths given
+	// for the engine must not be resolved against it
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, latexCompileTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "-interaction=nonstopmode", "-halt-on-error", "-output-directory=.", filepath.Base(texFile))
+	cmd.Dir = filepath.Dir(texFile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s failed: %v%s", filepath.Base(path), err, latexErrorLine(out))
+	}
+	return strings.TrimSuffix(texFile, filepath.Ext(texFile)) + ".pdf", nil
+}
+
+// latexErrorLine returns the first error TeX reported, such as a missing class
+func latexErrorLine(output []byte) string {
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "! ") {
+			return ": " + strings.TrimSpace(strings.TrimPrefix(line, "! "))
+		}
+	}
+	return ""
+}
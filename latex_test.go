@@ -0,0 +1,124 @@
+// Filename: latex_test.go
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLaTeXEscape(t *testing.T) {
+	tests := map[string]string{
+		`C:\path`:          `C:\textbackslash{}path`,
+		"R&D":              `R\&D`,
+		"100%":             `100\%`,
+		"$5":               `\$5`,
+		"#1":               `\#1`,
+		"snake_case":       `snake\_case`,
+		"{braces}":         `\{braces\}`,
+		"~user":            `\textasciitilde{}user`,
+		"x^2":              `x\textasciicircum{}2`,
+		"a < b > c | d":    `a \textless{} b \textgreater{} c \textbar{} d`,
+		"José Müller":      "José Müller",
+		"  one\ntwo  ":     "one two",
+		"first\n\n second": `first\par second`,
+	}
+	for in, want := range tests {
+		if got := latexEscape(in); got != want {
+			t.Errorf("latexEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if got, want := latexURL(`https://example.com/a%20b#top`), `https://example.com/a\%20b\#top`; got != want {
+		t.Errorf("latexURL = %q, want %q", got, want)
+	}
+	if got, want := latexDates("2021-03", ""), "2021-03--Present"; got != want {
+		t.Errorf("latexDates = %q, want %q", got, want)
+	}
+}
+
+func TestRenderResumeLaTeXTemplates(t *testing.T) {
+	resume := testResume()
+	resume.Basics.Label = "Go & Rust developer"
+	for _, name := range latexTemplateNames() {
+		cfg := defaultResumeConfig()
+		cfg.LaTeXTemplate = name
+		data, err := renderResume(resume, formatLaTeX, cfg)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		tex := string(data)
+		for _, want := range []string{`\documentclass`, `\begin{document}`, `\end{document}`, "Lovelace", `Go \& Rust developer`, `R\&D`, "Analytical Engines", "amalgia", "University of London"} {
+			if !strings.Contains(tex, want) {
+				t.Errorf("%s: output lacks %q", name, want)
+			}
+		}
+		if strings.Contains(tex, "<<") || strings.Contains(tex, "Go & Rust") {
+			t.Errorf("%s: template syntax or unescaped text left in the output", name)
+		}
+		// Braces other than escaped ones must balance for TeX to compile
+		depth := 0
+		for _, r := range strings.NewReplacer(`\{`, "", `\}`, "").Replace(tex) {
+			switch r {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			if depth < 0 {
+				break
+			}
+		}
+		if depth != 0 {
+			t.Errorf("%s: unbalanced braces in the output", name)
+		}
+	}
+}
+
+func TestCompileLaTeXInSubdirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake engine is a shell script")
+	}
+	// The fake engine writes the PDF where the arguments point, relative to its working directory
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor arg; do case \"$arg\" in -output-directory=*) out=\"${arg#-output-directory=}\";; -*) ;; *) tex=\"$arg\";; esac; done\n" +
+		"test -f \"$tex\" || { echo \"! I can't find file $tex\"; exit 1; }\n" +
+		"touch \"$out/$(basename \"$tex\" .tex).pdf\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "fakelatex"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// A relative output path, as paths.resume_output usually is
+	work := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+	if err := os.MkdirAll("build", 0755); err != nil {
+		t.Fatal(err)
+	}
+	texFile := filepath.Join("build", "resume.tex")
+	if err := os.WriteFile(texFile, []byte("\\documentclass{article}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pdfFile, err := compileLaTeX(context.Background(), "fakelatex", texFile)
+	if err != nil {
+		t.Fatalf("compiling: %v", err)
+	}
+	if pdfFile != filepath.Join("build", "resume.pdf") {
+		t.Errorf("got PDF path %q, want build/resume.pdf", pdfFile)
+	}
+	if _, err := os.Stat(filepath.Join(work, "build", "resume.pdf")); err != nil {
+		t.Errorf("PDF not written next to the .tex file: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Filename: linkedin.go
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// linkedInFiles are the CSVs of a LinkedIn data export that the importer reads
+var linkedInFiles = []string{"Profile.csv", "Email Addresses.csv", "Positions.csv", "Education.csv", "Skills.csv", "Projects.csv", "Certifications.csv"}
+
+// isLinkedInExport reports whether a zip archive looks like a LinkedIn data export
+func isLinkedInExport(zr *zip.Reader) bool {
+	for _, f := range zr.File {
+		switch path.Base(f.Name) {
+		case "Positions.csv", "Profile.csv":
+			return true
+		}
+	}
+	return false
+}
+
+// importLinkedInExport reads a LinkedIn data export ZIP into a profile
+func importLinkedInExport(filename string) (*Profile, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open ZIP: %v", err)
+	}
+	defer zr.Close()
+	if !isLinkedInExport(&zr.Reader) {
+		return nil, fmt.Errorf("ZIP file is not a LinkedIn data export")
+	}
+
+	tables := make(map[string][]map[string]string)
+	for _, name := range linkedInFiles {
+		rows, err := readLinkedInCSV(&zr.Reader, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", name, err)
+		}
+		tables[name] = rows
+	}
+
+	p := &Profile{}
+	for _, row := range tables["Profile.csv"] {
+		p.Contact.Name = joinNonEmpty(" ", row["First Name"], row["Last Name"])
+		p.Contact.Headline = row["Headline"]
+		p.Contact.Location = row["Geo Location"]
+		p.Summary = row["Summary"]
+		p.Links = append(p.Links, parseLinkedInWebsites(row["Websites"])...)
+	}
+	for _, row := range tables["Email Addresses.csv"] {
+		if p.Contact.Email == "" || strings.EqualFold(row["Primary"], "yes") {
+			p.Contact.Email = row["Email Address"]
+		}
+	}
+
+	for _, row := range tables["Positions.csv"] {
+		p.Experience = append(p.Experience, Experience{
+			Company:   row["Company Name"],
+			Title:     row["Title"],
+			Location:  row["Location"],
+			StartDate: row["Started On"],
+			EndDate:   row["Finished On"],
+			Summary:   row["Description"],
+		})
+	}
+	for _, row := range tables["Education.csv"] {
+		p.Education = append(p.Education, Education{
+			Institution: row["School Name"],
+			Degree:      row["Degree Name"],
+			StartDate:   row["Start Date"],
+			EndDate:     row["End Date"],
+		})
+	}
+	for _, row := range tables["Skills.csv"] {
+		if row["Name"] != "" {
+			p.Skills = append(p.Skills, row["Name"])
+		}
+	}
+	for _, row := range tables["Projects.csv"] {
+		p.Projects = append(p.Projects, Project{
+			Name:        row["Title"],
+			Description: row["Description"],
+			URL:         row["Url"],
+			Source:      sourceLinkedIn,
+		})
+	}
+	for _, row := range tables["Certifications.csv"] {
+		p.Certifications = append(p.Certifications, Certification{
+			Name:      row["Name"],
+			Authority: row["Authority"],
+			URL:       row["Url"],
+			StartDate: row["Started On"],
+			EndDate:   row["Finished On"],
+		})
+	}
+	return p, nil
+}
+
+// readLinkedInCSV returns the rows of a CSV in the export keyed by column name,
+// or nil when the export does not include it. Exports may nest files in a folder.
+func readLinkedInCSV(zr *zip.Reader, name string) ([]map[string]string, error) {
+	var file *zip.File
+	for _, f := range zr.File {
+		if path.Base(f.Name) == name {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return nil, nil
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var header []string
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header == nil {
+			header = record
+			for i := range header {
+				header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+			}
+			continue
+		}
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseLinkedInWebsites reads the Websites column, formatted like
+// "[PORTFOLIO:https://a.dev,OTHER:https://b.dev]"
+func parseLinkedInWebsites(value string) []Link {
+	var links []Link
+	for _, item := range splitList(strings.Trim(value, "[]")) {
+		label, url := "Website", item
+		if prefix, rest, found := strings.Cut(item, ":"); found && prefix != "" && !strings.HasPrefix(rest, "//") {
+			label, url = strings.ToUpper(prefix[:1])+strings.ToLower(prefix[1:]), rest
+		}
+		links = append(links, Link{Label: label, URL: url})
+	}
+	return links
+}
@@ -0,0 +1,99 @@
+// Filename: linkedin_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportLinkedInExport(t *testing.T) {
+	// Exports nest the CSVs in a folder and start some of them with a BOM
+	path := writeTestZip(t, "Basic_LinkedInDataExport.zip", map[string]string{
+		"export/Profile.csv": "\ufeffFirst Name,Last Name,Headline,Summary,Geo Location,Websites\n" +
+			`Jane,Doe,Backend engineer,"Builds APIs, mostly in Go",Berlin,"[PORTFOLIO:https://jane.dev,https://blog.jane.dev]"` + "\n",
+		"export/Email Addresses.csv": "Email Address,Confirmed,Primary\n" +
+			"old@example.com,Yes,No\n" +
+			"jane@example.com,Yes,Yes\n" +
+			"other@example.com,Yes,No\n",
+		"export/Positions.csv": "Company Name,Title,Description,Location,Started On,Finished On\n" +
+			"Example,Engineer,Built the billing platform,Berlin,Jan 2020,\n",
+		"export/Education.csv": "School Name,Start Date,End Date,Notes,Degree Name\n" +
+			"TU Berlin,2014,2018,,BSc Computer Science\n",
+		"export/Skills.csv":   "Name\nGo\n\nKubernetes\n",
+		"export/Projects.csv": "Title,Description,Url,Started On,Finished On\nAmalgia,Resume generator,https://github.com/jane/amalgia,,\n",
+	})
+
+	p, err := importLinkedInExport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Contact.Name != "Jane Doe" || p.Contact.Headline != "Backend engineer" || p.Contact.Location != "Berlin" {
+		t.Errorf("contact = %+v", p.Contact)
+	}
+	if p.Contact.Email != "jane@example.com" {
+		t.Errorf("email = %q, want the primary address", p.Contact.Email)
+	}
+	if p.Summary != "Builds APIs, mostly in Go" {
+		t.Errorf("summary = %q", p.Summary)
+	}
+	wantLinks := []Link{{Label: "Portfolio", URL: "https://jane.dev"}, {Label: "Website", URL: "https://blog.jane.dev"}}
+	if !reflect.DeepEqual(p.Links, wantLinks) {
+		t.Errorf("links = %+v, want %+v", p.Links, wantLinks)
+	}
+	wantExperience := []Experience{{Company: "Example", Title: "Engineer", Location: "Berlin", StartDate: "Jan 2020", Summary: "Built the billing platform"}}
+	if !reflect.DeepEqual(p.Experience, wantExperience) {
+		t.Errorf("experience = %+v", p.Experience)
+	}
+	if len(p.Education) != 1 || p.Education[0].Degree != "BSc Computer Science" || p.Education[0].StartDate != "2014" {
+		t.Errorf("education = %+v", p.Education)
+	}
+	if !reflect.DeepEqual(p.Skills, []string{"Go", "Kubernetes"}) {
+		t.Errorf("skills = %q", p.Skills)
+	}
+	if len(p.Projects) != 1 || p.Projects[0].URL != "https://github.com/jane/amalgia" || p.Projects[0].Source != sourceLinkedIn {
+		t.Errorf("projects = %+v", p.Projects)
+	}
+	if len(p.Certifications) != 0 {
+		t.Errorf("certifications = %+v, want none from an export without them", p.Certifications)
+	}
+}
+
+func TestImportLinkedInExportFirstEmail(t *testing.T) {
+	path := writeTestZip(t, "export.zip", map[string]string{
+		"Profile.csv":         "First Name,Last Name\nJane,Doe\n",
+		"Email Addresses.csv": "Email Address,Primary\nfirst@example.com,No\nsecond@example.com,No\n",
+	})
+	p, err := importLinkedInExport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Contact.Email != "first@example.com" {
+		t.Errorf("email = %q, want the first address when none is primary", p.Contact.Email)
+	}
+}
+
+func TestImportLinkedInExportRejectsOtherZips(t *testing.T) {
+	path := writeTestZip(t, "photos.zip", map[string]string{"photo.csv": "Name\nx\n"})
+	if _, err := importLinkedInExport(path); err == nil {
+		t.Error("a ZIP without LinkedIn CSVs was imported")
+	}
+}
+
+func TestParseLinkedInWebsites(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []Link
+	}{
+		{"", nil},
+		{"[]", nil},
+		{"[PORTFOLIO:https://a.dev]", []Link{{Label: "Portfolio", URL: "https://a.dev"}}},
+		{"[OTHER:https://b.dev, https://c.dev]", []Link{{Label: "Other", URL: "https://b.dev"}, {Label: "Website", URL: "https://c.dev"}}},
+		{"https://d.dev", []Link{{Label: "Website", URL: "https://d.dev"}}},
+	}
+	for _, tt := range tests {
+		if got := parseLinkedInWebsites(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLinkedInWebsites(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Filename: llm.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Supported LLM provider names
+const (
+	providerOpenAI           = "openai"
+	providerOpenAICompatible = "openai-compatible"
+	providerFake             = "fake"
+)
+
+// errTruncatedReply is returned with the partial reply when the model stopped at the token limit
+var errTruncatedReply = errors.New("reply was cut off at the token limit")
+
+// ChatMessage is a single message exchanged with an LLM provider
+type ChatMessage struct {
+	Role    string
+	Content string
+}
+
+// CompletionRequest describes a chat completion independently of the provider
+type CompletionRequest struct {
+	Messages    []ChatMessage
+	MaxTokens   int
+	Temperature float32
+}
+
+// LLMProvider is implemented by every backend that can serve the AI actions
+type LLMProvider interface {
+	// Name returns the provider name used in logs and messages
+	Name() string
+	// Complete returns the full completion for the request. A reply cut off
+	// by MaxTokens is returned with an error wrapping errTruncatedReply.
+	Complete(ctx context.Context, req CompletionRequest) (string, error)
+	// Stream calls onDelta for each chunk and returns the assembled completion
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error)
+	// Embed returns one embedding vector per input
+	Embed(ctx context.Context, inputs []string) ([][]float32, error)
+}
+
+// LLMConfig holds the provider selection and generation settings
+type LLMConfig struct {
+	Provider        string  `yaml:"provider"`          // Provider name, see the provider* constants
+	Model           string  `yaml:"model"`             // Chat completion model
+	EmbeddingModel  string  `yaml:"embedding_model"`   // Embedding model
+	BaseURL         string  `yaml:"base_url"`          // API base URL for OpenAI-compatible servers
+	APIKeyEnv       string  `yaml:"api_key_env"`       // Environment variable holding the API key
+	Fixtures        string  `yaml:"fixtures"`          // Scripted responses file for the fake provider
+	Temperature     float32 `yaml:"temperature"`       // Sampling temperature
+	MaxTokens       int     `yaml:"max_tokens"`        // Max tokens for document generation
+	ResumeMaxTokens int     `yaml:"resume_max_tokens"` // Max tokens for structured resumes, which need room for the whole JSON document
+	ChatMaxTokens   int     `yaml:"chat_max_tokens"`   // Max tokens for chat replies
+}
+
+// defaultLLMConfig returns the settings Amalgia used before they were configurable
+func defaultLLMConfig() LLMConfig {
+	return LLMConfig{
+		Provider:        providerOpenAI,
+		Model:           openai.GPT4,
+		EmbeddingModel:  string(openai.SmallEmbedding3),
+		APIKeyEnv:       "OPENAI_API_KEY",
+		Temperature:     0.7,
+		MaxTokens:       1000,
+		ResumeMaxTokens: 3000,
+		ChatMaxTokens:   500,
+	}
+}
+
+// applyEnv applies AMALGIA_LLM_* environment overrides
+func (cfg *LLMConfig) applyEnv() error {
+	stringVars := map[string]*string{
+		"AMALGIA_LLM_PROVIDER":        &cfg.Provider,
+		"AMALGIA_LLM_MODEL":           &cfg.Model,
+		"AMALGIA_LLM_EMBEDDING_MODEL": &cfg.EmbeddingModel,
+		"AMALGIA_LLM_BASE_URL":        &cfg.BaseURL,
+		"AMALGIA_LLM_API_KEY_ENV":     &cfg.APIKeyEnv,
+		"AMALGIA_LLM_FIXTURES":        &cfg.Fixtures,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+
+	if value := os.Getenv("AMALGIA_LLM_TEMPERATURE"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("invalid AMALGIA_LLM_TEMPERATURE %q: %v", value, err)
+		}
+		cfg.Temperature = float32(temperature)
+	}
+
+	intVars := map[string]*int{
+		"AMALGIA_LLM_MAX_TOKENS":        &cfg.MaxTokens,
+		"AMALGIA_LLM_RESUME_MAX_TOKENS": &cfg.ResumeMaxTokens,
+		"AMALGIA_LLM_CHAT_MAX_TOKENS":   &cfg.ChatMaxTokens,
+	}
+	for name, target := range intVars {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, value, err)
+			}
+			*target = n
+		}
+	}
+
+	return nil
+}
+
+// validate checks the provider selection and generation settings
+func (cfg LLMConfig) validate() error {
+	switch cfg.Provider {
+	case providerOpenAI, providerFake:
+	case providerOpenAICompatible:
+		if cfg.BaseURL == "" {
+			return errors.New("the openai-compatible provider requires base_url")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q", cfg.Provider)
+	}
+	if cfg.Model == "" {
+		return errors.New("model must not be empty")
+	}
+	if cfg.Temperature < 0 || cfg.Temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2, got %v", cfg.Temperature)
+	}
+	if cfg.MaxTokens <= 0 || cfg.ResumeMaxTokens <= 0 || cfg.ChatMaxTokens <= 0 {
+		return errors.New("max_tokens, resume_max_tokens and chat_max_tokens must be positive")
+	}
+	return nil
+}
+
+// requiredEnvVars lists the environment variables the configured provider needs
+func (cfg LLMConfig) requiredEnvVars() []string {
+	switch cfg.Provider {
+	case providerOpenAI:
+		return []string{cfg.APIKeyEnv}
+	default:
+		// OpenAI-compatible servers frequently run without authentication,
+		// and the fake provider never leaves the machine
+		return nil
+	}
+}
+
+// newLLMProvider builds the provider selected in the configuration
+func newLLMProvider(cfg LLMConfig) (LLMProvider, error) {
+	switch cfg.Provider {
+	case providerOpenAI:
+		apiKey := os.Getenv(cfg.APIKeyEnv)
+		if apiKey == "" && !activeCassette.replaying() {
+			return nil, fmt.Errorf("%s environment variable not set", cfg.APIKeyEnv)
+		}
+		return newOpenAIProvider(providerOpenAI, openai.DefaultConfig(apiKey), cfg), nil
+
+	case providerOpenAICompatible:
+		if cfg.BaseURL == "" {
+			return nil, errors.New("the openai-compatible provider requires a base URL")
+		}
+		clientConfig := openai.DefaultConfig(os.Getenv(cfg.APIKeyEnv))
+		clientConfig.BaseURL = cfg.BaseURL
+		return newOpenAIProvider(providerOpenAICompatible, clientConfig, cfg), nil
+
+	case providerFake:
+		return newFakeProvider(cfg.Fixtures)
+
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
+
+// openaiProvider talks to the OpenAI API or any server implementing it
+type openaiProvider struct {
+	name           string
+	client         *openai.Client
+	model          string
+	embeddingModel string
+}
+
+func newOpenAIProvider(name string, clientConfig openai.ClientConfig, cfg LLMConfig) *openaiProvider {
+	if activeCassette != nil {
+		clientConfig.HTTPClient = activeCassette.HTTPClient(&http.Client{})
+	}
+	return &openaiProvider{
+		name:           name,
+		client:         openai.NewClientWithConfig(clientConfig),
+		model:          cfg.Model,
+		embeddingModel: cfg.EmbeddingModel,
+	}
+}
+
+func (p *openaiProvider) Name() string {
+	return p.name
+}
+
+// chatRequest converts a CompletionRequest to the go-openai representation
+func (p *openaiProvider) chatRequest(req CompletionRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return openai.ChatCompletionRequest{
+		Model:       p.model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+}
+
+func (p *openaiProvider) Complete(ctx context.Context, req CompletionRequest) (string, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, p.chatRequest(req))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from %s", p.model)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason == openai.FinishReasonLength {
+		return choice.Message.Content, fmt.Errorf("%w of %d", errTruncatedReply, req.MaxTokens)
+	}
+	return choice.Message.Content, nil
+}
+
+func (p *openaiProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	chatReq := p.chatRequest(req)
+	chatReq.Stream = true
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var content []byte
+	var finishReason openai.FinishReason
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return string(content), err
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		if resp.Choices[0].FinishReason != "" {
+			finishReason = resp.Choices[0].FinishReason
+		}
+		delta := resp.Choices[0].Delta.Content
+		content = append(content, delta...)
+		if onDelta != nil {
+			onDelta(delta)
+		}
+	}
+
+	if len(content) == 0 {
+		return "", fmt.Errorf("no response from %s", p.model)
+	}
+	if finishReason == openai.FinishReasonLength {
+		return string(content), fmt.Errorf("%w of %d", errTruncatedReply, req.MaxTokens)
+	}
+	return string(content), nil
+}
+
+func (p *openaiProvider) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: inputs,
+		Model: openai.EmbeddingModel(p.embeddingModel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, item := range resp.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,211 @@
+// Filename: llm_test.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLLMConfigApplyEnv(t *testing.T) {
+	t.Setenv("AMALGIA_LLM_PROVIDER", providerOpenAICompatible)
+	t.Setenv("AMALGIA_LLM_MODEL", "llama3")
+	t.Setenv("AMALGIA_LLM_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("AMALGIA_LLM_TEMPERATURE", "0.2")
+	t.Setenv("AMALGIA_LLM_CHAT_MAX_TOKENS", "200")
+
+	cfg := defaultLLMConfig()
+	if err := cfg.applyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	want := defaultLLMConfig()
+	want.Provider = providerOpenAICompatible
+	want.Model = "llama3"
+	want.BaseURL = "http://localhost:11434/v1"
+	want.Temperature = 0.2
+	want.ChatMaxTokens = 200
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validating: %v", err)
+	}
+
+	for name, value := range map[string]string{"AMALGIA_LLM_TEMPERATURE": "warm", "AMALGIA_LLM_MAX_TOKENS": "1k"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			cfg := defaultLLMConfig()
+			if err := cfg.applyEnv(); err == nil {
+				t.Errorf("%s=%s was accepted", name, value)
+			}
+		})
+	}
+}
+
+func TestNewLLMProvider(t *testing.T) {
+	t.Setenv("TEST_OPENAI_KEY", "sk-test")
+	tests := []struct {
+		name     string
+		cfg      func(cfg *LLMConfig)
+		wantName string // Empty when creating the provider must fail
+	}{
+		{"openai", func(cfg *LLMConfig) {}, providerOpenAI},
+		{"openai without key", func(cfg *LLMConfig) { cfg.APIKeyEnv = "TEST_UNSET_KEY" }, ""},
+		{"compatible", func(cfg *LLMConfig) {
+			cfg.Provider, cfg.BaseURL = providerOpenAICompatible, "http://localhost:11434/v1"
+		}, providerOpenAICompatible},
+		{"compatible without key", func(cfg *LLMConfig) {
+			cfg.Provider, cfg.BaseURL, cfg.APIKeyEnv = providerOpenAICompatible, "http://localhost:11434/v1", "TEST_UNSET_KEY"
+		}, providerOpenAICompatible},
+		{"compatible without base URL", func(cfg *LLMConfig) { cfg.Provider = providerOpenAICompatible }, ""},
+		{"fake", func(cfg *LLMConfig) { cfg.Provider = providerFake }, providerFake},
+		{"unknown", func(cfg *LLMConfig) { cfg.Provider = "anthropic" }, ""},
+	}
+	for _, tt := range tests {
+		cfg := defaultLLMConfig()
+		cfg.APIKeyEnv = "TEST_OPENAI_KEY"
+		tt.cfg(&cfg)
+		llm, err := newLLMProvider(cfg)
+		if tt.wantName == "" {
+			if err == nil {
+				t.Errorf("%s: got provider %s, want an error", tt.name, llm.Name())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if llm.Name() != tt.wantName {
+			t.Errorf("%s: got %s, want %s", tt.name, llm.Name(), tt.wantName)
+		}
+	}
+}
+
+func TestNewLLMProviderFromConfig(t *testing.T) {
+	writeTestConfig(t, "llm:\n  provider: fake\n")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm, err := newLLMProvider(cfg.LLM)
+	if err != nil || llm.Name() != providerFake {
+		t.Fatalf("got %v, %v, want the configured fake provider", llm, err)
+	}
+
+	// The environment overrides the config file
+	t.Setenv("AMALGIA_LLM_PROVIDER", providerOpenAICompatible)
+	t.Setenv("AMALGIA_LLM_BASE_URL", "http://localhost:11434/v1")
+	cfg, err = loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm, err = newLLMProvider(cfg.LLM)
+	if err != nil || llm.Name() != providerOpenAICompatible {
+		t.Errorf("got %v, %v, want the provider of AMALGIA_LLM_PROVIDER", llm, err)
+	}
+}
+
+// newOpenAITestServer serves handler as an OpenAI-compatible API and returns
+// a provider using it
+func newOpenAITestServer(t *testing.T, handler http.HandlerFunc) LLMProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg := defaultLLMConfig()
+	cfg.Provider = providerOpenAICompatible
+	cfg.BaseURL = server.URL + "/v1"
+	cfg.Model = "test-model"
+	llm, err := newLLMProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return llm
+}
+
+func TestOpenAIProviderComplete(t *testing.T) {
+	finishReason := "stop"
+	var got struct {
+		Model       string  `json:"model"`
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float32 `json:"temperature"`
+		Messages    []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	llm := newOpenAITestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hello, Ada."}, "finish_reason": %q}]}`, finishReason)
+	})
+
+	req := CompletionRequest{
+		Messages:    []ChatMessage{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Greet me."}},
+		MaxTokens:   50,
+		Temperature: 0.5,
+	}
+	reply, err := llm.Complete(context.Background(), req)
+	if err != nil || reply != "Hello, Ada." {
+		t.Fatalf("got %q, %v", reply, err)
+	}
+	if got.Model != "test-model" || got.MaxTokens != 50 || got.Temperature != 0.5 || len(got.Messages) != 2 || got.Messages[1].Content != "Greet me." {
+		t.Errorf("request = %+v", got)
+	}
+
+	finishReason = "length"
+	reply, err = llm.Complete(context.Background(), req)
+	if !errors.Is(err, errTruncatedReply) || reply != "Hello, Ada." {
+		t.Errorf("got %q, %v, want the partial reply and errTruncatedReply", reply, err)
+	}
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	llm := newOpenAITestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo", "."} {
+			fmt.Fprintf(w, "data: {\"choices\": [{\"index\": 0, \"delta\": {\"content\": %q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: {\"choices\": [{\"index\": 0, \"delta\": {}, \"finish_reason\": \"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var deltas []string
+	reply, err := llm.Stream(context.Background(), CompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "Hi"}}}, func(delta string) {
+		if delta != "" {
+			deltas = append(deltas, delta)
+		}
+	})
+	if err != nil || reply != "Hello." {
+		t.Fatalf("got %q, %v", reply, err)
+	}
+	if want := []string{"Hel", "lo", "."}; !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %q, want %q", deltas, want)
+	}
+}
+
+func TestOpenAIProviderEmbed(t *testing.T) {
+	llm := newOpenAITestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Out of order, as the API does not promise any
+		fmt.Fprint(w, `{"data": [{"index": 1, "embedding": [0.5, 0.25]}, {"index": 0, "embedding": [1, 0]}]}`)
+	})
+
+	vectors, err := llm.Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]float32{{1, 0}, {0.5, 0.25}}; !reflect.DeepEqual(vectors, want) {
+		t.Errorf("got %v, want %v", vectors, want)
+	}
+}
@@ -7,9 +7,9 @@ import (
 )
 
 // InitializeLogger sets up logging to both stderr and a log file.
-func InitializeLogger() *log.Logger {
+func InitializeLogger(path string) *log.Logger {
 	// Open a log file for writing
-	logFile, err := os.OpenFile("app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	logFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		log.Fatalf("Failed to open log file: %v", err)
 	}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
@@ -11,27 +12,87 @@ import (
 // Global logger
 var logger *log.Logger
 
+// app holds the configuration and services shared by the TUI and the CLI
+type app struct {
+	*Config
+	llm LLMProvider
+}
+
 func main() {
+	// Load the configuration before anything is logged
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "amalgia: %v\n", err)
+		os.Exit(exitError)
+	}
+
 	// Initialize the logger
-	logger = InitializeLogger()
+	logger = InitializeLogger(cfg.Paths.LogFile)
 	logger.Println("Application started.")
+	if len(cfg.Files) > 0 {
+		logger.Printf("Loaded configuration from %v (env %q).", cfg.Files, cfg.Env)
+	}
 
-	// Check for required environment variables
-	requiredEnvVars := []string{"OPENAI_API_KEY", "GITHUB_TOKEN"}
-	for _, envVar := range requiredEnvVars {
-		if os.Getenv(envVar) == "" {
-			logger.Fatalf("Error: %s environment variable is not set", envVar)
-		}
+	// Subcommands run headless and exit with their own status code
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(cfg, os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	a, err := loadApp(cfg, true)
+	if err != nil {
+		logger.Fatalf("Error: %v", err)
 	}
 
 	// Create a new Bubble Tea program and pass it to the model
 	var p *tea.Program
-	m := initialModel(p)
+	m := initialModel(p, a)
 	p = tea.NewProgram(m)
 	m.program = p // Now set the program in the model
 
 	// Run the Bubble Tea program
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	if err := activeCassette.Close(); err != nil {
+		logger.Printf("Error: %v", err)
+	}
+	if err != nil {
 		logger.Fatalf("Error running program: %v", err)
 	}
 }
+
+// loadApp validates the configuration and, when needLLM is set, creates the LLM provider
+func loadApp(cfg *Config, needLLM bool) (*app, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	// Open the HTTP cassette when recording or replaying
+	cassette, err := cassetteFromConfig(cfg.Cassette)
+	if err != nil {
+		return nil, fmt.Errorf("opening cassette: %v", err)
+	}
+	activeCassette = cassette
+	if activeCassette != nil {
+		logger.Printf("HTTP cassette mode %s using %s.", activeCassette.mode, activeCassette.path)
+	}
+
+	a := &app{Config: cfg}
+	if !needLLM {
+		return a, nil
+	}
+
+	// Check for the environment variables the chosen provider needs.
+	// GITHUB_TOKEN is checked when fetching so offline runs still start.
+	for _, envVar := range a.LLM.requiredEnvVars() {
+		if os.Getenv(envVar) == "" && !activeCassette.replaying() {
+			return nil, fmt.Errorf("%s environment variable is not set", envVar)
+		}
+	}
+
+	a.llm, err = newLLMProvider(a.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("creating LLM provider: %v", err)
+	}
+	logger.Printf("Using LLM provider %s with model %s.", a.llm.Name(), a.LLM.Model)
+
+	return a, nil
+}
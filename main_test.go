@@ -0,0 +1,15 @@
+// Filename: main_test.go
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Commands and actions log through the global logger
+	logger = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
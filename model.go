@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"io/fs"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -20,6 +24,8 @@ const (
 	stateSelectREADMEs   = "selecting_readmes"
 	stateViewingLogs     = "viewing_logs"
 	stateChatWithProfile = "chat_with_profile" // New state
+	stateEditingProfile  = "editing_profile"
+	stateSelectFormat    = "selecting_format"
 )
 
 // Constants for actions
@@ -27,6 +33,7 @@ const (
 	actionGenerateResume      = "generate_resume"
 	actionGenerateCoverLetter = "generate_cover_letter"
 	actionFetchREADMEs        = "fetch_readmes"
+	actionScanLocalRepos      = "scan_local_repos"
 	actionChatWithProfile     = "chat_with_profile" // New action
 )
 
@@ -49,28 +56,48 @@ var (
 type model struct {
 	choices         []fs.DirEntry // Directory entries for file selection
 	cursor          int
-	selected        []string          // Selected files
-	directory       string            // Current directory
-	readmes         map[string]string // Map of README contents
-	readmeList      []string          // List of README names
-	selectedREADMEs map[string]bool   // Map to track selected READMEs
-	state           string            // Current application state
-	err             error             // Error message
-	spinner         spinner.Model     // Spinner model
-	spinnerActive   bool              // Spinner active status
-	progress        progress.Model    // Progress bar model
-	progressActive  bool              // Progress bar active status
-	message         string            // Message to display
-	action          string            // Current action
-	startTime       time.Time         // Action start time
-	logs            []string          // Slice to hold recent log messages
-	logLimit        int               // Maximum number of log messages to keep
-	fetchedCount    int               // Number of fetched READMEs
+	selected        []string                 // Selected files
+	fileErrors      map[string]string        // Import errors of selected files, by path
+	directory       string                   // Current directory
+	readmes         map[string]string        // Map of README contents
+	readmeList      []string                 // List of README names
+	repoMetadata    map[string]*RepoMetadata // Repository metadata by README name
+	readmeSources   map[string]string        // Source of each README by name, from the sync index
+	selectedREADMEs map[string]bool          // Map to track selected READMEs
+	state           string                   // Current application state
+	err             error                    // Error message
+	spinner         spinner.Model            // Spinner model
+	spinnerActive   bool                     // Spinner active status
+	progress        progress.Model           // Progress bar model
+	progressActive  bool                     // Progress bar active status
+	message         string                   // Message to display
+	action          string                   // Current action
+	startTime       time.Time                // Action start time
+	logs            []string                 // Slice to hold recent log messages
+	logLimit        int                      // Maximum number of log messages to keep
+	fetchedCount    int                      // Number of fetched READMEs
 	totalRepos      int
 	failedCount     int
 	program         *tea.Program
-	chatHistory     []string // Stores the chat messages
-	chatInput       string   // Stores the current user input
+	chatHistory     []string           // Stores the chat messages
+	chatInput       string             // Stores the current user input
+	profile         *Profile           // Structured profile used by all generation
+	profileFields   []profileField     // Fields shown in the profile editor
+	profileInput    textinput.Model    // Input for the field being edited
+	editingField    bool               // Whether a profile field is being edited
+	llm             LLMProvider        // Provider used by the AI actions
+	llmConfig       LLMConfig          // Model and generation settings
+	sources         []string           // Services READMEs are fetched from
+	githubConfig    GitHubConfig       // Repository selection for fetching
+	gitlabConfig    ForgeConfig        // GitLab instance and project selection
+	giteaConfig     ForgeConfig        // Gitea/Forgejo instance and repository selection
+	localConfig     LocalConfig        // Directories scanned for local repositories
+	resumeConfig    ResumeConfig       // Resume format and theme; the TUI changes them for the session
+	paths           PathsConfig        // Configured files and directories
+	prompts         PromptsConfig      // System prompts of the AI actions
+	rateLimit       RateLimitMsg       // Latest rate-limit status of the current sync
+	cancelAction    context.CancelFunc // Cancels the in-flight action, if any
+	actionID        int                // Identifies the latest action; messages of earlier ones are dropped
 }
 
 // Init is the first method that gets called. It sets up the model.
@@ -80,7 +107,7 @@ func (m *model) Init() tea.Cmd {
 }
 
 // Initialize the model
-func initialModel(p *tea.Program) *model {
+func initialModel(p *tea.Program, a *app) *model {
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -99,12 +126,38 @@ func initialModel(p *tea.Program) *model {
 	// Initialize progress bar
 	pr := progress.New(progressBarStyle)
 
+	// Populate the README selection from the last sync, if any
+	cached, err := loadCachedREADMEs(a.Paths.ReadmesDir)
+	if err != nil {
+		logger.Printf("Failed to load cached READMEs: %v", err)
+		cached = FetchCompleteMsg{
+			Readmes:       make(map[string]string),
+			Metadata:      make(map[string]*RepoMetadata),
+			ReadmeSources: make(map[string]string),
+			Names:         []string{},
+		}
+	}
+
+	// Load the profile and mark the READMEs it already contains
+	profile, err := loadProfile(a.Paths.ProfileFile)
+	if err != nil {
+		logger.Printf("Failed to load profile: %v", err)
+		profile = &Profile{}
+	}
+	selectedREADMEs := make(map[string]bool)
+	for _, name := range profile.readmeProjects() {
+		selectedREADMEs[name] = true
+	}
+
 	return &model{
 		choices:         files,
 		directory:       cwd,
-		readmes:         make(map[string]string),
-		readmeList:      []string{},
-		selectedREADMEs: make(map[string]bool),
+		fileErrors:      make(map[string]string),
+		readmes:         cached.Readmes,
+		readmeList:      cached.Names,
+		repoMetadata:    cached.Metadata,
+		readmeSources:   cached.ReadmeSources,
+		selectedREADMEs: selectedREADMEs,
 		state:           stateSelectingFiles,
 		spinner:         sp,
 		progress:        pr,
@@ -113,6 +166,98 @@ func initialModel(p *tea.Program) *model {
 		program:         p,
 		chatHistory:     []string{},
 		chatInput:       "",
+		profile:         profile,
+		profileInput:    newProfileInput(),
+		llm:             a.llm,
+		llmConfig:       a.LLM,
+		sources:         a.Sources,
+		githubConfig:    a.GitHub,
+		gitlabConfig:    a.GitLab,
+		giteaConfig:     a.Gitea,
+		localConfig:     a.Local,
+		resumeConfig:    a.Resume,
+		paths:           a.Paths,
+		prompts:         a.Prompts,
+	}
+}
+
+// mergeReadmes replaces the READMEs from the source of a completed sync,
+// keeping those synced from other sources in the selection
+func (m *model) mergeReadmes(msg FetchCompleteMsg) {
+	readmes := make(map[string]string)
+	metadata := make(map[string]*RepoMetadata)
+	sources := make(map[string]string)
+	var names []string
+	for _, name := range m.readmeList {
+		if !contains(msg.Sources, m.readmeSources[name]) {
+			readmes[name] = m.readmes[name]
+			if md := m.repoMetadata[name]; md != nil {
+				metadata[name] = md
+			}
+			sources[name] = m.readmeSources[name]
+			names = append(names, name)
+		}
+	}
+	for _, name := range msg.Names {
+		readmes[name] = msg.Readmes[name]
+		if md := msg.Metadata[name]; md != nil {
+			metadata[name] = md
+		}
+		sources[name] = msg.ReadmeSources[name]
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m.readmes, m.readmeList, m.repoMetadata, m.readmeSources = readmes, names, metadata, sources
+}
+
+// newActionContext returns a context that is cancelled when the user aborts
+// the action, and starts a new action ID for the messages the action sends
+func (m *model) newActionContext() context.Context {
+	m.finishAction()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelAction = cancel
+	m.actionID++
+	return ctx
+}
+
+// finishAction releases the context of the in-flight action
+func (m *model) finishAction() {
+	if m.cancelAction != nil {
+		m.cancelAction()
+		m.cancelAction = nil
+	}
+}
+
+// LogMsg carries a log line from a tea.Cmd goroutine to Update
+type LogMsg string
+
+// actionMsg tags a message of a background command with the action that sent
+// it, so that results arriving after the action was cancelled are not applied
+// to the next one
+type actionMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// sender returns a function that tea.Cmd goroutines use to deliver messages
+// of the current action to Update. Outside the TUI, log lines go straight to
+// the logger.
+func (m *model) sender() func(tea.Msg) {
+	program, id := m.program, m.actionID
+	return func(msg tea.Msg) {
+		if program != nil {
+			program.Send(actionMsg{id: id, msg: msg})
+		} else if line, ok := msg.(LogMsg); ok {
+			logger.Println(string(line))
+		}
+	}
+}
+
+// tagged tags the result of cmd with the current action
+func (m *model) tagged(cmd tea.Cmd) tea.Cmd {
+	id := m.actionID
+	return func() tea.Msg {
+		return actionMsg{id: id, msg: cmd()}
 	}
 }
 
@@ -136,6 +281,27 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
+// nextName returns the name after current in names, wrapping around
+func nextName(names []string, current string) string {
+	for i, name := range names {
+		if name == current {
+			return names[(i+1)%len(names)]
+		}
+	}
+	return names[0]
+}
+
+// splitList splits a comma-separated list, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // remove removes an item from a slice
 func remove(slice []string, item string) []string {
 	newSlice := []string{}
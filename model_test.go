@@ -0,0 +1,36 @@
+// Filename: model_test.go
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeReadmesUsesStoredSources(t *testing.T) {
+	m := newTestModel(t, nil)
+	// A GitHub owner named like a source must not pass for that source
+	m.readmes = map[string]string{"local/tool": "# Tool", "gitlab/site": "# Site", "local/old": "# Old"}
+	m.readmeList = []string{"gitlab/site", "local/old", "local/tool"}
+	m.readmeSources = map[string]string{"local/tool": sourceGitHub, "gitlab/site": sourceGitHub, "local/old": sourceLocal}
+
+	m.mergeReadmes(FetchCompleteMsg{
+		Sources:       []string{sourceLocal},
+		Readmes:       map[string]string{"local/new": "# New"},
+		ReadmeSources: map[string]string{"local/new": sourceLocal},
+		Names:         []string{"local/new"},
+	})
+
+	if got, want := fmt.Sprint(m.readmeList), "[gitlab/site local/new local/tool]"; got != want {
+		t.Errorf("READMEs after a local scan = %s, want %s", got, want)
+	}
+	if m.readmeSources["local/tool"] != sourceGitHub || m.readmeSources["local/new"] != sourceLocal {
+		t.Errorf("sources = %v", m.readmeSources)
+	}
+
+	m.profile.selectReadmes(m.readmeList, m.readmes, m.readmeSources)
+	for _, project := range m.profile.Projects {
+		if project.Source != m.readmeSources[project.Name] {
+			t.Errorf("project %s has source %q, want %q", project.Name, project.Source, m.readmeSources[project.Name])
+		}
+	}
+}
@@ -0,0 +1,407 @@
+// Filename: office.go
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// headingStylePattern matches Word heading style names such as "heading 2"
+var headingStylePattern = regexp.MustCompile(`(?i)^heading\s*(\d)$`)
+
+// blankLinesPattern matches runs of blank lines
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// markdownWriter collects Markdown-ish blocks extracted from office documents
+type markdownWriter struct {
+	b         strings.Builder
+	tableRows int // Rows written in the current table
+}
+
+func (w *markdownWriter) heading(level int, text string) {
+	if text == "" {
+		return
+	}
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	w.b.WriteString("\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+}
+
+func (w *markdownWriter) listItem(level int, text string) {
+	if text != "" {
+		w.b.WriteString(strings.Repeat("  ", level) + "- " + text + "\n")
+	}
+}
+
+func (w *markdownWriter) paragraph(text string) {
+	if text != "" {
+		w.b.WriteString("\n" + text + "\n\n")
+	}
+}
+
+// tableRow writes a row, adding the header separator after the first one
+func (w *markdownWriter) tableRow(cells []string) {
+	if len(cells) == 0 {
+		return
+	}
+	if w.tableRows == 0 {
+		w.b.WriteString("\n")
+	}
+	w.b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	if w.tableRows == 0 {
+		w.b.WriteString(strings.Repeat("| --- ", len(cells)) + "|\n")
+	}
+	w.tableRows++
+}
+
+func (w *markdownWriter) endTable() {
+	w.tableRows = 0
+	w.b.WriteString("\n")
+}
+
+// String returns the text with runs of blank lines collapsed
+func (w *markdownWriter) String() string {
+	text := blankLinesPattern.ReplaceAllString(w.b.String(), "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
+
+// officeTable tracks the rows and cells of the table being read
+type officeTable struct {
+	depth int
+	row   []string
+	cell  []string
+}
+
+// addText adds a paragraph to the current cell
+func (t *officeTable) addText(text string) {
+	if text != "" {
+		t.cell = append(t.cell, text)
+	}
+}
+
+// endCell closes the current cell; pipes are escaped so the row stays intact
+func (t *officeTable) endCell() {
+	t.row = append(t.row, strings.ReplaceAll(strings.Join(t.cell, " "), "|", "\\|"))
+	t.cell = nil
+}
+
+// openZipEntry opens a file inside a zip archive
+func openZipEntry(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("%s not found; the file may be damaged", name)
+}
+
+// xmlAttr returns the value of the attribute with the given local name
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, attr := range e.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// cleanText collapses whitespace within a paragraph
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// docxParagraph is a Word paragraph being read
+type docxParagraph struct {
+	text  strings.Builder
+	style string
+	list  bool
+	level int
+}
+
+// extractDOCXText converts a Word document to Markdown-ish text
+func extractDOCXText(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open DOCX: %v", err)
+	}
+	defer zr.Close()
+
+	headings, err := docxHeadingStyles(&zr.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := openZipEntry(&zr.Reader, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var w markdownWriter
+	var table officeTable
+	var paras []*docxParagraph // Text boxes nest paragraphs inside paragraphs
+	inText, inProps := false, false
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not parse DOCX: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			var para *docxParagraph
+			if len(paras) > 0 {
+				para = paras[len(paras)-1]
+			}
+			switch t.Name.Local {
+			case "Fallback":
+				// Legacy copy of drawings and text boxes that are also in Choice
+				if err := decoder.Skip(); err != nil {
+					return "", fmt.Errorf("could not parse DOCX: %v", err)
+				}
+			case "p":
+				paras = append(paras, &docxParagraph{})
+			case "pPr":
+				inProps = true
+			case "pStyle":
+				if para != nil {
+					para.style = xmlAttr(t, "val")
+				}
+			case "numPr":
+				if para != nil {
+					para.list = true
+				}
+			case "ilvl":
+				if para != nil {
+					para.level, _ = strconv.Atoi(xmlAttr(t, "val"))
+				}
+			case "t":
+				inText = true
+			case "tab":
+				// Tabs inside paragraph properties are tab stops, not text
+				if para != nil && !inProps {
+					para.text.WriteString(" ")
+				}
+			case "br", "cr":
+				if para != nil {
+					para.text.WriteString(" ")
+				}
+			case "tbl":
+				table.depth++
+			case "tr":
+				if table.depth == 1 {
+					table.row = nil
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.cell = nil
+				}
+			}
+
+		case xml.CharData:
+			if inText && len(paras) > 0 {
+				paras[len(paras)-1].text.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				inProps = false
+			case "p":
+				if len(paras) == 0 {
+					continue
+				}
+				para := paras[len(paras)-1]
+				paras = paras[:len(paras)-1]
+				text := cleanText(para.text.String())
+				switch {
+				case table.depth > 0:
+					table.addText(text)
+				case headings[para.style] > 0:
+					w.heading(headings[para.style], text)
+				case para.list || strings.HasPrefix(strings.ToLower(para.style), "list"):
+					w.listItem(para.level, text)
+				default:
+					w.paragraph(text)
+				}
+			case "tc":
+				if table.depth == 1 {
+					table.endCell()
+				}
+			case "tr":
+				if table.depth == 1 {
+					w.tableRow(table.row)
+				}
+			case "tbl":
+				table.depth--
+				if table.depth == 0 {
+					w.endTable()
+				}
+			}
+		}
+	}
+
+	return w.String(), nil
+}
+
+// docxHeadingStyles maps style IDs to heading levels using the style names,
+// which stay in English when the IDs are localized
+func docxHeadingStyles(zr *zip.Reader) (map[string]int, error) {
+	headings := map[string]int{"Title": 1}
+	for level := 1; level <= 6; level++ {
+		headings[fmt.Sprintf("Heading%d", level)] = level
+	}
+
+	rc, err := openZipEntry(zr, "word/styles.xml")
+	if err != nil {
+		// Styles are optional; fall back to the built-in IDs
+		return headings, nil
+	}
+	defer rc.Close()
+
+	var styles struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+		} `xml:"style"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&styles); err != nil {
+		return nil, fmt.Errorf("could not parse DOCX styles: %v", err)
+	}
+	for _, style := range styles.Styles {
+		name := strings.ToLower(style.Name.Val)
+		if name == "title" {
+			headings[style.ID] = 1
+		} else if match := headingStylePattern.FindStringSubmatch(name); match != nil {
+			headings[style.ID], _ = strconv.Atoi(match[1])
+		}
+	}
+	return headings, nil
+}
+
+// extractODTText converts an OpenDocument text file to Markdown-ish text
+func extractODTText(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open ODT: %v", err)
+	}
+	defer zr.Close()
+
+	rc, err := openZipEntry(&zr.Reader, "content.xml")
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var w markdownWriter
+	var table officeTable
+	var blocks []*strings.Builder // Frames nest paragraphs inside paragraphs
+	listDepth, headingLevel := 0, 0
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not parse ODT: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "note", "annotation", "tracked-changes":
+				// Footnotes, comments and deleted text are not part of the body
+				if err := decoder.Skip(); err != nil {
+					return "", fmt.Errorf("could not parse ODT: %v", err)
+				}
+			case "h":
+				blocks = append(blocks, &strings.Builder{})
+				headingLevel, _ = strconv.Atoi(xmlAttr(t, "outline-level"))
+				if headingLevel == 0 {
+					headingLevel = 1
+				}
+			case "p":
+				blocks = append(blocks, &strings.Builder{})
+			case "s", "tab", "line-break":
+				if len(blocks) > 0 {
+					blocks[len(blocks)-1].WriteString(" ")
+				}
+			case "list":
+				listDepth++
+			case "table":
+				table.depth++
+			case "table-row":
+				if table.depth == 1 {
+					table.row = nil
+				}
+			case "table-cell":
+				if table.depth == 1 {
+					table.cell = nil
+				}
+			}
+
+		case xml.CharData:
+			if len(blocks) > 0 {
+				blocks[len(blocks)-1].Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "h", "p":
+				if len(blocks) == 0 {
+					continue
+				}
+				content := cleanText(blocks[len(blocks)-1].String())
+				blocks = blocks[:len(blocks)-1]
+				switch {
+				case table.depth > 0:
+					table.addText(content)
+				case t.Name.Local == "h":
+					w.heading(headingLevel, content)
+				case listDepth > 0:
+					w.listItem(listDepth-1, content)
+				default:
+					w.paragraph(content)
+				}
+			case "list":
+				listDepth--
+			case "table-cell":
+				if table.depth == 1 {
+					table.endCell()
+				}
+			case "table-row":
+				if table.depth == 1 {
+					w.tableRow(table.row)
+				}
+			case "table":
+				table.depth--
+				if table.depth == 0 {
+					w.endTable()
+				}
+			}
+		}
+	}
+
+	return w.String(), nil
+}
@@ -0,0 +1,136 @@
+// Filename: office_test.go
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestZip writes files to a zip archive named name in a temporary directory
+func writeTestZip(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for fileName, content := range files {
+		w, err := zw.Create(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testDOCXDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" xmlns:wps="http://schemas.microsoft.com/office/word/2010/wordprocessingShape">
+<w:body>
+<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Jane Doe</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Berschrift1"/><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t>Experience</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Engineer at </w:t></w:r><w:r><w:t>Example</w:t></w:r><w:r><w:tab/><w:t>2020</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Built the billing platform</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Cut costs by 30%</w:t></w:r></w:p>
+<w:p><w:r><mc:AlternateContent><mc:Choice Requires="wps"><w:drawing><wps:txbx><w:txbxContent><w:p><w:r><w:t>Sidebar note</w:t></w:r></w:p></w:txbxContent></wps:txbx></w:drawing></mc:Choice><mc:Fallback><w:pict><w:p><w:r><w:t>Sidebar note</w:t></w:r></w:p></w:pict></mc:Fallback></mc:AlternateContent></w:r></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>Skill</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Years</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>Go</w:t></w:r></w:p><w:p><w:r><w:t>Rust</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>5 | 2</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+</w:body>
+</w:document>`
+
+const testDOCXStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:style w:type="paragraph" w:styleId="Berschrift1"><w:name w:val="heading 1"/></w:style>
+</w:styles>`
+
+func TestExtractDOCXText(t *testing.T) {
+	path := writeTestZip(t, "resume.docx", map[string]string{
+		"word/document.xml": testDOCXDocument,
+		"word/styles.xml":   testDOCXStyles,
+	})
+
+	text, err := extractDOCXText(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Jane Doe\n\n" +
+		"# Experience\n\n" +
+		"Engineer at Example 2020\n\n" +
+		"- Built the billing platform\n" +
+		"  - Cut costs by 30%\n\n" +
+		"Sidebar note\n\n" +
+		"| Skill | Years |\n" +
+		"| --- | --- |\n" +
+		"| Go Rust | 5 \\| 2 |\n"
+	if text != want {
+		t.Errorf("got:\n%s\nwant:\n%s", text, want)
+	}
+}
+
+func TestExtractDOCXTextErrors(t *testing.T) {
+	if _, err := extractDOCXText(writeTestZip(t, "empty.docx", map[string]string{"other.xml": "<x/>"})); err == nil {
+		t.Error("a DOCX without word/document.xml was accepted")
+	}
+	broken := filepath.Join(t.TempDir(), "broken.docx")
+	if err := os.WriteFile(broken, []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractDOCXText(broken); err == nil {
+		t.Error("a file that is not a zip was accepted")
+	}
+}
+
+const testODTContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0">
+<office:body><office:text>
+<text:tracked-changes><text:changed-region><text:deletion><text:p>Deleted text</text:p></text:deletion></text:changed-region></text:tracked-changes>
+<text:h text:outline-level="1">Jane Doe</text:h>
+<text:h text:outline-level="2">Experience</text:h>
+<text:p>Engineer at<text:s/>Example<text:note><text:note-body><text:p>A footnote</text:p></text:note-body></text:note></text:p>
+<text:list><text:list-item><text:p>Built the billing platform</text:p>
+<text:list><text:list-item><text:p>Cut costs by 30%</text:p></text:list-item></text:list>
+</text:list-item></text:list>
+<text:p><draw:frame><draw:text-box><text:p>Sidebar note</text:p></draw:text-box></draw:frame></text:p>
+<table:table>
+<table:table-row><table:table-cell><text:p>Skill</text:p></table:table-cell><table:table-cell><text:p>Years</text:p></table:table-cell></table:table-row>
+<table:table-row><table:table-cell><text:p>Go</text:p></table:table-cell><table:table-cell><text:p>5</text:p></table:table-cell></table:table-row>
+</table:table>
+</office:text></office:body>
+</office:document-content>`
+
+func TestExtractODTText(t *testing.T) {
+	path := writeTestZip(t, "resume.odt", map[string]string{"content.xml": testODTContent})
+
+	text, err := extractODTText(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Jane Doe\n\n" +
+		"## Experience\n\n" +
+		"Engineer at Example\n\n" +
+		"- Built the billing platform\n" +
+		"  - Cut costs by 30%\n\n" +
+		"Sidebar note\n\n" +
+		"| Skill | Years |\n" +
+		"| --- | --- |\n" +
+		"| Go | 5 |\n"
+	if text != want {
+		t.Errorf("got:\n%s\nwant:\n%s", text, want)
+	}
+
+	if _, err := extractODTText(writeTestZip(t, "empty.odt", map[string]string{"meta.xml": "<x/>"})); err == nil {
+		t.Error("an ODT without content.xml was accepted")
+	}
+}
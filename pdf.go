@@ -0,0 +1,242 @@
+// Filename: pdf.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// resumeSectionPattern matches the headings resumes are usually divided into
+var resumeSectionPattern = regexp.MustCompile(`(?i)^(summary|profile|about( me)?|objective|(work |professional )?experience|employment( history)?|work history|education|(technical |core )?skills|technologies|projects|certifications?|licenses( & certifications)?|awards|honors|publications|languages|interests|volunteer(ing)?|contact|references)$`)
+
+// pdfGlyph is a positioned run of text on a page
+type pdfGlyph struct {
+	x, y, w, size float64
+	bold          bool
+	s             string
+}
+
+// pdfLine is the text on one baseline
+type pdfLine struct {
+	y, size float64
+	bold    bool
+	text    string
+}
+
+// extractPDFText returns the text of a PDF as Markdown-ish lines, reading
+// two-column layouts column by column and marking detected section headings
+func extractPDFText(path string) (text string, err error) {
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("could not parse PDF: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not parse PDF: %v", err)
+	}
+	defer f.Close()
+
+	var lines []pdfLine
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		var glyphs []pdfGlyph
+		for _, t := range page.Content().Text {
+			glyphs = append(glyphs, pdfGlyph{
+				x:    t.X,
+				y:    t.Y,
+				w:    t.W,
+				size: t.FontSize,
+				bold: isBoldFont(t.Font),
+				s:    t.S,
+			})
+		}
+		for _, column := range splitPDFColumns(glyphs) {
+			lines = append(lines, groupPDFLines(column)...)
+		}
+	}
+
+	text = formatPDFLines(lines)
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("no text found in PDF; scanned documents are not supported")
+	}
+	return text, nil
+}
+
+// isBoldFont guesses the weight from the font name
+func isBoldFont(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "bold") || strings.Contains(name, "black") || strings.Contains(name, "heavy")
+}
+
+// splitPDFColumns splits a page at a vertical gutter in its middle half,
+// so sidebars and two-column resumes are not interleaved line by line
+func splitPDFColumns(glyphs []pdfGlyph) [][]pdfGlyph {
+	if len(glyphs) == 0 {
+		return nil
+	}
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, g := range glyphs {
+		minX = math.Min(minX, g.x)
+		maxX = math.Max(maxX, g.x+g.w)
+	}
+	width := maxX - minX
+	if width <= 0 {
+		return [][]pdfGlyph{glyphs}
+	}
+
+	// Mark the horizontal positions covered by any glyph
+	const buckets = 200
+	var covered [buckets]bool
+	for _, g := range glyphs {
+		if strings.TrimSpace(g.s) == "" {
+			continue
+		}
+		from := int((g.x - minX) / width * buckets)
+		to := int((g.x + g.w - minX) / width * buckets)
+		for b := from; b <= to && b < buckets; b++ {
+			covered[b] = true
+		}
+	}
+
+	// Find the widest empty run in the middle half of the page
+	bestStart, bestLen := -1, 0
+	for b := buckets / 4; b < buckets*3/4; b++ {
+		if covered[b] {
+			continue
+		}
+		run := b
+		for run < buckets*3/4 && !covered[run] {
+			run++
+		}
+		if run-b > bestLen {
+			bestStart, bestLen = b, run-b
+		}
+		b = run
+	}
+	if bestLen < buckets/50 {
+		return [][]pdfGlyph{glyphs}
+	}
+
+	gutter := minX + float64(bestStart)*width/buckets
+	var left, right []pdfGlyph
+	for _, g := range glyphs {
+		if g.x < gutter {
+			left = append(left, g)
+		} else {
+			right = append(right, g)
+		}
+	}
+	// A gutter with little text on one side is just whitespace, not a column
+	if len(left) < len(glyphs)/10 || len(right) < len(glyphs)/10 {
+		return [][]pdfGlyph{glyphs}
+	}
+	return [][]pdfGlyph{left, right}
+}
+
+// groupPDFLines joins glyphs that share a baseline into lines, top to bottom
+func groupPDFLines(glyphs []pdfGlyph) []pdfLine {
+	sort.SliceStable(glyphs, func(i, j int) bool {
+		if math.Abs(glyphs[i].y-glyphs[j].y) > 2 {
+			return glyphs[i].y > glyphs[j].y
+		}
+		return glyphs[i].x < glyphs[j].x
+	})
+
+	var lines []pdfLine
+	var b strings.Builder
+	var line pdfLine
+	var prevEnd float64
+	bold, boldCount := true, 0
+	flush := func() {
+		if text := strings.Join(strings.Fields(b.String()), " "); text != "" {
+			line.text = text
+			line.bold = bold && boldCount > 0
+			lines = append(lines, line)
+		}
+		b.Reset()
+		bold, boldCount = true, 0
+	}
+
+	for i, g := range glyphs {
+		if i == 0 || math.Abs(g.y-line.y) > 2 {
+			if i > 0 {
+				flush()
+			}
+			line = pdfLine{y: g.y, size: g.size}
+		} else if g.x-prevEnd > g.size*0.2 {
+			// Gaps wider than a fifth of the font size separate words
+			b.WriteString(" ")
+		}
+		b.WriteString(g.s)
+		prevEnd = g.x + g.w
+		if g.size > line.size {
+			line.size = g.size
+		}
+		if strings.TrimSpace(g.s) != "" {
+			bold = bold && g.bold
+			boldCount++
+		}
+	}
+	flush()
+	return lines
+}
+
+// formatPDFLines writes lines as text, turning headings into "## " lines and
+// vertical gaps into paragraph breaks
+func formatPDFLines(lines []pdfLine) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// The most common font size is the body text
+	counts := map[float64]int{}
+	bodySize := lines[0].size
+	for _, line := range lines {
+		size := math.Round(line.size)
+		counts[size]++
+		if counts[size] > counts[math.Round(bodySize)] {
+			bodySize = size
+		}
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if isPDFHeading(line, bodySize) {
+			b.WriteString("\n## " + strings.TrimRight(line.text, ":") + "\n")
+			continue
+		}
+		if i > 0 {
+			gap := lines[i-1].y - line.y
+			if gap > line.size*1.8 && gap < line.size*10 {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(line.text + "\n")
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// isPDFHeading decides whether a line starts a resume section: a known section
+// name, or a short line set noticeably larger than the body text
+func isPDFHeading(line pdfLine, bodySize float64) bool {
+	text := strings.TrimRight(strings.TrimSpace(line.text), ":")
+	if len(strings.Fields(text)) > 5 {
+		return false
+	}
+	emphasized := line.bold || line.size > bodySize*1.15 || text == strings.ToUpper(text)
+	if resumeSectionPattern.MatchString(text) {
+		return emphasized
+	}
+	return line.size > bodySize*1.3
+}
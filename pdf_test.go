@@ -0,0 +1,169 @@
+// Filename: pdf_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// pdfWords lays out words left to right on a baseline, 5 units per character
+func pdfWords(x, y float64, text string) []pdfGlyph {
+	var glyphs []pdfGlyph
+	for _, word := range strings.Fields(text) {
+		w := float64(len(word)) * 5
+		glyphs = append(glyphs, pdfGlyph{x: x, y: y, w: w, size: 10, s: word})
+		x += w + 5
+	}
+	return glyphs
+}
+
+func TestSplitPDFColumns(t *testing.T) {
+	var twoColumn []pdfGlyph
+	for i, row := range []struct{ left, right string }{
+		{"Skills", "Experience"},
+		{"Go Rust", "Senior engineer at Example"},
+		{"Kubernetes", "Built the billing platform"},
+		{"Postgres", "Led a team of four"},
+	} {
+		y := 700 - float64(i)*14
+		twoColumn = append(twoColumn, pdfWords(50, y, row.left)...)
+		twoColumn = append(twoColumn, pdfWords(300, y, row.right)...)
+	}
+
+	columns := splitPDFColumns(twoColumn)
+	if len(columns) != 2 {
+		t.Fatalf("got %d columns, want 2", len(columns))
+	}
+	var texts []string
+	for _, column := range columns {
+		var lines []string
+		for _, line := range groupPDFLines(column) {
+			lines = append(lines, line.text)
+		}
+		texts = append(texts, strings.Join(lines, "|"))
+	}
+	if texts[0] != "Skills|Go Rust|Kubernetes|Postgres" {
+		t.Errorf("left column = %q", texts[0])
+	}
+	if texts[1] != "Experience|Senior engineer at Example|Built the billing platform|Led a team of four" {
+		t.Errorf("right column = %q", texts[1])
+	}
+}
+
+func TestSplitPDFColumnsSingleColumn(t *testing.T) {
+	for name, glyphs := range map[string][]pdfGlyph{
+		// Full-width lines cover the middle of the page
+		"full width": append(pdfWords(50, 700, "A long sentence that runs across the whole page width"),
+			pdfWords(50, 686, "and another one that also runs across the page width")...),
+		// A gap with only a stray word on one side is not a gutter
+		"stray word": append(append(pdfWords(50, 700, "Left aligned text on every line"),
+			pdfWords(50, 686, "More left aligned text on this line")...),
+			append(pdfWords(50, 672, "Still more left aligned body text"),
+				pdfWords(50, 658, "And the last line of body text")...)...),
+	} {
+		if name == "stray word" {
+			glyphs = append(glyphs, pdfGlyph{x: 500, y: 644, w: 10, size: 10, s: "1"})
+		}
+		if columns := splitPDFColumns(glyphs); len(columns) != 1 {
+			t.Errorf("%s: got %d columns, want 1", name, len(columns))
+		}
+	}
+	if columns := splitPDFColumns(nil); columns != nil {
+		t.Errorf("empty page: got %v", columns)
+	}
+}
+
+func TestGroupPDFLines(t *testing.T) {
+	glyphs := []pdfGlyph{
+		// Out of order, with a baseline jitter within the tolerance
+		{x: 80, y: 699, w: 20, size: 10, s: "World"},
+		{x: 50, y: 700, w: 25, size: 10, bold: true, s: "Hello"},
+		// Adjacent runs without a gap belong to the same word
+		{x: 50, y: 680, w: 10, size: 12, bold: true, s: "Ex"},
+		{x: 60, y: 680, w: 30, size: 12, bold: true, s: "perience"},
+	}
+
+	lines := groupPDFLines(glyphs)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %+v", len(lines), lines)
+	}
+	if lines[0].text != "Hello World" || lines[0].bold {
+		t.Errorf("first line = %+v, want non-bold \"Hello World\"", lines[0])
+	}
+	if lines[1].text != "Experience" || !lines[1].bold || lines[1].size != 12 {
+		t.Errorf("second line = %+v, want bold 12pt \"Experience\"", lines[1])
+	}
+}
+
+func TestIsPDFHeading(t *testing.T) {
+	tests := []struct {
+		line pdfLine
+		want bool
+	}{
+		{pdfLine{text: "Experience", size: 10, bold: true}, true},
+		{pdfLine{text: "EDUCATION", size: 10}, true},
+		{pdfLine{text: "Skills:", size: 12}, true},
+		{pdfLine{text: "Professional Experience", size: 10, bold: true}, true},
+		// Section names in body text are not headings
+		{pdfLine{text: "Projects", size: 10}, false},
+		// Other short lines only when clearly larger than the body
+		{pdfLine{text: "Jane Doe", size: 18}, true},
+		{pdfLine{text: "Jane Doe", size: 11}, false},
+		{pdfLine{text: "Acme Corp", size: 10, bold: true}, false},
+		// Long lines never are
+		{pdfLine{text: "Led the migration of six services to Kubernetes", size: 18, bold: true}, false},
+	}
+	for _, tt := range tests {
+		if got := isPDFHeading(tt.line, 10); got != tt.want {
+			t.Errorf("isPDFHeading(%+v) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPDFLines(t *testing.T) {
+	lines := []pdfLine{
+		{y: 760, size: 18, text: "Jane Doe"},
+		{y: 730, size: 10, bold: true, text: "Experience"},
+		{y: 716, size: 10, text: "Engineer at Example"},
+		{y: 702, size: 10, text: "Built things"},
+		{y: 670, size: 10, text: "Engineer at Other"},
+	}
+	want := "## Jane Doe\n\n## Experience\nEngineer at Example\nBuilt things\n\nEngineer at Other\n"
+	if got := formatPDFLines(lines); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExtractPDFText(t *testing.T) {
+	doc := gofpdf.New("P", "pt", "A4", "")
+	doc.AddPage()
+	doc.SetFont("Helvetica", "B", 11)
+	doc.Text(50, 80, "Experience")
+	doc.SetFont("Helvetica", "", 11)
+	doc.Text(50, 96, "Engineer at Example")
+	doc.Text(50, 112, "Built the billing platform")
+	path := filepath.Join(t.TempDir(), "resume.pdf")
+	if err := doc.OutputFileAndClose(path); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := extractPDFText(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Experience\nEngineer at Example\nBuilt the billing platform\n"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+
+	broken := filepath.Join(t.TempDir(), "broken.pdf")
+	if err := os.WriteFile(broken, []byte("not a pdf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractPDFText(broken); err == nil {
+		t.Error("a file that is not a PDF was accepted")
+	}
+}
@@ -0,0 +1,577 @@
+// Filename: profile.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultProfileFile is where the structured profile is stored by default
+const defaultProfileFile = "profile.json"
+
+// Sources of profile projects
+const (
+	sourceGitHub   = "github"
+	sourceLocal    = "local"
+	sourceGitLab   = "gitlab"
+	sourceGitea    = "gitea"
+	sourceManual   = "manual"
+	sourceLinkedIn = "linkedin"
+)
+
+// Profile is everything known about the candidate; all generation reads from it
+type Profile struct {
+	Contact        Contact             `json:"contact"`
+	Summary        string              `json:"summary,omitempty"`
+	Experience     []Experience        `json:"experience,omitempty"`
+	Education      []Education         `json:"education,omitempty"`
+	Projects       []Project           `json:"projects,omitempty"`
+	Skills         []string            `json:"skills,omitempty"`
+	Stack          []StackSkill        `json:"stack,omitempty"` // Detected from the manifests of README projects
+	Certifications []Certification     `json:"certifications,omitempty"`
+	Contributions  []RepoContributions `json:"contributions,omitempty"` // Activity by repository
+	Links          []Link              `json:"links,omitempty"`
+	Documents      []Document          `json:"documents,omitempty"`
+	UpdatedAt      time.Time           `json:"updated_at,omitempty"`
+}
+
+// Contact holds the candidate's name and how to reach them
+type Contact struct {
+	Name     string `json:"name,omitempty"`
+	Headline string `json:"headline,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// Experience is a position held
+type Experience struct {
+	Company    string   `json:"company"`
+	Title      string   `json:"title,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	StartDate  string   `json:"start_date,omitempty"`
+	EndDate    string   `json:"end_date,omitempty"` // Empty while current
+	Summary    string   `json:"summary,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// Education is a degree or course of study
+type Education struct {
+	Institution string `json:"institution"`
+	Degree      string `json:"degree,omitempty"`
+	Field       string `json:"field,omitempty"`
+	StartDate   string `json:"start_date,omitempty"`
+	EndDate     string `json:"end_date,omitempty"`
+}
+
+// Project is a piece of work worth showing, usually a repository
+type Project struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	Technologies []string     `json:"technologies,omitempty"`
+	Highlights   []string     `json:"highlights,omitempty"`
+	Source       string       `json:"source,omitempty"`       // github, linkedin or manual
+	Readme       string       `json:"readme,omitempty"`       // README text for GitHub projects
+	ReadmeFacts  *ReadmeFacts `json:"readme_facts,omitempty"` // What the README says, without boilerplate
+}
+
+// Certification is a certificate or license
+type Certification struct {
+	Name      string `json:"name"`
+	Authority string `json:"authority,omitempty"`
+	URL       string `json:"url,omitempty"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+}
+
+// Link is a labelled URL such as a portfolio or social profile
+type Link struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// Document is imported free text, such as an existing resume
+type Document struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"` // Absolute path it was imported from, which identifies it
+	Content string `json:"content"`
+}
+
+// loadProfile reads the profile at path, returning an empty one if none exists
+func loadProfile(path string) (*Profile, error) {
+	profile := &Profile{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profile, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return profile, nil
+}
+
+// save writes the profile to path
+func (p *Profile) save(path string) error {
+	p.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data)
+}
+
+// importFile merges a JSON Resume or LinkedIn data export into the profile, or
+// adds any other file's text as a document, replacing an earlier import of the same name
+func (p *Profile) importFile(path string) error {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		linkedIn, err := importLinkedInExport(path)
+		if err != nil {
+			return fmt.Errorf("Error importing %s: %v", filepath.Base(path), err)
+		}
+		p.merge(linkedIn)
+		return nil
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading file %s: %v", path, err)
+		}
+		resume, ok, err := parseJSONResume(content)
+		if err != nil {
+			return fmt.Errorf("Error importing %s: %v", path, err)
+		}
+		if ok {
+			p.merge(resume.profile())
+			return nil
+		}
+	}
+
+	text, err := extractDocumentText(path)
+	if err != nil {
+		return fmt.Errorf("Error importing %s: %v", filepath.Base(path), err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	p.upsertDocument(Document{Name: filepath.Base(path), Path: abs, Content: text})
+	return nil
+}
+
+// merge adds other's entries to the profile. Contact details and the summary
+// are replaced when other sets them; list entries already present are skipped.
+func (p *Profile) merge(other *Profile) {
+	c := &p.Contact
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&c.Name, other.Contact.Name},
+		{&c.Headline, other.Contact.Headline},
+		{&c.Email, other.Contact.Email},
+		{&c.Phone, other.Contact.Phone},
+		{&c.Location, other.Contact.Location},
+		{&p.Summary, other.Summary},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+		}
+	}
+
+	for _, e := range other.Experience {
+		if !p.hasExperience(e) {
+			p.Experience = append(p.Experience, e)
+		}
+	}
+	for _, e := range other.Education {
+		if !p.hasEducation(e) {
+			p.Education = append(p.Education, e)
+		}
+	}
+	for _, project := range other.Projects {
+		if p.project(project.Name) == nil {
+			p.Projects = append(p.Projects, project)
+		}
+	}
+	for _, skill := range other.Skills {
+		if !containsFold(p.Skills, skill) {
+			p.Skills = append(p.Skills, skill)
+		}
+	}
+	for _, cert := range other.Certifications {
+		if !p.hasCertification(cert) {
+			p.Certifications = append(p.Certifications, cert)
+		}
+	}
+	for _, link := range other.Links {
+		if !p.hasLink(link.URL) {
+			p.Links = append(p.Links, link)
+		}
+	}
+	for _, doc := range other.Documents {
+		p.upsertDocument(doc)
+	}
+}
+
+// hasExperience reports whether a position at the same company, title and start exists
+func (p *Profile) hasExperience(e Experience) bool {
+	for _, existing := range p.Experience {
+		if strings.EqualFold(existing.Company, e.Company) && strings.EqualFold(existing.Title, e.Title) && existing.StartDate == e.StartDate {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEducation reports whether the same degree at the same institution exists
+func (p *Profile) hasEducation(e Education) bool {
+	for _, existing := range p.Education {
+		if strings.EqualFold(existing.Institution, e.Institution) && strings.EqualFold(existing.Degree, e.Degree) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCertification reports whether the same certification from the same authority exists
+func (p *Profile) hasCertification(c Certification) bool {
+	for _, existing := range p.Certifications {
+		if strings.EqualFold(existing.Name, c.Name) && strings.EqualFold(existing.Authority, c.Authority) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLink reports whether a link to url exists
+func (p *Profile) hasLink(url string) bool {
+	for _, link := range p.Links {
+		if link.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold checks if a slice contains an item, ignoring case
+func containsFold(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertDocument adds doc or replaces the document imported from the same
+// path. Documents saved before paths were recorded, and documents without a
+// file, are matched by name.
+func (p *Profile) upsertDocument(doc Document) {
+	for i, existing := range p.Documents {
+		if doc.Path != "" && existing.Path == doc.Path || existing.Path == "" && existing.Name == doc.Name {
+			p.Documents[i] = doc
+			return
+		}
+	}
+	p.Documents = append(p.Documents, doc)
+}
+
+// project returns the project with the given name, or nil
+func (p *Profile) project(name string) *Project {
+	for i := range p.Projects {
+		if p.Projects[i].Name == name {
+			return &p.Projects[i]
+		}
+	}
+	return nil
+}
+
+// readmeSources are where READMEs are synced from
+var readmeSources = []string{sourceGitHub, sourceGitLab, sourceGitea, sourceLocal}
+
+// fromReadme reports whether the project was created from a synced README
+func (project *Project) fromReadme() bool {
+	return contains(readmeSources, project.Source)
+}
+
+// addReadme adds a project for a README synced from source, or refreshes its
+// README text while keeping any edits made to the project
+func (p *Profile) addReadme(name, source, content string) {
+	if project := p.project(name); project != nil {
+		project.setReadme(content)
+		return
+	}
+	project := Project{Name: name, Source: source}
+	project.setReadme(content)
+	p.Projects = append(p.Projects, project)
+}
+
+// setReadme stores a README along with the facts extracted from it
+func (project *Project) setReadme(content string) {
+	project.Readme = content
+	project.ReadmeFacts = nil
+	if content != "" {
+		project.ReadmeFacts = parseReadme(content)
+	}
+}
+
+// readmeText is the README context for prompts: the extracted facts, or the
+// README itself when nothing could be extracted
+func (project *Project) readmeText() string {
+	facts := project.ReadmeFacts
+	if facts == nil && project.Readme != "" {
+		// Profiles saved before facts were extracted
+		facts = parseReadme(project.Readme)
+	}
+	if facts == nil || facts.empty() {
+		return project.Readme
+	}
+	return facts.render()
+}
+
+// selectReadmes makes the README projects match the selected READMEs, given
+// with their contents and sources; projects from other sources are left alone
+func (p *Profile) selectReadmes(names []string, readmes, sources map[string]string) {
+	var projects []Project
+	for _, project := range p.Projects {
+		if !project.fromReadme() || contains(names, project.Name) {
+			projects = append(projects, project)
+		}
+	}
+	p.Projects = projects
+
+	for _, name := range names {
+		p.addReadme(name, sources[name], readmes[name])
+	}
+}
+
+// refreshReadmes updates the README text of README projects after a sync
+func (p *Profile) refreshReadmes(readmes map[string]string) {
+	for i := range p.Projects {
+		if !p.Projects[i].fromReadme() {
+			continue
+		}
+		if content, ok := readmes[p.Projects[i].Name]; ok {
+			p.Projects[i].setReadme(content)
+		}
+	}
+}
+
+// setContributions replaces the GitHub activity with a newly mined history,
+// keeping the activity read from local repositories
+func (p *Profile) setContributions(history *ContributionHistory) {
+	p.Contributions = p.filterContributions(true)
+	for _, repo := range history.Repos {
+		p.Contributions = append(p.Contributions, *repo)
+	}
+}
+
+// setLocalContributions replaces the activity read from local repositories
+// with the commit statistics of a new scan
+func (p *Profile) setLocalContributions(metadata map[string]*RepoMetadata) {
+	p.Contributions = p.filterContributions(false)
+	var names []string
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		md := metadata[name]
+		if md.Path == "" || md.Commits == 0 {
+			continue
+		}
+		p.Contributions = append(p.Contributions, RepoContributions{
+			Repo:          md.Path,
+			Key:           name,
+			Local:         true,
+			Commits:       md.Commits,
+			FirstActivity: md.FirstCommit,
+			LastActivity:  md.LastCommit,
+		})
+	}
+}
+
+// filterContributions returns the activity from local repositories, or from GitHub
+func (p *Profile) filterContributions(local bool) []RepoContributions {
+	var kept []RepoContributions
+	for _, c := range p.Contributions {
+		if c.Local == local {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// contributions returns the GitHub activity in the repository with the given README name, or nil
+func (p *Profile) contributions(key string) *RepoContributions {
+	for i := range p.Contributions {
+		if p.Contributions[i].Key == key {
+			return &p.Contributions[i]
+		}
+	}
+	return nil
+}
+
+// readmeProjects returns the names of the projects that came from READMEs
+func (p *Profile) readmeProjects() []string {
+	var names []string
+	for _, project := range p.Projects {
+		if project.fromReadme() {
+			names = append(names, project.Name)
+		}
+	}
+	return names
+}
+
+// render formats the profile as prompt input
+func (p *Profile) render() string {
+	var b strings.Builder
+
+	c := p.Contact
+	b.WriteString("# Contact\n")
+	writeField(&b, "Name", c.Name)
+	writeField(&b, "Headline", c.Headline)
+	writeField(&b, "Email", c.Email)
+	writeField(&b, "Phone", c.Phone)
+	writeField(&b, "Location", c.Location)
+	for _, link := range p.Links {
+		writeField(&b, link.Label, link.URL)
+	}
+
+	if p.Summary != "" {
+		b.WriteString("\n# Summary\n" + p.Summary + "\n")
+	}
+
+	if len(p.Experience) > 0 {
+		b.WriteString("\n# Experience\n")
+		for _, e := range p.Experience {
+			b.WriteString(fmt.Sprintf("\n## %s\n", joinNonEmpty(" at ", e.Title, e.Company)))
+			writeField(&b, "Dates", dateRange(e.StartDate, e.EndDate))
+			writeField(&b, "Location", e.Location)
+			if e.Summary != "" {
+				b.WriteString(e.Summary + "\n")
+			}
+			writeList(&b, e.Highlights)
+		}
+	}
+
+	if len(p.Education) > 0 {
+		b.WriteString("\n# Education\n")
+		for _, e := range p.Education {
+			b.WriteString(fmt.Sprintf("\n## %s\n", e.Institution))
+			writeField(&b, "Degree", joinNonEmpty(", ", e.Degree, e.Field))
+			writeField(&b, "Dates", dateRange(e.StartDate, e.EndDate))
+		}
+	}
+
+	if len(p.Skills) > 0 || len(p.Stack) > 0 {
+		b.WriteString("\n# Skills\n")
+		if len(p.Skills) > 0 {
+			b.WriteString(strings.Join(p.Skills, ", ") + "\n")
+		}
+		if len(p.Stack) > 0 {
+			b.WriteString("Detected in project manifests:\n")
+			for _, skill := range p.Stack {
+				b.WriteString(fmt.Sprintf("- %s (%s): %s\n", skill.Name, skill.Category, strings.Join(skill.Repos, ", ")))
+			}
+		}
+	}
+
+	if len(p.Certifications) > 0 {
+		b.WriteString("\n# Certifications\n")
+		for _, c := range p.Certifications {
+			b.WriteString("- " + joinNonEmpty(", ", c.Name, c.Authority, dateRange(c.StartDate, c.EndDate)) + "\n")
+		}
+	}
+
+	b.WriteString("\n# Projects\n")
+	if len(p.Projects) == 0 {
+		b.WriteString("No projects selected.\n")
+	}
+	for _, project := range p.Projects {
+		b.WriteString(fmt.Sprintf("\n## Project: %s\n", project.Name))
+		writeField(&b, "URL", project.URL)
+		writeField(&b, "Technologies", strings.Join(project.Technologies, ", "))
+		writeField(&b, "Stack", strings.Join(p.projectStack(project.Name), ", "))
+		if c := p.contributions(project.Name); c != nil {
+			writeField(&b, "Activity", c.summary())
+		}
+		if project.Description != "" {
+			b.WriteString(project.Description + "\n")
+		}
+		writeList(&b, project.Highlights)
+		if readme := project.readmeText(); readme != "" {
+			b.WriteString("\nREADME:\n" + readme + "\n")
+		}
+	}
+
+	// Activity in other people's repositories that are not projects of their own
+	var external []string
+	for _, c := range p.Contributions {
+		if summary := c.summary(); c.External && summary != "" && p.project(c.Key) == nil {
+			external = append(external, c.Repo+": "+summary)
+		}
+	}
+	if len(external) > 0 {
+		b.WriteString("\n# Open Source Contributions\n")
+		writeList(&b, external)
+	}
+
+	for _, doc := range p.Documents {
+		b.WriteString(fmt.Sprintf("\n# File: %s\n%s\n", doc.Name, doc.Content))
+	}
+
+	return b.String()
+}
+
+// writeField writes "label: value" when value is set
+func writeField(b *strings.Builder, label, value string) {
+	if value != "" {
+		b.WriteString(fmt.Sprintf("%s: %s\n", label, value))
+	}
+}
+
+// writeList writes items as a bulleted list
+func writeList(b *strings.Builder, items []string) {
+	for _, item := range items {
+		b.WriteString("- " + item + "\n")
+	}
+}
+
+// dateRange formats start and end dates, treating a missing end as present
+func dateRange(start, end string) string {
+	if start == "" {
+		return end
+	}
+	if end == "" {
+		end = "Present"
+	}
+	return start + " - " + end
+}
+
+// joinNonEmpty joins the non-empty parts with sep
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
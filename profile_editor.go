@@ -0,0 +1,259 @@
+// Filename: profile_editor.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ProfileEditedMsg is sent when the external editor opened on the profile exits
+type ProfileEditedMsg struct {
+	Err error
+}
+
+// profileField is one editable line of the profile editor
+type profileField struct {
+	label string
+	get   func(p *Profile) string
+	set   func(p *Profile, value string)
+}
+
+// profileFields lists the fields shown in the editor for the current profile
+func profileFields(p *Profile) []profileField {
+	fields := []profileField{
+		{"Name", func(p *Profile) string { return p.Contact.Name }, func(p *Profile, v string) { p.Contact.Name = v }},
+		{"Headline", func(p *Profile) string { return p.Contact.Headline }, func(p *Profile, v string) { p.Contact.Headline = v }},
+		{"Email", func(p *Profile) string { return p.Contact.Email }, func(p *Profile, v string) { p.Contact.Email = v }},
+		{"Phone", func(p *Profile) string { return p.Contact.Phone }, func(p *Profile, v string) { p.Contact.Phone = v }},
+		{"Location", func(p *Profile) string { return p.Contact.Location }, func(p *Profile, v string) { p.Contact.Location = v }},
+		{"Summary", func(p *Profile) string { return p.Summary }, func(p *Profile, v string) { p.Summary = v }},
+		{"Skills", func(p *Profile) string { return strings.Join(p.Skills, ", ") }, func(p *Profile, v string) { p.Skills = splitList(v) }},
+		{"Links", formatLinks, func(p *Profile, v string) { p.Links = parseLinks(v) }},
+	}
+
+	for i := range p.Experience {
+		i := i
+		name := joinNonEmpty(" at ", p.Experience[i].Title, p.Experience[i].Company)
+		fields = append(fields,
+			profileField{"Experience: " + name + " / title", func(p *Profile) string { return p.Experience[i].Title }, func(p *Profile, v string) { p.Experience[i].Title = v }},
+			profileField{"Experience: " + name + " / dates", func(p *Profile) string {
+				return dateRange(p.Experience[i].StartDate, p.Experience[i].EndDate)
+			}, func(p *Profile, v string) {
+				p.Experience[i].StartDate, p.Experience[i].EndDate = parseDateRange(v)
+			}},
+			profileField{"Experience: " + name + " / highlights", func(p *Profile) string {
+				return strings.Join(p.Experience[i].Highlights, "; ")
+			}, func(p *Profile, v string) { p.Experience[i].Highlights = splitHighlights(v) }},
+		)
+	}
+
+	for i := range p.Projects {
+		i := i
+		name := p.Projects[i].Name
+		fields = append(fields,
+			profileField{"Project: " + name + " / description", func(p *Profile) string { return p.Projects[i].Description }, func(p *Profile, v string) { p.Projects[i].Description = v }},
+			profileField{"Project: " + name + " / technologies", func(p *Profile) string {
+				return strings.Join(p.Projects[i].Technologies, ", ")
+			}, func(p *Profile, v string) { p.Projects[i].Technologies = splitList(v) }},
+		)
+	}
+
+	return fields
+}
+
+// formatLinks renders links as "label=url" pairs
+func formatLinks(p *Profile) string {
+	var pairs []string
+	for _, link := range p.Links {
+		pairs = append(pairs, link.Label+"="+link.URL)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// parseLinks reads "label=url" pairs; a bare URL is labelled "Link"
+func parseLinks(value string) []Link {
+	var links []Link
+	for _, item := range splitList(value) {
+		label, url, found := strings.Cut(item, "=")
+		if !found {
+			label, url = "Link", item
+		}
+		links = append(links, Link{Label: strings.TrimSpace(label), URL: strings.TrimSpace(url)})
+	}
+	return links
+}
+
+// parseDateRange splits "start - end"; "Present" leaves the end empty
+func parseDateRange(value string) (string, string) {
+	start, end, _ := strings.Cut(value, " - ")
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if strings.EqualFold(end, "present") {
+		end = ""
+	}
+	return start, end
+}
+
+// splitHighlights splits a "; "-separated list of highlights
+func splitHighlights(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// saveProfile writes the profile and reports failures in the UI
+func (m *model) saveProfile() {
+	if err := m.profile.save(m.paths.ProfileFile); err != nil {
+		m.err = fmt.Errorf("saving profile: %v", err)
+		m.addLog(m.err.Error())
+		return
+	}
+	m.addLog(fmt.Sprintf("Saved profile to %s.", m.paths.ProfileFile))
+}
+
+// openProfileInEditor hands the profile file to $EDITOR for sections the field editor does not cover
+func (m *model) openProfileInEditor() tea.Cmd {
+	m.saveProfile()
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	m.addLog(fmt.Sprintf("Opening profile in %s.", editor))
+	return tea.ExecProcess(exec.Command(editor, m.paths.ProfileFile), func(err error) tea.Msg {
+		return ProfileEditedMsg{Err: err}
+	})
+}
+
+// viewProfileEditor renders the profile editor
+func (m *model) viewProfileEditor() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render(fmt.Sprintf("Profile (%s):\n", m.paths.ProfileFile)))
+	s.WriteString(normalStyle.Render("Use arrow keys to navigate, enter to edit a field, 'e' to open the whole profile in $EDITOR, 'x' to export it as JSON Resume, esc to return.\n\n"))
+
+	for i, field := range m.profileFields {
+		cursor := "  "
+		if m.cursor == i {
+			cursor = selectedStyle.Render("❯ ")
+		}
+		if m.editingField && m.cursor == i {
+			s.WriteString(fmt.Sprintf("%s%s: %s\n", cursor, field.label, m.profileInput.View()))
+			continue
+		}
+		value := field.get(m.profile)
+		if runes := []rune(value); len(runes) > 60 {
+			value = string(runes[:57]) + "..."
+		}
+		s.WriteString(fmt.Sprintf("%s%s: %s\n", cursor, field.label, value))
+	}
+
+	s.WriteString(normalStyle.Render(fmt.Sprintf("\n%d experience, %d education, %d projects, %d certifications, %d documents.", len(m.profile.Experience), len(m.profile.Education), len(m.profile.Projects), len(m.profile.Certifications), len(m.profile.Documents))))
+
+	if m.message != "" {
+		s.WriteString("\n" + messageStyle.Render(m.message))
+	}
+
+	return s.String()
+}
+
+// updateProfileEditor handles input while the profile editor is open
+func (m *model) updateProfileEditor(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ProfileEditedMsg:
+		if msg.Err != nil {
+			m.err = fmt.Errorf("running editor: %v", msg.Err)
+			m.addLog(m.err.Error())
+			return m, nil
+		}
+		profile, err := loadProfile(m.paths.ProfileFile)
+		if err != nil {
+			m.err = fmt.Errorf("reloading profile: %v", err)
+			m.addLog(m.err.Error())
+			return m, nil
+		}
+		m.err = nil
+		m.profile = profile
+		m.profileFields = profileFields(profile)
+		m.cursor = 0
+		m.message = "Profile reloaded."
+		m.addLog("Reloaded profile after editing.")
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.editingField {
+			switch msg.String() {
+			case "enter":
+				field := m.profileFields[m.cursor]
+				field.set(m.profile, strings.TrimSpace(m.profileInput.Value()))
+				m.editingField = false
+				m.profileInput.Blur()
+				m.saveProfile()
+				m.profileFields = profileFields(m.profile)
+				m.message = fmt.Sprintf("Updated %s.", field.label)
+				return m, nil
+			case "esc":
+				m.editingField = false
+				m.profileInput.Blur()
+				m.message = "Edit discarded."
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.profileInput, cmd = m.profileInput.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.profileFields)-1 {
+				m.cursor++
+			}
+		case "enter":
+			m.editingField = true
+			m.profileInput.SetValue(m.profileFields[m.cursor].get(m.profile))
+			m.profileInput.CursorEnd()
+			m.message = "Press Enter to save, Esc to discard."
+			return m, m.profileInput.Focus()
+		case "e":
+			return m, m.openProfileInEditor()
+		case "x":
+			if err := m.profile.exportJSONResume(m.paths.JSONResumeOutput); err != nil {
+				m.err = err
+				m.addLog(fmt.Sprintf("Error exporting profile: %v", err))
+				return m, nil
+			}
+			m.message = fmt.Sprintf("Exported JSON Resume to '%s'.", m.paths.JSONResumeOutput)
+			m.addLog(m.message)
+		case "esc", "b":
+			m.state = stateMainMenu
+			m.cursor = 0
+			m.message = "Returning to main menu."
+			m.addLog("Closed profile editor.")
+		case "ctrl+c":
+			m.addLog("Application terminated by user.")
+			return m, tea.Quit
+		}
+	}
+
+	return m, nil
+}
+
+// newProfileInput creates the text input used to edit profile fields
+func newProfileInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = ""
+	input.CharLimit = 0
+	input.Width = 60
+	return input
+}
@@ -0,0 +1,43 @@
+// Filename: profile_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportFileKeysDocumentsByPath(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, content string) string {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a/resume.md", "Resume A")
+	b := write("b/resume.md", "Resume B")
+
+	p := &Profile{}
+	for _, path := range []string{a, b} {
+		if err := p.importFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.Documents) != 2 {
+		t.Fatalf("imported %d documents, want both files named resume.md", len(p.Documents))
+	}
+
+	// Importing a file again replaces its document
+	write("a/resume.md", "Resume A, revised")
+	if err := p.importFile(a); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Documents) != 2 || p.Documents[0].Content != "Resume A, revised" || p.Documents[1].Content != "Resume B" {
+		t.Errorf("documents after reimport = %+v", p.Documents)
+	}
+}
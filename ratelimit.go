@@ -0,0 +1,202 @@
+// Filename: ratelimit.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// RateLimitMsg reports the latest rate-limit status of a source to the progress view
+type RateLimitMsg struct {
+	Source    string // Source whose API is limited, e.g. sourceGitHub
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	WaitUntil time.Time // Set while requests are paused by a rate limit
+}
+
+// backoff retries transient failures of any API with exponential delays
+type backoff struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// newBackoff allows maxRetries retries after the first attempt
+func newBackoff(maxRetries int) backoff {
+	return backoff{maxAttempts: maxRetries + 1, baseDelay: time.Second, maxDelay: time.Minute}
+}
+
+// retry runs call until it succeeds, runs out of attempts or transient reports
+// its error as permanent. transient returns how long to wait before the next attempt.
+func (b backoff) retry(ctx context.Context, call func() error, transient func(err error, attempt int) (time.Duration, bool)) error {
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= b.maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		wait, retry := transient(err, attempt)
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay is an exponential delay with jitter for attempt, capped at maxDelay
+func (b backoff) delay(attempt int) time.Duration {
+	delay := b.baseDelay << uint(attempt-1)
+	if delay <= 0 || delay > b.maxDelay {
+		delay = b.maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// githubRetrier retries GitHub calls on server errors and rate limits
+type githubRetrier struct {
+	backoff
+	maxWait time.Duration // Longest pause accepted for a primary rate-limit reset
+	report  func(RateLimitMsg)
+}
+
+// newGitHubRetrier builds a retrier for the configured number of attempts
+func newGitHubRetrier(cfg GitHubConfig, report func(RateLimitMsg)) *githubRetrier {
+	return &githubRetrier{
+		backoff: newBackoff(cfg.MaxRetries),
+		maxWait: 15 * time.Minute,
+		report:  report,
+	}
+}
+
+// do runs call until it succeeds, fails permanently or runs out of attempts.
+// The last response is returned alongside the error so callers can inspect it.
+func (r *githubRetrier) do(ctx context.Context, call func() (*github.Response, error)) (*github.Response, error) {
+	var resp *github.Response
+	err := r.retry(ctx, func() (err error) {
+		resp, err = call()
+		if resp != nil {
+			r.reportRate(resp.Rate, time.Time{})
+		}
+		return err
+	}, func(err error, attempt int) (time.Duration, bool) {
+		wait, retry := r.retryDelay(resp, err, attempt)
+		if !retry {
+			return 0, false
+		}
+		var rateLimitErr *github.RateLimitError
+		var abuseErr *github.AbuseRateLimitError
+		if errors.As(err, &rateLimitErr) {
+			r.reportRate(rateLimitErr.Rate, time.Now().Add(wait))
+		} else if errors.As(err, &abuseErr) && resp != nil {
+			r.reportRate(resp.Rate, time.Now().Add(wait))
+		}
+		return wait, true
+	})
+	return resp, err
+}
+
+// retryDelay decides whether err is transient and how long to wait before retrying
+func (r *githubRetrier) retryDelay(resp *github.Response, err error, attempt int) (time.Duration, bool) {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var acceptedErr *github.AcceptedError
+	var errorResp *github.ErrorResponse
+
+	switch {
+	case errors.As(err, &rateLimitErr):
+		// Primary limit: wait for X-RateLimit-Reset
+		wait := time.Until(rateLimitErr.Rate.Reset.Time) + time.Second
+		if wait > r.maxWait {
+			return 0, false
+		}
+		if wait < r.baseDelay {
+			wait = r.baseDelay
+		}
+		return wait, true
+
+	case errors.As(err, &abuseErr):
+		// Secondary limit: honor Retry-After when GitHub sends it
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return r.delay(attempt), true
+
+	case errors.As(err, &acceptedErr):
+		// GitHub is still computing the result
+		return r.delay(attempt), true
+
+	case errors.As(err, &errorResp):
+		if errorResp.Response != nil && errorResp.Response.StatusCode >= http.StatusInternalServerError {
+			return r.delay(attempt), true
+		}
+		return 0, false
+
+	case resp == nil:
+		// Network errors never produced a response
+		return r.delay(attempt), true
+	}
+
+	return 0, false
+}
+
+// reportRate forwards rate-limit headers to the progress view
+func (r *githubRetrier) reportRate(rate github.Rate, waitUntil time.Time) {
+	if r.report == nil || rate.Limit == 0 {
+		return
+	}
+	r.report(RateLimitMsg{
+		Source:    sourceGitHub,
+		Limit:     rate.Limit,
+		Remaining: rate.Remaining,
+		Reset:     rate.Reset.Time,
+		WaitUntil: waitUntil,
+	})
+}
+
+// sourceTitles are the names of the sources as shown to the user
+var sourceTitles = map[string]string{
+	sourceGitHub: "GitHub",
+	sourceGitLab: "GitLab",
+	sourceGitea:  "Gitea",
+}
+
+// title names the limited source, falling back to a neutral wording
+func (msg RateLimitMsg) title() string {
+	if title, ok := sourceTitles[msg.Source]; ok {
+		return title + " rate limit"
+	}
+	return "Rate limit"
+}
+
+// String renders the rate-limit status for the progress view
+func (msg RateLimitMsg) String() string {
+	var details []string
+	if msg.Limit > 0 {
+		details = append(details, fmt.Sprintf("%d/%d remaining", msg.Remaining, msg.Limit))
+	}
+	if !msg.Reset.IsZero() {
+		details = append(details, "resets at "+msg.Reset.Local().Format("15:04:05"))
+	}
+	status := msg.title()
+	if len(details) > 0 {
+		status += ": " + strings.Join(details, ", ")
+	}
+	if !msg.WaitUntil.IsZero() && time.Now().Before(msg.WaitUntil) {
+		status += fmt.Sprintf(" (paused until %s)", msg.WaitUntil.Local().Format("15:04:05"))
+	}
+	return status
+}
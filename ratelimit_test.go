@@ -0,0 +1,217 @@
+// Filename: ratelimit_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// newTestRetrier returns a retrier with millisecond delays
+func newTestRetrier(maxRetries int, report func(RateLimitMsg)) *githubRetrier {
+	cfg := defaultGitHubConfig()
+	cfg.MaxRetries = maxRetries
+	r := newGitHubRetrier(cfg, report)
+	r.baseDelay = time.Millisecond
+	r.maxDelay = 10 * time.Millisecond
+	return r
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := newTestRetrier(3, nil)
+	r.baseDelay = time.Second
+	r.maxDelay = time.Minute
+	retryAfter := 7 * time.Second
+	response := &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+	statusErr := func(code int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code}}
+	}
+	rateLimited := func(reset time.Duration) error {
+		return &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(reset)}}}
+	}
+
+	tests := []struct {
+		name     string
+		resp     *github.Response
+		err      error
+		retry    bool
+		min, max time.Duration
+	}{
+		{"primary limit waits for the reset", response, rateLimited(10 * time.Second), true, 10 * time.Second, 11 * time.Second},
+		{"primary limit past its reset", response, rateLimited(-10 * time.Second), true, time.Second, time.Second},
+		{"primary limit beyond maxWait", response, rateLimited(time.Hour), false, 0, 0},
+		{"abuse limit with Retry-After", response, &github.AbuseRateLimitError{RetryAfter: &retryAfter}, true, retryAfter, retryAfter},
+		{"abuse limit without Retry-After", response, &github.AbuseRateLimitError{}, true, time.Second, 2 * time.Second},
+		{"accepted", response, &github.AcceptedError{}, true, time.Second, 2 * time.Second},
+		{"server error", response, statusErr(http.StatusBadGateway), true, time.Second, 2 * time.Second},
+		{"client error", response, statusErr(http.StatusNotFound), false, 0, 0},
+		{"network error", nil, errors.New("connection reset"), true, time.Second, 2 * time.Second},
+		{"other error with a response", response, errors.New("decoding failed"), false, 0, 0},
+	}
+	// The second attempt backs off between one and two base delays
+	for _, tt := range tests {
+		wait, retry := r.retryDelay(tt.resp, tt.err, 2)
+		if retry != tt.retry {
+			t.Errorf("%s: retry = %v, want %v", tt.name, retry, tt.retry)
+			continue
+		}
+		if wait < tt.min || wait > tt.max {
+			t.Errorf("%s: wait = %v, want between %v and %v", tt.name, wait, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := backoff{maxAttempts: 10, baseDelay: time.Second, maxDelay: 5 * time.Second}
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 70: 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := b.delay(attempt); d < max/2 || d > max {
+				t.Errorf("delay(%d) = %v, want between %v and %v", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	b := backoff{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+	transient := func(err error, attempt int) (time.Duration, bool) { return time.Millisecond, true }
+
+	calls := 0
+	err := b.retry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, transient)
+	if err != nil || calls != 3 {
+		t.Errorf("transient failures: err = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = b.retry(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	}, transient)
+	if err == nil || err.Error() != "failure 3" {
+		t.Errorf("out of attempts: got %v, want the last error", err)
+	}
+
+	calls = 0
+	err = b.retry(context.Background(), func() error {
+		calls++
+		return errors.New("permanent")
+	}, func(error, int) (time.Duration, bool) { return 0, false })
+	if err == nil || calls != 1 {
+		t.Errorf("permanent failure: err = %v after %d calls, want one call", err, calls)
+	}
+}
+
+func TestBackoffRetryCancelledDuringWait(t *testing.T) {
+	b := backoff{maxAttempts: 5, baseDelay: time.Hour, maxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	calls := 0
+	start := time.Now()
+	err := b.retry(ctx, func() error {
+		calls++
+		return errors.New("flaky")
+	}, func(error, int) (time.Duration, bool) { return time.Hour, true })
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("got %v after %d calls, want context.Canceled after one call", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+}
+
+func TestGitHubRetrierDo(t *testing.T) {
+	var statuses []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		if status == http.StatusForbidden {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit", "documentation_url": "https://docs.github.com/rest#secondary-rate-limits"}`)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"login": "octo"}`)
+	}))
+	defer server.Close()
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		wantWait bool
+	}{
+		{"server errors are retried", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, false, false},
+		{"client errors fail at once", []int{http.StatusNotFound}, true, false},
+		{"abuse limits honor Retry-After", []int{http.StatusForbidden, http.StatusOK}, false, true},
+		{"server errors run out of attempts", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, true, false},
+	}
+	for _, tt := range tests {
+		statuses = tt.statuses
+		var reports []RateLimitMsg
+		r := newTestRetrier(3, func(msg RateLimitMsg) { reports = append(reports, msg) })
+		_, err := r.do(context.Background(), func() (*github.Response, error) {
+			_, resp, err := client.Users.Get(context.Background(), "octo")
+			return resp, err
+		})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if len(statuses) != 0 {
+			t.Errorf("%s: %d responses left unrequested", tt.name, len(statuses))
+		}
+		paused := false
+		for _, msg := range reports {
+			if msg.Limit != 5000 || msg.Source != sourceGitHub {
+				t.Errorf("%s: reported %+v", tt.name, msg)
+			}
+			paused = paused || !msg.WaitUntil.IsZero()
+		}
+		if paused != tt.wantWait {
+			t.Errorf("%s: reported a pause = %v, want %v", tt.name, paused, tt.wantWait)
+		}
+	}
+}
+
+func TestRateLimitMsgString(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	tests := map[string]string{
+		sourceGitHub: "GitHub rate limit: 10/60 remaining",
+		sourceGitLab: "GitLab rate limit: 10/60 remaining",
+		"":           "Rate limit: 10/60 remaining",
+	}
+	for source, want := range tests {
+		msg := RateLimitMsg{Source: source, Limit: 60, Remaining: 10, Reset: reset}
+		if got := msg.String(); !strings.HasPrefix(got, want) || strings.Contains(got, "paused") {
+			t.Errorf("%q: got %q, want it to start with %q", source, got, want)
+		}
+	}
+
+	paused := RateLimitMsg{Source: sourceGitHub, Limit: 60, Reset: reset, WaitUntil: reset}
+	if !strings.Contains(paused.String(), "(paused until ") {
+		t.Errorf("got %q, want the pause shown", paused.String())
+	}
+}
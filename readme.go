@@ -0,0 +1,247 @@
+// Filename: readme.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// maxReadmeSectionText bounds the text kept from a single README section
+const maxReadmeSectionText = 600
+
+// Headings of README sections with a special meaning
+var (
+	readmeNoisePattern       = regexp.MustCompile(`(?i)^(table of contents|contents|toc|install(ation|ing)?|getting started|quick ?start|setup|set ?up|prerequisites|requirements|dependencies|build(ing)?( from source)?|running( locally)?|development|testing|tests|deploy(ment|ing)?|configuration|license|licensing|contribut(e|ing|ors)|code of conduct|support|donat(e|ions?)|sponsors?|acknowledg(e)?ments?|credits|thanks|authors?|contact|changelog|faq|roadmap|todo|next steps|badges|screenshots?|demo|disclaimer|security)$`)
+	readmeFeaturesPattern    = regexp.MustCompile(`(?i)^((key |main )?features|highlights|what it does|capabilities)$`)
+	readmeTechStackPattern   = regexp.MustCompile(`(?i)^(tech(nology)? stack|technologies( used)?|built with|stack|tools( used)?|tools (&|and) technologies|made with|powered by)$`)
+	readmeOverviewPattern    = regexp.MustCompile(`(?i)^(about( the project)?|overview|description|introduction|intro|summary|what is (this|it)\??)$`)
+	readmeHeadingTrimPattern = regexp.MustCompile(`^[^\pL\pN]+|[^\pL\pN)]+$`)
+)
+
+// ReadmeFacts is what a README says about its project, without badges,
+// install steps, license text and other boilerplate
+type ReadmeFacts struct {
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Features    []string        `json:"features,omitempty"`
+	TechStack   []string        `json:"tech_stack,omitempty"`
+	Sections    []ReadmeSection `json:"sections,omitempty"`
+}
+
+// ReadmeSection is a notable README section, condensed to plain text
+type ReadmeSection struct {
+	Heading string `json:"heading"`
+	Text    string `json:"text"`
+}
+
+// readmeBlock is a section being collected while walking the README
+type readmeBlock struct {
+	heading string
+	paras   []string
+	items   []string
+	lines   []string // Paragraphs, items and sub-headings in document order
+}
+
+func (b *readmeBlock) addPara(text string) {
+	b.paras = append(b.paras, text)
+	b.lines = append(b.lines, text)
+}
+
+func (b *readmeBlock) addItems(items ...string) {
+	b.items = append(b.items, items...)
+	for _, item := range items {
+		b.lines = append(b.lines, "- "+item)
+	}
+}
+
+// parseReadme extracts the structured facts from README Markdown
+func parseReadme(content string) *ReadmeFacts {
+	source := []byte(content)
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	// Split the document at headings; the preamble has no heading
+	facts := &ReadmeFacts{}
+	blocks := []*readmeBlock{{}}
+	sectionLevel := 0
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		current := blocks[len(blocks)-1]
+		switch n := node.(type) {
+		case *ast.Heading:
+			heading := readmeHeadingTrimPattern.ReplaceAllString(inlineText(n, source), "")
+			if facts.Title == "" && n.Level == 1 && len(blocks) == 1 {
+				facts.Title = heading
+				continue
+			}
+			if sectionLevel == 0 || n.Level <= sectionLevel {
+				sectionLevel = n.Level
+				blocks = append(blocks, &readmeBlock{heading: heading})
+				continue
+			}
+			// Sub-headings stay in their section, as items
+			if heading != "" {
+				current.items = append(current.items, heading)
+				current.lines = append(current.lines, heading+":")
+			}
+		case *ast.Paragraph:
+			if para := inlineText(n, source); para != "" {
+				current.addPara(para)
+			}
+		case *ast.List:
+			current.addItems(listItems(n, source)...)
+		case *ast.Blockquote:
+			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+				if para := inlineText(child, source); para != "" {
+					current.addPara(para)
+				}
+			}
+		}
+		// Code blocks, HTML, tables and rules are left out
+	}
+
+	preamble := blocks[0]
+	if len(preamble.paras) > 0 {
+		facts.Description = preamble.paras[0]
+		// "It lets you:" introduces the list that follows
+		if strings.HasSuffix(facts.Description, ":") && len(preamble.items) > 0 {
+			var items []string
+			for _, item := range preamble.items {
+				items = append(items, strings.TrimRight(item, ".;"))
+			}
+			facts.Description += " " + strings.Join(items, "; ") + "."
+		}
+	}
+	for _, block := range blocks[1:] {
+		switch {
+		case readmeNoisePattern.MatchString(block.heading):
+		case readmeFeaturesPattern.MatchString(block.heading):
+			facts.Features = append(facts.Features, block.items...)
+			if len(block.items) == 0 {
+				facts.Features = append(facts.Features, block.paras...)
+			}
+		case readmeTechStackPattern.MatchString(block.heading):
+			facts.TechStack = append(facts.TechStack, techStackItems(block)...)
+		case readmeOverviewPattern.MatchString(block.heading) && facts.Description == "" && len(block.paras) > 0:
+			facts.Description = block.paras[0]
+			for i, line := range block.lines {
+				if line == facts.Description {
+					block.lines = append(block.lines[:i], block.lines[i+1:]...)
+					break
+				}
+			}
+			if text := block.text(); text != "" {
+				facts.Sections = append(facts.Sections, ReadmeSection{Heading: block.heading, Text: text})
+			}
+		default:
+			if text := block.text(); text != "" && block.heading != "" {
+				facts.Sections = append(facts.Sections, ReadmeSection{Heading: block.heading, Text: text})
+			}
+		}
+	}
+	return facts
+}
+
+// text condenses a section to its lines, cut at a word boundary
+func (b *readmeBlock) text() string {
+	text := strings.Join(b.lines, "\n")
+	if len(text) <= maxReadmeSectionText {
+		return text
+	}
+	cut := strings.LastIndexAny(text[:maxReadmeSectionText], " \n")
+	if cut <= 0 {
+		cut = maxReadmeSectionText
+	}
+	return strings.TrimSpace(text[:cut]) + "..."
+}
+
+// techStackItems reads a tech stack section, which may be a list or a
+// comma-separated paragraph
+func techStackItems(b *readmeBlock) []string {
+	var items []string
+	for _, item := range b.items {
+		// "Go - the backend" or "Go: the backend" names the technology first
+		name := item
+		for _, sep := range []string{" - ", " – ", ": "} {
+			if before, _, found := strings.Cut(name, sep); found {
+				name = before
+			}
+		}
+		items = append(items, strings.TrimSpace(name))
+	}
+	if len(items) == 0 {
+		for _, para := range b.paras {
+			items = append(items, splitList(para)...)
+		}
+	}
+	return items
+}
+
+// listItems returns the text of each item of a list, flattening nested lists
+func listItems(list *ast.List, source []byte) []string {
+	var items []string
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+			if nested, ok := child.(*ast.List); ok {
+				items = append(items, listItems(nested, source)...)
+			} else if text := inlineText(child, source); text != "" {
+				items = append(items, text)
+			}
+		}
+	}
+	return items
+}
+
+// inlineText returns the plain text of a block, dropping images (badges
+// included) and raw HTML
+func inlineText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Image, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			b.Write(n.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteString(" ")
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.AutoLink:
+			b.Write(n.URL(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return cleanText(b.String())
+}
+
+// render formats the facts as prompt input
+func (f *ReadmeFacts) render() string {
+	var b strings.Builder
+	writeField(&b, "Title", f.Title)
+	if f.Description != "" {
+		b.WriteString(f.Description + "\n")
+	}
+	if len(f.Features) > 0 {
+		b.WriteString("Features:\n")
+		writeList(&b, f.Features)
+	}
+	writeField(&b, "Tech stack", strings.Join(f.TechStack, ", "))
+	for _, section := range f.Sections {
+		b.WriteString(fmt.Sprintf("### %s\n%s\n", section.Heading, section.Text))
+	}
+	return b.String()
+}
+
+// empty reports whether nothing was extracted
+func (f *ReadmeFacts) empty() bool {
+	return f.Title == "" && f.Description == "" && len(f.Features) == 0 && len(f.TechStack) == 0 && len(f.Sections) == 0
+}
@@ -0,0 +1,109 @@
+// Filename: readme_test.go
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReadme(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *ReadmeFacts
+	}{
+		{
+			name: "badges and boilerplate",
+			content: "# 🚀 amalgia\n\n" +
+				"[![Build](https://ci.example/badge.svg)](https://ci.example) ![License](https://img.shields.io/badge/license-MIT-green)\n\n" +
+				"Generates resumes from your **GitHub** projects.\n\n" +
+				"## Installation\n\n```sh\ngo install example.com/amalgia@latest\n```\n\n" +
+				"## Features\n\n- Fetches READMEs\n- Writes PDF resumes\n\n" +
+				"## Built With\n\n- Go - the backend\n- Bubble Tea: the TUI\n\n" +
+				"## License\n\nMIT\n",
+			want: &ReadmeFacts{
+				Title:       "amalgia",
+				Description: "Generates resumes from your GitHub projects.",
+				Features:    []string{"Fetches READMEs", "Writes PDF resumes"},
+				TechStack:   []string{"Go", "Bubble Tea"},
+			},
+		},
+		{
+			name: "description introducing a list",
+			content: "# tool\n\nIt lets you:\n\n- sync repositories;\n- render resumes.\n\n" +
+				"## Tech Stack\n\nGo, SQLite,  HTMX\n",
+			want: &ReadmeFacts{
+				Title:       "tool",
+				Description: "It lets you: sync repositories; render resumes.",
+				TechStack:   []string{"Go", "SQLite", "HTMX"},
+			},
+		},
+		{
+			name: "overview section and sub-headings",
+			content: "# service\n\n" +
+				"## Overview\n\nA billing service.\n\nHandles invoices for 2M users.\n\n" +
+				"## Architecture\n\nEvent driven.\n\n### Storage\n\n- Postgres\n- S3\n\n" +
+				"## Highlights\n\nZero downtime deploys.\n",
+			want: &ReadmeFacts{
+				Title:       "service",
+				Description: "A billing service.",
+				Features:    []string{"Zero downtime deploys."},
+				Sections: []ReadmeSection{
+					{Heading: "Overview", Text: "Handles invoices for 2M users."},
+					{Heading: "Architecture", Text: "Event driven.\nStorage:\n- Postgres\n- S3"},
+				},
+			},
+		},
+		{
+			name:    "HTML header and nested lists",
+			content: "<p align=\"center\"><img src=\"logo.png\"></p>\n\n# app\n\n> A note-taking app.\n\n## Features\n\n- Sync\n  - Offline first\n- Search <kbd>/</kbd>\n",
+			want: &ReadmeFacts{
+				Title:       "app",
+				Description: "A note-taking app.",
+				Features:    []string{"Sync", "Offline first", "Search /"},
+			},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    &ReadmeFacts{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseReadme(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReadmeLongSection(t *testing.T) {
+	facts := parseReadme("# x\n\n## Design\n\n" + strings.Repeat("word ", 200) + "\n")
+	if len(facts.Sections) != 1 {
+		t.Fatalf("sections = %+v", facts.Sections)
+	}
+	text := facts.Sections[0].Text
+	if len(text) > maxReadmeSectionText+3 || !strings.HasSuffix(text, "word...") {
+		t.Errorf("section not cut at a word boundary: %d bytes ending %q", len(text), text[len(text)-10:])
+	}
+}
+
+func TestReadmeFactsRender(t *testing.T) {
+	facts := &ReadmeFacts{
+		Title:       "amalgia",
+		Description: "Generates resumes.",
+		Features:    []string{"PDF output"},
+		TechStack:   []string{"Go", "Bubble Tea"},
+		Sections:    []ReadmeSection{{Heading: "Design", Text: "Event driven."}},
+	}
+	for _, want := range []string{"Title: amalgia", "Generates resumes.", "Features:", "PDF output", "Tech stack: Go, Bubble Tea", "### Design\nEvent driven."} {
+		if !strings.Contains(facts.render(), want) {
+			t.Errorf("render() does not contain %q:\n%s", want, facts.render())
+		}
+	}
+	if facts.empty() || !(&ReadmeFacts{}).empty() {
+		t.Error("empty() does not follow the extracted facts")
+	}
+}
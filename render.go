@@ -0,0 +1,649 @@
+// Filename: render.go
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Formats resumes are written in
+const (
+	formatText       = "text"       // The model's reply as is
+	formatMarkdown   = "markdown"   // Rendered from the structured resume, like the formats below
+	formatHTML       = "html"       // Standalone page with the theme's stylesheet inlined
+	formatPDF        = "pdf"        // Laid out in Go, without external tools
+	formatLaTeX      = "latex"      // Source for a local TeX engine, from a LaTeX template
+	formatJSONResume = "jsonresume" // The structured resume itself
+)
+
+// resumeFormats lists the formats in the order they are offered, with the
+// extension of their output files
+var resumeFormats = []struct{ name, label, ext string }{
+	{formatMarkdown, "Markdown", ".md"},
+	{formatHTML, "HTML", ".html"},
+	{formatPDF, "PDF", ".pdf"},
+	{formatLaTeX, "LaTeX", ".tex"},
+	{formatJSONResume, "JSON Resume", ".json"},
+	{formatText, "Plain text", ".txt"},
+}
+
+// resumeFormatNames returns the names of the formats, for messages
+func resumeFormatNames() []string {
+	var names []string
+	for _, format := range resumeFormats {
+		names = append(names, format.name)
+	}
+	return names
+}
+
+// resumeOutputPath replaces the extension of the configured output file with
+// the one of format, so each format gets its own file
+func resumeOutputPath(path, format string) string {
+	for _, f := range resumeFormats {
+		if f.name == format {
+			return strings.TrimSuffix(path, filepath.Ext(path)) + f.ext
+		}
+	}
+	return path
+}
+
+// ResumeConfig selects how generated resumes are rendered
+type ResumeConfig struct {
+	Format        string `yaml:"format"`         // Default output format, see the format* constants
+	Theme         string `yaml:"theme"`          // Bundled theme of HTML and PDF output
+	CSS           string `yaml:"css"`            // Stylesheet replacing the theme's in HTML output
+	TemplatesDir  string `yaml:"templates_dir"`  // Directory with resume.md.tmpl, resume.html.tmpl or resume.tex.tmpl overriding the bundled templates
+	LaTeXTemplate string `yaml:"latex_template"` // Bundled template of LaTeX output
+	LaTeXEngine   string `yaml:"latex_engine"`   // TeX engine compiling LaTeX output: auto, none or a command such as xelatex
+}
+
+// defaultResumeConfig renders Markdown with the classic theme, and compiles
+// LaTeX with whichever engine is installed
+func defaultResumeConfig() ResumeConfig {
+	return ResumeConfig{Format: formatMarkdown, Theme: "classic", LaTeXTemplate: "moderncv", LaTeXEngine: latexEngineAuto}
+}
+
+// applyEnv applies AMALGIA_RESUME_* environment overrides
+func (cfg *ResumeConfig) applyEnv() {
+	stringVars := map[string]*string{
+		"AMALGIA_RESUME_FORMAT":         &cfg.Format,
+		"AMALGIA_RESUME_THEME":          &cfg.Theme,
+		"AMALGIA_RESUME_CSS":            &cfg.CSS,
+		"AMALGIA_RESUME_TEMPLATES_DIR":  &cfg.TemplatesDir,
+		"AMALGIA_RESUME_LATEX_TEMPLATE": &cfg.LaTeXTemplate,
+		"AMALGIA_RESUME_LATEX_ENGINE":   &cfg.LaTeXEngine,
+	}
+	for name, target := range stringVars {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+}
+
+// validate checks the format, theme and LaTeX template names
+func (cfg ResumeConfig) validate() error {
+	if !contains(resumeFormatNames(), cfg.Format) {
+		return fmt.Errorf("unknown format %q (expected one of %s)", cfg.Format, strings.Join(resumeFormatNames(), ", "))
+	}
+	if _, ok := resumeThemes[cfg.Theme]; !ok {
+		return fmt.Errorf("unknown theme %q (expected one of %s)", cfg.Theme, strings.Join(resumeThemeNames(), ", "))
+	}
+	if _, ok := latexTemplates[cfg.LaTeXTemplate]; !ok {
+		return fmt.Errorf("unknown LaTeX template %q (expected one of %s)", cfg.LaTeXTemplate, strings.Join(latexTemplateNames(), ", "))
+	}
+	if cfg.LaTeXEngine == "" {
+		return fmt.Errorf("latex_engine is empty (expected %s, %s or the command of a TeX engine)", latexEngineAuto, latexEngineNone)
+	}
+	return nil
+}
+
+// resumeTheme styles HTML and PDF output
+type resumeTheme struct {
+	css    string // Custom properties and overrides applied after resumeBaseCSS
+	font   string // Standard PDF font: Helvetica, Times or Courier; unicodePDFFont for other scripts
+	accent [3]int // Color of the name, section headings and rules
+}
+
+// resumeThemes are the bundled themes by name
+var resumeThemes = map[string]resumeTheme{
+	"classic": {
+		css: `:root { --font: Georgia, "Times New Roman", serif; --accent: #1f3a60; --page: #f4f1ea; }
+h2 { border-bottom: 1px solid var(--accent); }`,
+		font:   "Times",
+		accent: [3]int{31, 58, 96},
+	},
+	"modern": {
+		css: `:root { --font: "Helvetica Neue", Helvetica, Arial, sans-serif; --accent: #0f766e; --page: #eef2f3; }
+h2 { text-transform: uppercase; letter-spacing: .08em; font-size: .95rem; border-bottom: 2px solid var(--accent); }
+header { border-left: 6px solid var(--accent); padding-left: 1rem; }`,
+		font:   "Helvetica",
+		accent: [3]int{15, 118, 110},
+	},
+	"minimal": {
+		css: `:root { --font: system-ui, -apple-system, "Segoe UI", sans-serif; --accent: #222; --page: #fff; }
+h1 { font-weight: 600; }
+h2 { font-weight: 600; }`,
+		font:   "Helvetica",
+		accent: [3]int{34, 34, 34},
+	},
+}
+
+// resumeThemeNames returns the bundled theme names in a stable order
+func resumeThemeNames() []string {
+	return []string{"classic", "modern", "minimal"}
+}
+
+// resumeBaseCSS is shared by every theme; themes set the custom properties
+const resumeBaseCSS = `body { margin: 0; background: var(--page); color: #222; font: 15px/1.5 var(--font); }
+.resume { max-width: 800px; margin: 2rem auto; padding: 2.5rem 3rem; background: #fff; box-shadow: 0 1px 4px rgba(0, 0, 0, .12); }
+h1 { margin: 0; font-size: 2.1rem; line-height: 1.2; color: var(--accent); }
+.label { margin: .2rem 0 .6rem; font-size: 1.1rem; color: #555; }
+.contact { display: flex; flex-wrap: wrap; gap: .2rem 1.2rem; margin: 0; padding: 0; list-style: none; font-size: .9rem; }
+a { color: var(--accent); text-decoration: none; }
+h2 { margin: 1.6rem 0 .6rem; padding-bottom: .2rem; font-size: 1.1rem; color: var(--accent); }
+h3 { margin: 0; font-size: 1rem; }
+article { margin-bottom: 1rem; break-inside: avoid; }
+.entry-head { display: flex; justify-content: space-between; align-items: baseline; gap: 1rem; }
+.dates { color: #666; font-size: .9rem; white-space: nowrap; }
+.meta { margin: .1rem 0 .3rem; color: #666; font-size: .9rem; font-style: italic; }
+p { margin: .3rem 0; }
+ul { margin: .3rem 0; padding-left: 1.2rem; }
+.skills { padding: 0; list-style: none; }
+@media print {
+  body { background: none; }
+  .resume { max-width: none; margin: 0; padding: 0; box-shadow: none; }
+}
+@page { margin: 16mm 18mm; }`
+
+// resumeContact is a contact detail shown under the name, linked when it has a URL
+type resumeContact struct {
+	Text string
+	URL  string
+}
+
+// resumeContacts lists how to reach the candidate, in display order
+func resumeContacts(b JSONResumeBasics) []resumeContact {
+	var contacts []resumeContact
+	if location := resumeLocation(b.Location); location != "" {
+		contacts = append(contacts, resumeContact{Text: location})
+	}
+	if b.Email != "" {
+		contacts = append(contacts, resumeContact{Text: b.Email, URL: "mailto:" + b.Email})
+	}
+	if b.Phone != "" {
+		contacts = append(contacts, resumeContact{Text: b.Phone})
+	}
+	if b.URL != "" {
+		contacts = append(contacts, resumeContact{Text: displayURL(b.URL), URL: b.URL})
+	}
+	for _, profile := range b.Profiles {
+		switch {
+		case profile.URL != "":
+			contacts = append(contacts, resumeContact{Text: displayURL(profile.URL), URL: profile.URL})
+		case profile.Username != "":
+			contacts = append(contacts, resumeContact{Text: joinNonEmpty(": ", profile.Network, profile.Username)})
+		}
+	}
+	return contacts
+}
+
+// resumeLocation formats basics.location
+func resumeLocation(l *JSONResumeLocation) string {
+	if l == nil {
+		return ""
+	}
+	return joinNonEmpty(", ", l.City, l.Region, l.CountryCode)
+}
+
+// displayURL drops the scheme and trailing slash of a URL shown as text
+func displayURL(url string) string {
+	for _, scheme := range []string{"https://", "http://"} {
+		url = strings.TrimPrefix(url, scheme)
+	}
+	return strings.TrimSuffix(url, "/")
+}
+
+// resumeTemplateFuncs are available in the Markdown and HTML templates
+var resumeTemplateFuncs = map[string]interface{}{
+	"contacts":     resumeContacts,
+	"dates":        dateRange,
+	"join":         strings.Join,
+	"joinNonEmpty": joinNonEmpty,
+}
+
+// resumeMarkdownTemplate renders the structured resume as Markdown
+const resumeMarkdownTemplate = `# {{.Basics.Name}}
+{{with .Basics.Label}}
+**{{.}}**
+{{end}}
+{{with contacts .Basics}}
+{{range $i, $c := .}}{{if $i}} · {{end}}{{if $c.URL}}[{{$c.Text}}]({{$c.URL}}){{else}}{{$c.Text}}{{end}}{{end}}
+{{end}}
+{{with .Basics.Summary}}
+## Summary
+
+{{.}}
+{{end}}
+{{with .Work}}
+## Experience
+{{range .}}
+### {{joinNonEmpty ", " .Position .Name}}
+
+{{with joinNonEmpty " · " (dates .StartDate .EndDate) .Location}}*{{.}}*{{end}}
+
+{{with .Summary}}{{.}}{{end}}
+
+{{range .Highlights}}- {{.}}
+{{end}}{{end}}{{end}}
+{{with .Projects}}
+## Projects
+{{range .}}
+### {{if .URL}}[{{.Name}}]({{.URL}}){{else}}{{.Name}}{{end}}
+
+{{with .Keywords}}*{{join . ", "}}*{{end}}
+
+{{with .Description}}{{.}}{{end}}
+
+{{range .Highlights}}- {{.}}
+{{end}}{{end}}{{end}}
+{{with .Skills}}
+## Skills
+
+{{range .}}- {{if .Keywords}}**{{.Name}}**{{with .Level}} ({{.}}){{end}}: {{join .Keywords ", "}}{{else}}{{.Name}}{{with .Level}} ({{.}}){{end}}{{end}}
+{{end}}{{end}}
+{{with .Education}}
+## Education
+{{range .}}
+### {{joinNonEmpty ", " (joinNonEmpty " in " .StudyType .Area) .Institution}}
+
+{{with dates .StartDate .EndDate}}*{{.}}*{{end}}
+{{end}}{{end}}
+{{with .Certificates}}
+## Certificates
+
+{{range .}}- {{joinNonEmpty ", " .Name .Issuer .Date}}
+{{end}}{{end}}
+`
+
+// resumeHTMLTemplate renders the structured resume as a standalone page
+const resumeHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Basics.Name}}{{with .Basics.Label}} - {{.}}{{end}}</title>
+<style>
+{{css}}
+</style>
+</head>
+<body>
+<main class="resume">
+<header>
+<h1>{{.Basics.Name}}</h1>
+{{with .Basics.Label}}<p class="label">{{.}}</p>{{end}}
+{{with contacts .Basics}}<ul class="contact">{{range .}}<li>{{if .URL}}<a href="{{.URL}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</li>{{end}}</ul>{{end}}
+</header>
+{{with .Basics.Summary}}<section class="summary">
+<h2>Summary</h2>
+<p>{{.}}</p>
+</section>{{end}}
+{{with .Work}}<section class="work">
+<h2>Experience</h2>
+{{range .}}<article>
+<div class="entry-head"><h3>{{joinNonEmpty ", " .Position .Name}}</h3><span class="dates">{{dates .StartDate .EndDate}}</span></div>
+{{with .Location}}<p class="meta">{{.}}</p>{{end}}
+{{with .Summary}}<p>{{.}}</p>{{end}}
+{{with .Highlights}}<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</article>
+{{end}}</section>{{end}}
+{{with .Projects}}<section class="projects">
+<h2>Projects</h2>
+{{range .}}<article>
+<div class="entry-head"><h3>{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</h3></div>
+{{with .Keywords}}<p class="meta">{{join . ", "}}</p>{{end}}
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{with .Highlights}}<ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</article>
+{{end}}</section>{{end}}
+{{with .Skills}}<section>
+<h2>Skills</h2>
+<ul class="skills">{{range .}}<li>{{if .Keywords}}<strong>{{.Name}}</strong>{{with .Level}} ({{.}}){{end}}: {{join .Keywords ", "}}{{else}}{{.Name}}{{with .Level}} ({{.}}){{end}}{{end}}</li>{{end}}</ul>
+</section>{{end}}
+{{with .Education}}<section class="education">
+<h2>Education</h2>
+{{range .}}<article>
+<div class="entry-head"><h3>{{joinNonEmpty ", " (joinNonEmpty " in " .StudyType .Area) .Institution}}</h3><span class="dates">{{dates .StartDate .EndDate}}</span></div>
+</article>
+{{end}}</section>{{end}}
+{{with .Certificates}}<section>
+<h2>Certificates</h2>
+<ul>{{range .}}<li>{{joinNonEmpty ", " .Name .Issuer .Date}}</li>{{end}}</ul>
+</section>{{end}}
+</main>
+</body>
+</html>
+`
+
+// blankLines matches runs of empty lines left by template sections that render nothing
+var blankLines = regexp.MustCompile(`\n(?:[ \t]*\n){2,}`)
+
+// renderResume renders a structured resume in format
+func renderResume(r *JSONResume, format string, cfg ResumeConfig) ([]byte, error) {
+	switch format {
+	case formatJSONResume:
+		return json.MarshalIndent(r, "", "  ")
+	case formatMarkdown:
+		return renderResumeMarkdown(r, cfg)
+	case formatHTML:
+		return renderResumeHTML(r, cfg)
+	case formatPDF:
+		return renderResumePDF(r, resumeThemes[cfg.Theme])
+	case formatLaTeX:
+		return renderResumeLaTeX(r, cfg)
+	default:
+		return nil, fmt.Errorf("format %q is not rendered from a structured resume", format)
+	}
+}
+
+// resumeTemplate returns the template file name in the templates directory, or bundled
+func resumeTemplate(cfg ResumeConfig, name, bundled string) (string, error) {
+	if cfg.TemplatesDir == "" {
+		return bundled, nil
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.TemplatesDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return bundled, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading template: %v", err)
+	}
+	return string(data), nil
+}
+
+func renderResumeMarkdown(r *JSONResume, cfg ResumeConfig) ([]byte, error) {
+	text, err := resumeTemplate(cfg, "resume.md.tmpl", resumeMarkdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := texttemplate.New("resume.md.tmpl").Funcs(texttemplate.FuncMap(resumeTemplateFuncs)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Markdown template: %v", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r); err != nil {
+		return nil, fmt.Errorf("rendering Markdown: %v", err)
+	}
+	return []byte(strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n")) + "\n"), nil
+}
+
+func renderResumeHTML(r *JSONResume, cfg ResumeConfig) ([]byte, error) {
+	text, err := resumeTemplate(cfg, "resume.html.tmpl", resumeHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	css := resumeBaseCSS + "\n" + resumeThemes[cfg.Theme].css
+	if cfg.CSS != "" {
+		data, err := os.ReadFile(cfg.CSS)
+		if err != nil {
+			return nil, fmt.Errorf("reading stylesheet: %v", err)
+		}
+		css = string(data)
+	}
+	funcs := htmltemplate.FuncMap{"css": func() htmltemplate.CSS { return htmltemplate.CSS(css) }}
+	for name, fn := range resumeTemplateFuncs {
+		funcs[name] = fn
+	}
+	tmpl, err := htmltemplate.New("resume.html.tmpl").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML template: %v", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r); err != nil {
+		return nil, fmt.Errorf("rendering HTML: %v", err)
+	}
+	return blankLines.ReplaceAll(b.Bytes(), []byte("\n")), nil
+}
+
+// unicodePDFFont is the embedded font family used for text the standard PDF
+// fonts cannot encode, by the styles of the resume layout
+const unicodePDFFont = "DejaVu"
+
+var unicodePDFFontFiles = map[string]string{
+	"":  "fonts/DejaVuSansCondensed.ttf",
+	"B": "fonts/DejaVuSansCondensed-Bold.ttf",
+	"I": "fonts/DejaVuSansCondensed-Oblique.ttf",
+}
+
+//go:embed fonts/*.ttf
+var pdfFonts embed.FS
+
+// resumePDF lays out resume sections on A4 pages
+type resumePDF struct {
+	pdf   *gofpdf.Fpdf
+	tr    func(string) string // UTF-8 to the font's encoding
+	theme resumeTheme
+	width float64 // Between the margins
+}
+
+// renderResumePDF renders the resume with the theme's standard PDF font, which
+// covers Western European text without embedding font files. Resumes with
+// other characters, such as Cyrillic or Greek names, use the embedded UTF-8 font.
+func renderResumePDF(r *JSONResume, theme resumeTheme) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(18, 16, 18)
+	pdf.SetAutoPageBreak(true, 16)
+	pdf.SetTitle(joinNonEmpty(" - ", r.Basics.Name, r.Basics.Label), true)
+	pdf.SetAuthor(r.Basics.Name, true)
+	pdf.SetCreator("Amalgia", true)
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	w := &resumePDF{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor(""), theme: theme, width: pageWidth - left - right}
+	if text, err := json.Marshal(r); err != nil || !encodable(string(text), w.tr) {
+		for style, file := range unicodePDFFontFiles {
+			data, err := pdfFonts.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			pdf.AddUTF8FontFromBytes(unicodePDFFont, style, data)
+		}
+		w.theme.font = unicodePDFFont
+		w.tr = func(s string) string { return s }
+	}
+
+	pdf.AddPage()
+	w.header(r.Basics)
+	if r.Basics.Summary != "" {
+		w.section("Summary")
+		w.paragraph(r.Basics.Summary)
+	}
+	if len(r.Work) > 0 {
+		w.section("Experience")
+		for _, e := range r.Work {
+			w.entry(joinNonEmpty(", ", e.Position, e.Name), dateRange(e.StartDate, e.EndDate), e.URL)
+			w.meta(e.Location)
+			w.paragraph(e.Summary)
+			w.bullets(e.Highlights)
+		}
+	}
+	if len(r.Projects) > 0 {
+		w.section("Projects")
+		for _, project := range r.Projects {
+			w.entry(project.Name, "", project.URL)
+			w.meta(strings.Join(project.Keywords, ", "))
+			w.paragraph(project.Description)
+			w.bullets(project.Highlights)
+		}
+	}
+	if len(r.Skills) > 0 {
+		w.section("Skills")
+		for _, skill := range r.Skills {
+			w.labeled(joinNonEmpty(" ", skill.Name, parenthesize(skill.Level)), strings.Join(skill.Keywords, ", "))
+		}
+	}
+	if len(r.Education) > 0 {
+		w.section("Education")
+		for _, e := range r.Education {
+			w.entry(joinNonEmpty(", ", joinNonEmpty(" in ", e.StudyType, e.Area), e.Institution), dateRange(e.StartDate, e.EndDate), e.URL)
+		}
+	}
+	if len(r.Certificates) > 0 {
+		w.section("Certificates")
+		for _, c := range r.Certificates {
+			w.entry(joinNonEmpty(", ", c.Name, c.Issuer), c.Date, c.URL)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := pdf.Output(&b); err != nil {
+		return nil, fmt.Errorf("rendering PDF: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// encodable reports whether tr, a translator to a single-byte code page, can
+// encode every character of s; it replaces the others with a dot
+func encodable(s string, tr func(string) string) bool {
+	for _, r := range s {
+		if r >= 0x80 && tr(string(r)) == "." {
+			return false
+		}
+	}
+	return true
+}
+
+// parenthesize wraps s in parentheses, unless it is empty
+func parenthesize(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "(" + s + ")"
+}
+
+func (w *resumePDF) accent() {
+	w.pdf.SetTextColor(w.theme.accent[0], w.theme.accent[1], w.theme.accent[2])
+}
+
+func (w *resumePDF) gray() {
+	w.pdf.SetTextColor(90, 90, 90)
+}
+
+func (w *resumePDF) black() {
+	w.pdf.SetTextColor(34, 34, 34)
+}
+
+// header writes the name, label and contact line
+func (w *resumePDF) header(b JSONResumeBasics) {
+	w.pdf.SetFont(w.theme.font, "B", 22)
+	w.accent()
+	w.pdf.MultiCell(0, 10, w.tr(b.Name), "", "L", false)
+	if b.Label != "" {
+		w.pdf.SetFont(w.theme.font, "", 12)
+		w.gray()
+		w.pdf.MultiCell(0, 6, w.tr(b.Label), "", "L", false)
+	}
+	w.pdf.Ln(1)
+	w.pdf.SetFont(w.theme.font, "", 9.5)
+	for i, c := range resumeContacts(b) {
+		w.gray()
+		if i > 0 {
+			w.pdf.Write(5, "  |  ")
+		}
+		if c.URL != "" {
+			w.accent()
+			w.pdf.WriteLinkString(5, w.tr(c.Text), c.URL)
+		} else {
+			w.pdf.Write(5, w.tr(c.Text))
+		}
+	}
+	w.pdf.Ln(6)
+	w.black()
+}
+
+// section writes a heading with a rule below it
+func (w *resumePDF) section(title string) {
+	w.pdf.Ln(3)
+	w.pdf.SetFont(w.theme.font, "B", 12)
+	w.accent()
+	w.pdf.CellFormat(0, 7, w.tr(strings.ToUpper(title)), "", 1, "L", false, 0, "")
+	left, _, _, _ := w.pdf.GetMargins()
+	y := w.pdf.GetY()
+	w.pdf.SetDrawColor(w.theme.accent[0], w.theme.accent[1], w.theme.accent[2])
+	w.pdf.SetLineWidth(0.3)
+	w.pdf.Line(left, y, left+w.width, y)
+	w.pdf.Ln(2)
+	w.black()
+}
+
+// entry writes a bold title, linked to url when given, with dates on the right
+func (w *resumePDF) entry(title, dates, url string) {
+	w.pdf.Ln(1)
+	datesWidth := 0.0
+	if dates != "" {
+		w.pdf.SetFont(w.theme.font, "", 9.5)
+		datesWidth = w.pdf.GetStringWidth(w.tr(dates)) + 2
+	}
+	w.pdf.SetFont(w.theme.font, "B", 10.5)
+	if dates == "" {
+		w.pdf.CellFormat(w.width, 5.5, w.tr(title), "", 1, "L", false, 0, url)
+		return
+	}
+	w.pdf.CellFormat(w.width-datesWidth, 5.5, w.tr(title), "", 0, "L", false, 0, url)
+	w.pdf.SetFont(w.theme.font, "", 9.5)
+	w.gray()
+	w.pdf.CellFormat(datesWidth, 5.5, w.tr(dates), "", 1, "R", false, 0, "")
+	w.black()
+}
+
+// meta writes a line of secondary details such as a location
+func (w *resumePDF) meta(text string) {
+	if text == "" {
+		return
+	}
+	w.pdf.SetFont(w.theme.font, "I", 9.5)
+	w.gray()
+	w.pdf.MultiCell(0, 4.5, w.tr(text), "", "L", false)
+	w.black()
+}
+
+func (w *resumePDF) paragraph(text string) {
+	if text == "" {
+		return
+	}
+	w.pdf.SetFont(w.theme.font, "", 10)
+	w.pdf.MultiCell(0, 5, w.tr(text), "", "L", false)
+}
+
+// bullets writes items as a list with hanging indentation
+func (w *resumePDF) bullets(items []string) {
+	w.pdf.SetFont(w.theme.font, "", 10)
+	left, _, _, _ := w.pdf.GetMargins()
+	for _, item := range items {
+		w.pdf.SetX(left + 2)
+		w.pdf.CellFormat(4, 5, w.tr("•"), "", 0, "L", false, 0, "")
+		w.pdf.MultiCell(w.width-6, 5, w.tr(item), "", "L", false)
+	}
+}
+
+// labeled writes "label: text" as a wrapping line with a bold label
+func (w *resumePDF) labeled(label, text string) {
+	w.pdf.SetFont(w.theme.font, "B", 10)
+	if text == "" {
+		w.pdf.Write(5, w.tr(label))
+	} else {
+		w.pdf.Write(5, w.tr(label+": "))
+		w.pdf.SetFont(w.theme.font, "", 10)
+		w.pdf.Write(5, w.tr(text))
+	}
+	w.pdf.Ln(5.5)
+}
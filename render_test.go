@@ -0,0 +1,170 @@
+// Filename: render_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testResume is a small resume touching every section the templates render
+func testResume() *JSONResume {
+	return &JSONResume{
+		Basics: JSONResumeBasics{
+			Name:     "Ada Lovelace",
+			Label:    "Go developer",
+			Email:    "ada@example.com",
+			Summary:  "Builds tools for R&D teams.",
+			Profiles: []JSONResumeProfile{{Network: "GitHub", Username: "ada", URL: "https://github.com/ada"}},
+		},
+		Work:      []JSONResumeWork{{Name: "Analytical Engines", Position: "Engineer", StartDate: "2021-03", Highlights: []string{"Shipped the difference engine"}}},
+		Projects:  []JSONResumeProject{{Name: "amalgia", URL: "https://github.com/ada/amalgia", Keywords: []string{"Go", "Bubble Tea"}, Description: "Resume generator"}},
+		Skills:    []JSONResumeSkill{{Name: "Languages", Keywords: []string{"Go", "Python"}}},
+		Education: []JSONResumeEducation{{Institution: "University of London", StudyType: "BSc", Area: "Mathematics"}},
+	}
+}
+
+func TestRenderResume(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{formatMarkdown, []string{
+			"# Ada Lovelace\n", "**Go developer**", "[github.com/ada](https://github.com/ada)",
+			"## Summary\n\nBuilds tools for R&D teams.", "## Experience", "### Engineer, Analytical Engines", "- Shipped the difference engine",
+			"## Projects", "### [amalgia](https://github.com/ada/amalgia)", "*Go, Bubble Tea*",
+			"## Skills", "- **Languages**: Go, Python", "## Education", "### BSc in Mathematics, University of London",
+		}},
+		{formatHTML, []string{
+			"<!DOCTYPE html>", "<title>Ada Lovelace - Go developer</title>", "<h1>Ada Lovelace</h1>",
+			`<a href="https://github.com/ada">`, "<p>Builds tools for R&amp;D teams.</p>", "<h2>Experience</h2>",
+			"<h3>Engineer, Analytical Engines</h3>", "<li>Shipped the difference engine</li>", "<h2>Projects</h2>",
+			"<h2>Skills</h2>", "<strong>Languages</strong>: Go, Python", "<h2>Education</h2>",
+		}},
+		{formatPDF, []string{"%PDF-"}},
+		{formatLaTeX, []string{`\documentclass`, `R\&D`, "Analytical Engines"}},
+	}
+	for _, tt := range tests {
+		data, err := renderResume(testResume(), tt.format, defaultResumeConfig())
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.format, want, data)
+			}
+		}
+	}
+
+	// Sections without content leave no heading behind
+	data, err := renderResume(&JSONResume{Basics: JSONResumeBasics{Name: "Ada Lovelace"}}, formatMarkdown, defaultResumeConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "# Ada Lovelace\n" {
+		t.Errorf("got %q, want only the name", got)
+	}
+
+	if _, err := renderResume(testResume(), formatText, defaultResumeConfig()); err == nil {
+		t.Error("rendering plain text succeeded, want an error")
+	}
+}
+
+func TestRenderResumeJSONResumeRoundTrip(t *testing.T) {
+	data, err := renderResume(testResume(), formatJSONResume, defaultResumeConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got JSONResume
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, testResume()) {
+		t.Errorf("got %+v, want the resume back", got)
+	}
+}
+
+func TestRenderResumeHTMLThemes(t *testing.T) {
+	for _, name := range resumeThemeNames() {
+		cfg := defaultResumeConfig()
+		cfg.Theme = name
+		data, err := renderResume(testResume(), formatHTML, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Contains(data, []byte(resumeThemes[name].css)) {
+			t.Errorf("%s: the theme's stylesheet is not inlined", name)
+		}
+		for other, theme := range resumeThemes {
+			if other != name && bytes.Contains(data, []byte(theme.css)) {
+				t.Errorf("%s: the stylesheet of %s is inlined too", name, other)
+			}
+		}
+	}
+
+	// A stylesheet of one's own replaces the theme's
+	dir := t.TempDir()
+	cfg := defaultResumeConfig()
+	cfg.CSS = filepath.Join(dir, "resume.css")
+	if err := os.WriteFile(cfg.CSS, []byte("body { color: rebeccapurple; }"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := renderResume(testResume(), formatHTML, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if html := string(data); !strings.Contains(html, "rebeccapurple") || strings.Contains(html, resumeThemes[cfg.Theme].css) {
+		t.Errorf("the stylesheet did not replace the theme:\n%s", html)
+	}
+}
+
+func TestRenderResumeTemplatesDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "resume.md.tmpl"), []byte("{{.Basics.Name}} ({{join (index .Skills 0).Keywords \"/\"}})"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultResumeConfig()
+	cfg.TemplatesDir = dir
+	data, err := renderResume(testResume(), formatMarkdown, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "Ada Lovelace (Go/Python)\n" {
+		t.Errorf("got %q, want the template of the directory", got)
+	}
+
+	// Formats without a template in the directory use the bundled one
+	data, err = renderResume(testResume(), formatHTML, cfg)
+	if err != nil || !bytes.Contains(data, []byte("<h1>Ada Lovelace</h1>")) {
+		t.Errorf("got %v, want the bundled HTML template:\n%s", err, data)
+	}
+}
+
+func TestRenderResumePDFEmbedsUnicodeFont(t *testing.T) {
+	tests := []struct {
+		name, baseFont string
+		embedded       bool
+	}{
+		{"José Müller", "/BaseFont /Times", false},
+		{"Олена Коваленко", "/BaseFont /utf8dejavu", true},
+		{"Ελένη Παππά", "/BaseFont /utf8dejavu", true},
+	}
+	for _, test := range tests {
+		resume := &JSONResume{Basics: JSONResumeBasics{Name: test.name, Label: "Go developer"}}
+		data, err := renderResumePDF(resume, resumeThemes["classic"])
+		if err != nil {
+			t.Fatalf("rendering %s: %v", test.name, err)
+		}
+		if got := bytes.Contains(data, []byte("FontFile2")); got != test.embedded {
+			t.Errorf("%s: embedded font %v, want %v", test.name, got, test.embedded)
+		}
+		if !bytes.Contains(data, []byte(test.baseFont)) {
+			t.Errorf("%s: PDF does not use %s", test.name, test.baseFont)
+		}
+	}
+}
@@ -0,0 +1,745 @@
+// Filename: stack.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Categories of detected technologies
+const (
+	stackLanguage  = "language"
+	stackFramework = "framework"
+	stackLibrary   = "library"
+	stackDatabase  = "database"
+	stackTooling   = "tooling"
+)
+
+// stackCategories are the categories in the order skills are listed
+var stackCategories = []struct{ name, title string }{
+	{stackLanguage, "Languages"},
+	{stackFramework, "Frameworks"},
+	{stackLibrary, "Libraries"},
+	{stackDatabase, "Databases"},
+	{stackTooling, "Tooling"},
+}
+
+// maxManifestSize is the largest manifest read; anything bigger is generated
+const maxManifestSize = 512 * 1024
+
+// manifestFiles are the dependency and build files analyzed at the top of each repository
+var manifestFiles = []string{"go.mod", "package.json", "requirements.txt", "pyproject.toml", "Pipfile", "setup.py", "Cargo.toml", "pom.xml", "build.gradle", "build.gradle.kts", "Gemfile", "composer.json", "Dockerfile", "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml", "Makefile", "Jenkinsfile", ".gitlab-ci.yml", ".travis.yml", "azure-pipelines.yml", ".drone.yml", ".woodpecker.yml"}
+
+// workflowDirs hold CI configuration files, one per pipeline
+var workflowDirs = []string{".github/workflows", ".gitea/workflows", ".forgejo/workflows", ".circleci"}
+
+// StackEvidence is a technology found in a repository, with the file and entry it was found in
+type StackEvidence struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Source   string `json:"source"` // e.g. "go.mod: github.com/spf13/cobra"
+}
+
+// stackRule maps a dependency to a technology
+type stackRule struct {
+	name, category string
+}
+
+// Known dependencies by ecosystem. Keys are module paths, package names or
+// Maven group:artifact prefixes; the longest matching prefix wins.
+var (
+	goStack = map[string]stackRule{
+		"github.com/gin-gonic/gin":            {"Gin", stackFramework},
+		"github.com/labstack/echo":            {"Echo", stackFramework},
+		"github.com/gofiber/fiber":            {"Fiber", stackFramework},
+		"github.com/go-chi/chi":               {"chi", stackFramework},
+		"github.com/gorilla/mux":              {"Gorilla", stackFramework},
+		"github.com/spf13/cobra":              {"Cobra", stackLibrary},
+		"github.com/charmbracelet/bubbletea":  {"Bubble Tea", stackFramework},
+		"google.golang.org/grpc":              {"gRPC", stackFramework},
+		"google.golang.org/protobuf":          {"Protocol Buffers", stackLibrary},
+		"github.com/golang/protobuf":          {"Protocol Buffers", stackLibrary},
+		"gorm.io/gorm":                        {"GORM", stackLibrary},
+		"github.com/jmoiron/sqlx":             {"sqlx", stackLibrary},
+		"github.com/jackc/pgx":                {"PostgreSQL", stackDatabase},
+		"github.com/lib/pq":                   {"PostgreSQL", stackDatabase},
+		"github.com/go-sql-driver/mysql":      {"MySQL", stackDatabase},
+		"github.com/mattn/go-sqlite3":         {"SQLite", stackDatabase},
+		"modernc.org/sqlite":                  {"SQLite", stackDatabase},
+		"go.mongodb.org/mongo-driver":         {"MongoDB", stackDatabase},
+		"github.com/redis/go-redis":           {"Redis", stackDatabase},
+		"github.com/go-redis/redis":           {"Redis", stackDatabase},
+		"github.com/aws/aws-sdk-go":           {"AWS", stackTooling},
+		"github.com/aws/aws-sdk-go-v2":        {"AWS", stackTooling},
+		"cloud.google.com/go":                 {"Google Cloud", stackTooling},
+		"github.com/Azure/azure-sdk-for-go":   {"Azure", stackTooling},
+		"k8s.io/client-go":                    {"Kubernetes", stackTooling},
+		"github.com/prometheus/client_golang": {"Prometheus", stackTooling},
+		"go.opentelemetry.io/otel":            {"OpenTelemetry", stackTooling},
+		"github.com/stretchr/testify":         {"Testify", stackTooling},
+		"github.com/google/go-github":         {"GitHub API", stackLibrary},
+		"github.com/sashabaranov/go-openai":   {"OpenAI API", stackLibrary},
+		"github.com/graphql-go/graphql":       {"GraphQL", stackLibrary},
+		"github.com/99designs/gqlgen":         {"GraphQL", stackLibrary},
+		"github.com/segmentio/kafka-go":       {"Kafka", stackTooling},
+		"github.com/IBM/sarama":               {"Kafka", stackTooling},
+		"github.com/Shopify/sarama":           {"Kafka", stackTooling},
+		"github.com/nats-io/nats.go":          {"NATS", stackTooling},
+		"go.temporal.io/sdk":                  {"Temporal", stackTooling},
+	}
+	npmStack = map[string]stackRule{
+		"typescript":       {"TypeScript", stackLanguage},
+		"react":            {"React", stackFramework},
+		"react-native":     {"React Native", stackFramework},
+		"next":             {"Next.js", stackFramework},
+		"vue":              {"Vue.js", stackFramework},
+		"nuxt":             {"Nuxt", stackFramework},
+		"svelte":           {"Svelte", stackFramework},
+		"@sveltejs/kit":    {"SvelteKit", stackFramework},
+		"@angular/core":    {"Angular", stackFramework},
+		"solid-js":         {"Solid", stackFramework},
+		"express":          {"Express", stackFramework},
+		"@nestjs/core":     {"NestJS", stackFramework},
+		"fastify":          {"Fastify", stackFramework},
+		"koa":              {"Koa", stackFramework},
+		"electron":         {"Electron", stackFramework},
+		"redux":            {"Redux", stackLibrary},
+		"@reduxjs/toolkit": {"Redux", stackLibrary},
+		"graphql":          {"GraphQL", stackLibrary},
+		"@apollo/client":   {"Apollo", stackLibrary},
+		"@apollo/server":   {"Apollo", stackLibrary},
+		"apollo-server":    {"Apollo", stackLibrary},
+		"socket.io":        {"Socket.IO", stackLibrary},
+		"three":            {"three.js", stackLibrary},
+		"d3":               {"D3.js", stackLibrary},
+		"tailwindcss":      {"Tailwind CSS", stackLibrary},
+		"prisma":           {"Prisma", stackLibrary},
+		"@prisma/client":   {"Prisma", stackLibrary},
+		"typeorm":          {"TypeORM", stackLibrary},
+		"sequelize":        {"Sequelize", stackLibrary},
+		"mongoose":         {"MongoDB", stackDatabase},
+		"mongodb":          {"MongoDB", stackDatabase},
+		"pg":               {"PostgreSQL", stackDatabase},
+		"mysql2":           {"MySQL", stackDatabase},
+		"redis":            {"Redis", stackDatabase},
+		"ioredis":          {"Redis", stackDatabase},
+		"firebase":         {"Firebase", stackTooling},
+		"aws-sdk":          {"AWS", stackTooling},
+		"@aws-sdk/":        {"AWS", stackTooling},
+		"jest":             {"Jest", stackTooling},
+		"vitest":           {"Vitest", stackTooling},
+		"mocha":            {"Mocha", stackTooling},
+		"cypress":          {"Cypress", stackTooling},
+		"@playwright/test": {"Playwright", stackTooling},
+		"webpack":          {"webpack", stackTooling},
+		"vite":             {"Vite", stackTooling},
+		"eslint":           {"ESLint", stackTooling},
+		"openai":           {"OpenAI API", stackLibrary},
+	}
+	pythonStack = map[string]stackRule{
+		"django":          {"Django", stackFramework},
+		"flask":           {"Flask", stackFramework},
+		"fastapi":         {"FastAPI", stackFramework},
+		"streamlit":       {"Streamlit", stackFramework},
+		"numpy":           {"NumPy", stackLibrary},
+		"pandas":          {"pandas", stackLibrary},
+		"scipy":           {"SciPy", stackLibrary},
+		"scikit-learn":    {"scikit-learn", stackLibrary},
+		"tensorflow":      {"TensorFlow", stackFramework},
+		"torch":           {"PyTorch", stackFramework},
+		"keras":           {"Keras", stackFramework},
+		"transformers":    {"Hugging Face Transformers", stackLibrary},
+		"langchain":       {"LangChain", stackLibrary},
+		"openai":          {"OpenAI API", stackLibrary},
+		"matplotlib":      {"Matplotlib", stackLibrary},
+		"opencv-python":   {"OpenCV", stackLibrary},
+		"sqlalchemy":      {"SQLAlchemy", stackLibrary},
+		"pydantic":        {"Pydantic", stackLibrary},
+		"celery":          {"Celery", stackTooling},
+		"psycopg2":        {"PostgreSQL", stackDatabase},
+		"psycopg2-binary": {"PostgreSQL", stackDatabase},
+		"psycopg":         {"PostgreSQL", stackDatabase},
+		"pymongo":         {"MongoDB", stackDatabase},
+		"redis":           {"Redis", stackDatabase},
+		"boto3":           {"AWS", stackTooling},
+		"pytest":          {"pytest", stackTooling},
+	}
+	cargoStack = map[string]stackRule{
+		"tokio":        {"Tokio", stackFramework},
+		"actix-web":    {"Actix Web", stackFramework},
+		"axum":         {"Axum", stackFramework},
+		"rocket":       {"Rocket", stackFramework},
+		"warp":         {"warp", stackFramework},
+		"bevy":         {"Bevy", stackFramework},
+		"serde":        {"Serde", stackLibrary},
+		"clap":         {"clap", stackLibrary},
+		"reqwest":      {"reqwest", stackLibrary},
+		"diesel":       {"Diesel", stackLibrary},
+		"sqlx":         {"SQLx", stackLibrary},
+		"wasm-bindgen": {"WebAssembly", stackTooling},
+	}
+	mavenStack = map[string]stackRule{
+		"org.springframework.boot":   {"Spring Boot", stackFramework},
+		"org.springframework":        {"Spring", stackFramework},
+		"io.quarkus":                 {"Quarkus", stackFramework},
+		"io.micronaut":               {"Micronaut", stackFramework},
+		"org.hibernate":              {"Hibernate", stackLibrary},
+		"com.fasterxml.jackson":      {"Jackson", stackLibrary},
+		"org.projectlombok":          {"Lombok", stackLibrary},
+		"org.apache.kafka":           {"Kafka", stackTooling},
+		"org.postgresql":             {"PostgreSQL", stackDatabase},
+		"com.mysql":                  {"MySQL", stackDatabase},
+		"mysql:mysql-connector-java": {"MySQL", stackDatabase},
+		"org.mongodb":                {"MongoDB", stackDatabase},
+		"junit":                      {"JUnit", stackTooling},
+		"org.junit":                  {"JUnit", stackTooling},
+		"org.mockito":                {"Mockito", stackTooling},
+		"org.jetbrains.kotlin":       {"Kotlin", stackLanguage},
+		"com.android.tools.build":    {"Android", stackFramework},
+	}
+	rubyStack = map[string]stackRule{
+		"rails":   {"Ruby on Rails", stackFramework},
+		"sinatra": {"Sinatra", stackFramework},
+		"rspec":   {"RSpec", stackTooling},
+		"pg":      {"PostgreSQL", stackDatabase},
+		"sidekiq": {"Sidekiq", stackTooling},
+	}
+	phpStack = map[string]stackRule{
+		"laravel/framework": {"Laravel", stackFramework},
+		"symfony/":          {"Symfony", stackFramework},
+		"phpunit/phpunit":   {"PHPUnit", stackTooling},
+	}
+	// Images in Dockerfiles and compose files
+	imageStack = map[string]stackRule{
+		"postgres":        {"PostgreSQL", stackDatabase},
+		"mysql":           {"MySQL", stackDatabase},
+		"mariadb":         {"MariaDB", stackDatabase},
+		"mongo":           {"MongoDB", stackDatabase},
+		"redis":           {"Redis", stackDatabase},
+		"elasticsearch":   {"Elasticsearch", stackDatabase},
+		"rabbitmq":        {"RabbitMQ", stackTooling},
+		"nginx":           {"nginx", stackTooling},
+		"golang":          {"Go", stackLanguage},
+		"node":            {"JavaScript", stackLanguage},
+		"python":          {"Python", stackLanguage},
+		"rust":            {"Rust", stackLanguage},
+		"openjdk":         {"Java", stackLanguage},
+		"eclipse-temurin": {"Java", stackLanguage},
+		"ruby":            {"Ruby", stackLanguage},
+		"php":             {"PHP", stackLanguage},
+	}
+	// CI configuration files and directories
+	ciStack = map[string]stackRule{
+		".github/workflows":   {"GitHub Actions", stackTooling},
+		".gitea/workflows":    {"Gitea Actions", stackTooling},
+		".forgejo/workflows":  {"Forgejo Actions", stackTooling},
+		".circleci":           {"CircleCI", stackTooling},
+		".gitlab-ci.yml":      {"GitLab CI", stackTooling},
+		".travis.yml":         {"Travis CI", stackTooling},
+		"azure-pipelines.yml": {"Azure Pipelines", stackTooling},
+		".drone.yml":          {"Drone CI", stackTooling},
+		".woodpecker.yml":     {"Woodpecker CI", stackTooling},
+		"Jenkinsfile":         {"Jenkins", stackTooling},
+	}
+	// Actions and tools referenced from CI pipelines
+	ciToolStack = map[string]stackRule{
+		"goreleaser":                {"GoReleaser", stackTooling},
+		"docker/":                   {"Docker", stackTooling},
+		"hashicorp/setup-terraform": {"Terraform", stackTooling},
+		"terraform":                 {"Terraform", stackTooling},
+		"aws-actions/":              {"AWS", stackTooling},
+		"google-github-actions/":    {"Google Cloud", stackTooling},
+		"azure/":                    {"Azure", stackTooling},
+		"kubectl":                   {"Kubernetes", stackTooling},
+		"helm":                      {"Helm", stackTooling},
+	}
+)
+
+// lookupStack returns the rule with the longest key that prefixes name
+func lookupStack(rules map[string]stackRule, name string) (stackRule, bool) {
+	var best string
+	for key := range rules {
+		if strings.HasPrefix(name, key) && len(key) > len(best) {
+			// Match whole path segments or names only
+			if rest := name[len(key):]; rest == "" || strings.HasSuffix(key, "/") || strings.ContainsAny(rest[:1], "/:.@") {
+				best = key
+			}
+		}
+	}
+	if best == "" {
+		return stackRule{}, false
+	}
+	return rules[best], true
+}
+
+// stackCollector gathers evidence, keeping the first source of each technology
+type stackCollector struct {
+	found map[string]bool
+	stack []StackEvidence
+}
+
+func (c *stackCollector) add(name, category, source string) {
+	if c.found == nil {
+		c.found = make(map[string]bool)
+	}
+	if c.found[name] {
+		return
+	}
+	c.found[name] = true
+	c.stack = append(c.stack, StackEvidence{Name: name, Category: category, Source: source})
+}
+
+// match adds the technology a dependency maps to, if any
+func (c *stackCollector) match(rules map[string]stackRule, file, dependency string) {
+	if rule, ok := lookupStack(rules, dependency); ok {
+		c.add(rule.name, rule.category, file+": "+dependency)
+	}
+}
+
+// detectStack derives the languages, frameworks, databases and tools used by
+// a repository from its manifests and CI configuration, keyed by path
+func detectStack(files map[string]string) []StackEvidence {
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	c := &stackCollector{stack: []StackEvidence{}}
+	for _, p := range paths {
+		content := files[p]
+		base := path.Base(p)
+		if rule, ok := ciStack[path.Dir(p)]; ok {
+			c.add(rule.name, rule.category, p)
+			detectCITools(c, p, content)
+			continue
+		}
+		if rule, ok := ciStack[p]; ok {
+			c.add(rule.name, rule.category, p)
+			detectCITools(c, p, content)
+			continue
+		}
+
+		switch {
+		case base == "go.mod":
+			c.add("Go", stackLanguage, p)
+			for _, module := range goModRequires(content) {
+				c.match(goStack, p, module)
+			}
+		case base == "package.json":
+			var pkg struct {
+				Dependencies    map[string]interface{} `json:"dependencies"`
+				DevDependencies map[string]interface{} `json:"devDependencies"`
+			}
+			if err := json.Unmarshal([]byte(content), &pkg); err != nil {
+				continue
+			}
+			c.add("JavaScript", stackLanguage, p)
+			c.add("Node.js", stackTooling, p)
+			for _, name := range sortedKeys(pkg.Dependencies, pkg.DevDependencies) {
+				c.match(npmStack, p, name)
+			}
+		case base == "requirements.txt":
+			c.add("Python", stackLanguage, p)
+			for _, name := range requirementNames(strings.Split(content, "\n")) {
+				c.match(pythonStack, p, name)
+			}
+		case base == "pyproject.toml":
+			c.add("Python", stackLanguage, p)
+			for _, name := range pyprojectDependencies(content) {
+				c.match(pythonStack, p, name)
+			}
+		case base == "Pipfile":
+			c.add("Python", stackLanguage, p)
+			var pipfile struct {
+				Packages    map[string]interface{} `toml:"packages"`
+				DevPackages map[string]interface{} `toml:"dev-packages"`
+			}
+			if _, err := toml.Decode(content, &pipfile); err == nil {
+				for _, name := range sortedKeys(pipfile.Packages, pipfile.DevPackages) {
+					c.match(pythonStack, p, strings.ToLower(name))
+				}
+			}
+		case base == "setup.py":
+			c.add("Python", stackLanguage, p)
+		case base == "Cargo.toml":
+			c.add("Rust", stackLanguage, p)
+			c.add("Cargo", stackTooling, p)
+			var cargo struct {
+				Dependencies    map[string]interface{} `toml:"dependencies"`
+				DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			}
+			if _, err := toml.Decode(content, &cargo); err == nil {
+				for _, name := range sortedKeys(cargo.Dependencies, cargo.DevDependencies) {
+					c.match(cargoStack, p, name)
+				}
+			}
+		case base == "pom.xml":
+			c.add("Java", stackLanguage, p)
+			c.add("Maven", stackTooling, p)
+			for _, coordinate := range pomDependencies(content) {
+				c.match(mavenStack, p, coordinate)
+			}
+		case base == "build.gradle" || base == "build.gradle.kts":
+			if strings.HasSuffix(base, ".kts") || strings.Contains(content, "kotlin") {
+				c.add("Kotlin", stackLanguage, p)
+			} else {
+				c.add("Java", stackLanguage, p)
+			}
+			c.add("Gradle", stackTooling, p)
+			for _, coordinate := range gradleCoordinatePattern.FindAllString(content, -1) {
+				c.match(mavenStack, p, coordinate)
+			}
+		case base == "Gemfile":
+			c.add("Ruby", stackLanguage, p)
+			for _, m := range gemPattern.FindAllStringSubmatch(content, -1) {
+				c.match(rubyStack, p, m[1])
+			}
+		case base == "composer.json":
+			var composer struct {
+				Require    map[string]interface{} `json:"require"`
+				RequireDev map[string]interface{} `json:"require-dev"`
+			}
+			if err := json.Unmarshal([]byte(content), &composer); err != nil {
+				continue
+			}
+			c.add("PHP", stackLanguage, p)
+			for _, name := range sortedKeys(composer.Require, composer.RequireDev) {
+				c.match(phpStack, p, name)
+			}
+		case base == "Dockerfile":
+			c.add("Docker", stackTooling, p)
+			for _, m := range dockerFromPattern.FindAllStringSubmatch(content, -1) {
+				c.match(imageStack, p, imageName(m[1]))
+			}
+		case strings.Contains(base, "compose"):
+			c.add("Docker Compose", stackTooling, p)
+			for _, m := range composeImagePattern.FindAllStringSubmatch(content, -1) {
+				c.match(imageStack, p, imageName(m[1]))
+			}
+		case base == "Makefile":
+			c.add("Make", stackTooling, p)
+		}
+	}
+	return c.stack
+}
+
+var (
+	gradleCoordinatePattern = regexp.MustCompile(`[A-Za-z0-9_.\-]+:[A-Za-z0-9_.\-]+`)
+	gemPattern              = regexp.MustCompile(`(?m)^\s*gem\s+['"]([^'"]+)['"]`)
+	dockerFromPattern       = regexp.MustCompile(`(?mi)^\s*FROM\s+(?:--platform=\S+\s+)?(\S+)`)
+	composeImagePattern     = regexp.MustCompile(`(?m)^\s*image:\s*['"]?([^\s'"]+)`)
+	ciToolPattern           = regexp.MustCompile(`(?m)(?:uses:|image:|run:)\s*['"]?([^\s'"]+)`)
+)
+
+// detectCITools adds the notable actions, images and commands used by a pipeline
+func detectCITools(c *stackCollector, file, content string) {
+	for _, m := range ciToolPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.ToLower(m[1])
+		if i := strings.LastIndex(name, "/"); i >= 0 && !strings.Contains(name[:i], "/") {
+			// owner/action@version: match on the owner and the action
+			c.match(ciToolStack, file, name)
+			continue
+		}
+		c.match(ciToolStack, file, imageName(name))
+	}
+}
+
+// imageName strips the registry, tag and digest from a container image reference
+func imageName(image string) string {
+	image = strings.ToLower(image)
+	if i := strings.IndexAny(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	image = strings.TrimPrefix(image, "docker.io/")
+	image = strings.TrimPrefix(image, "library/")
+	if i := strings.LastIndex(image, "/"); i >= 0 && strings.ContainsAny(image[:i], ".:") {
+		image = image[i+1:]
+	}
+	return image
+}
+
+// goModRequires returns the module paths required by a go.mod file
+func goModRequires(content string) []string {
+	var modules []string
+	inBlock := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			if strings.Contains(line[i:], "indirect") {
+				// Dependencies of dependencies say nothing about the project
+				continue
+			}
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimPrefix(line, "require ")
+		case !inBlock:
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			modules = append(modules, fields[0])
+		}
+	}
+	return modules
+}
+
+// requirementNamePattern matches the distribution name at the start of a requirement
+var requirementNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._\-]*`)
+
+// requirementNames returns the normalized package names of PEP 508 requirements
+func requirementNames(requirements []string) []string {
+	var names []string
+	for _, line := range requirements {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if name := requirementNamePattern.FindString(line); name != "" {
+			names = append(names, strings.ReplaceAll(strings.ToLower(name), "_", "-"))
+		}
+	}
+	return names
+}
+
+// pyprojectDependencies reads PEP 621 and Poetry dependencies
+func pyprojectDependencies(content string) []string {
+	var pyproject struct {
+		Project struct {
+			Dependencies         []string            `toml:"dependencies"`
+			OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+		} `toml:"project"`
+		Tool struct {
+			Poetry struct {
+				Dependencies    map[string]interface{} `toml:"dependencies"`
+				DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+				Group           map[string]struct {
+					Dependencies map[string]interface{} `toml:"dependencies"`
+				} `toml:"group"`
+			} `toml:"poetry"`
+		} `toml:"tool"`
+	}
+	if _, err := toml.Decode(content, &pyproject); err != nil {
+		return nil
+	}
+	requirements := pyproject.Project.Dependencies
+	for _, extra := range pyproject.Project.OptionalDependencies {
+		requirements = append(requirements, extra...)
+	}
+	poetry := pyproject.Tool.Poetry
+	requirements = append(requirements, sortedKeys(poetry.Dependencies, poetry.DevDependencies)...)
+	for _, group := range poetry.Group {
+		requirements = append(requirements, sortedKeys(group.Dependencies)...)
+	}
+	sort.Strings(requirements)
+	return requirementNames(requirements)
+}
+
+// pomDependencies returns the group:artifact coordinates of a pom.xml's
+// dependencies and plugins
+func pomDependencies(content string) []string {
+	var coordinates []string
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	var group string
+	var element string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			element = t.Name.Local
+		case xml.EndElement:
+			element = ""
+		case xml.CharData:
+			value := strings.TrimSpace(string(t))
+			switch element {
+			case "groupId":
+				group = value
+			case "artifactId":
+				if group != "" {
+					coordinates = append(coordinates, group+":"+value)
+				}
+				group = ""
+			}
+		}
+	}
+	return coordinates
+}
+
+// sortedKeys returns the keys of the maps, sorted and without duplicates
+func sortedKeys(maps ...map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isManifest reports whether a file at the top of a repository is analyzed
+func isManifest(name string) bool {
+	return contains(manifestFiles, name)
+}
+
+// collectManifests reads the manifests and CI configuration of a repository
+// through list, which returns the file and directory names in a directory
+// ("" for the top), and read, which returns a file's content
+func collectManifests(list func(dir string) (files, dirs []string, err error), read func(path string) (string, error)) (map[string]string, error) {
+	files, dirs, err := list("")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range files {
+		if isManifest(name) {
+			paths = append(paths, name)
+		}
+	}
+	for _, dir := range workflowDirs {
+		if !contains(dirs, strings.Split(dir, "/")[0]) {
+			continue
+		}
+		workflows, _, err := list(dir)
+		if err != nil {
+			// The parent exists without the workflow directory
+			continue
+		}
+		for _, name := range workflows {
+			if strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml") {
+				paths = append(paths, dir+"/"+name)
+			}
+		}
+	}
+
+	contents := make(map[string]string)
+	for _, p := range paths {
+		content, err := read(p)
+		if err != nil {
+			return nil, err
+		}
+		contents[p] = content
+	}
+	return contents, nil
+}
+
+// localManifests reads the manifests and CI configuration of a working tree
+func localManifests(root string) map[string]string {
+	list := func(dir string) ([]string, []string, error) {
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			return nil, nil, err
+		}
+		var files, dirs []string
+		for _, entry := range entries {
+			if entry.IsDir() {
+				dirs = append(dirs, entry.Name())
+			} else if entry.Type().IsRegular() {
+				files = append(files, entry.Name())
+			}
+		}
+		return files, dirs, nil
+	}
+	read := func(p string) (string, error) {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if info, err := os.Stat(full); err == nil && info.Size() > maxManifestSize {
+			return "", nil
+		}
+		data, err := os.ReadFile(full)
+		return string(data), err
+	}
+	contents, err := collectManifests(list, read)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return contents
+}
+
+// StackSkill is a technology used across the profile's projects, with the
+// repositories and manifest entries it was found in
+type StackSkill struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Repos    []string `json:"repos"`
+	Evidence []string `json:"evidence,omitempty"` // "repo: file: dependency"
+}
+
+// setStack replaces the detected skills with those found in the manifests of
+// the README projects, most widely used first
+func (p *Profile) setStack(metadata map[string]*RepoMetadata) {
+	skills := make(map[string]*StackSkill)
+	for _, name := range p.readmeProjects() {
+		md := metadata[name]
+		if md == nil {
+			continue
+		}
+		for _, evidence := range md.Stack {
+			skill := skills[evidence.Name]
+			if skill == nil {
+				skill = &StackSkill{Name: evidence.Name, Category: evidence.Category}
+				skills[evidence.Name] = skill
+			}
+			skill.Repos = append(skill.Repos, name)
+			skill.Evidence = append(skill.Evidence, name+": "+evidence.Source)
+		}
+	}
+
+	p.Stack = nil
+	for _, skill := range skills {
+		p.Stack = append(p.Stack, *skill)
+	}
+	sort.Slice(p.Stack, func(i, j int) bool {
+		if len(p.Stack[i].Repos) != len(p.Stack[j].Repos) {
+			return len(p.Stack[i].Repos) > len(p.Stack[j].Repos)
+		}
+		return p.Stack[i].Name < p.Stack[j].Name
+	})
+}
+
+// projectStack returns the names of the detected technologies used by a project
+func (p *Profile) projectStack(name string) []string {
+	var names []string
+	for _, skill := range p.Stack {
+		if contains(skill.Repos, name) {
+			names = append(names, skill.Name)
+		}
+	}
+	return names
+}
+
+// stackNames returns the names of the technologies in a repository's evidence
+func stackNames(stack []StackEvidence) []string {
+	var names []string
+	for _, evidence := range stack {
+		names = append(names, evidence.Name)
+	}
+	return names
+}
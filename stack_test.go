@@ -0,0 +1,256 @@
+// Filename: stack_test.go
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "go.mod",
+			files: map[string]string{"go.mod": "module example.com/api\n\ngo 1.21\n\n" +
+				"require github.com/spf13/cobra v1.8.0\n\n" +
+				"require (\n" +
+				"\tgithub.com/gin-gonic/gin v1.9.1\n" +
+				"\tgithub.com/jackc/pgx/v5 v5.5.0 // database\n" +
+				"\tgithub.com/aws/aws-sdk-go-v2/service/s3 v1.40.0\n" +
+				"\tgithub.com/ginkgo/other v1.0.0\n" +
+				"\tgithub.com/redis/go-redis/v9 v9.0.0 // indirect\n" +
+				")\n"},
+			want: []string{"Go", "Cobra", "Gin", "PostgreSQL", "AWS"},
+		},
+		{
+			name: "package.json",
+			files: map[string]string{"web/package.json": `{
+				"dependencies": {"react": "^18.0.0", "next": "14.0.0", "@aws-sdk/client-s3": "3.0.0", "left-pad": "1.0.0"},
+				"devDependencies": {"typescript": "5.0.0", "vitest": "1.0.0", "react": "^18.0.0"}
+			}`},
+			want: []string{"JavaScript", "Node.js", "AWS", "Next.js", "React", "TypeScript", "Vitest"},
+		},
+		{
+			name:  "invalid package.json",
+			files: map[string]string{"package.json": "{"},
+			want:  []string{},
+		},
+		{
+			name:  "requirements.txt",
+			files: map[string]string{"requirements.txt": "# web\nDjango>=4.2\n-r base.txt\npsycopg2_binary==2.9\nscikit-learn[all] ; python_version > '3.8'\n"},
+			want:  []string{"Python", "Django", "PostgreSQL", "scikit-learn"},
+		},
+		{
+			name: "pyproject.toml",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"svc\"\ndependencies = [\"fastapi>=0.100\", \"SQLAlchemy\"]\n\n" +
+				"[project.optional-dependencies]\ntest = [\"pytest\"]\n\n" +
+				"[tool.poetry.group.ml.dependencies]\ntorch = \"^2.0\"\n"},
+			want: []string{"Python", "SQLAlchemy", "FastAPI", "pytest", "PyTorch"},
+		},
+		{
+			name:  "Pipfile",
+			files: map[string]string{"Pipfile": "[packages]\nFlask = \"*\"\n\n[dev-packages]\npytest = \"*\"\n"},
+			want:  []string{"Python", "Flask", "pytest"},
+		},
+		{
+			name:  "Cargo.toml",
+			files: map[string]string{"Cargo.toml": "[package]\nname = \"cli\"\n\n[dependencies]\nclap = { version = \"4\", features = [\"derive\"] }\ntokio = \"1\"\n\n[dev-dependencies]\nserde = \"1\"\n"},
+			want:  []string{"Rust", "Cargo", "clap", "Serde", "Tokio"},
+		},
+		{
+			name: "pom.xml",
+			files: map[string]string{"pom.xml": `<project><parent><groupId>org.springframework.boot</groupId><artifactId>spring-boot-starter-parent</artifactId></parent>
+				<dependencies><dependency><groupId>org.postgresql</groupId><artifactId>postgresql</artifactId></dependency>
+				<dependency><groupId>org.junit.jupiter</groupId><artifactId>junit-jupiter</artifactId></dependency></dependencies></project>`},
+			want: []string{"Java", "Maven", "Spring Boot", "PostgreSQL", "JUnit"},
+		},
+		{
+			name:  "build.gradle.kts",
+			files: map[string]string{"build.gradle.kts": "dependencies {\n    implementation(\"io.quarkus:quarkus-core:3.0.0\")\n}\n"},
+			want:  []string{"Kotlin", "Gradle", "Quarkus"},
+		},
+		{
+			name:  "Gemfile",
+			files: map[string]string{"Gemfile": "source 'https://rubygems.org'\ngem 'rails', '~> 7.0'\ngem \"pg\"\n"},
+			want:  []string{"Ruby", "Ruby on Rails", "PostgreSQL"},
+		},
+		{
+			name:  "composer.json",
+			files: map[string]string{"composer.json": `{"require": {"laravel/framework": "^10.0", "symfony/console": "^6.0"}}`},
+			want:  []string{"PHP", "Laravel", "Symfony"},
+		},
+		{
+			name: "Dockerfile and compose",
+			files: map[string]string{
+				"Dockerfile":         "FROM --platform=linux/amd64 golang:1.21 AS build\nFROM gcr.io/distroless/static\n",
+				"docker-compose.yml": "services:\n  db:\n    image: \"postgres:16\"\n  cache:\n    image: docker.io/library/redis@sha256:abc\n",
+			},
+			want: []string{"Docker", "Go", "Docker Compose", "PostgreSQL", "Redis"},
+		},
+		{
+			name: "workflow YAML",
+			files: map[string]string{
+				".github/workflows/release.yml": "jobs:\n  release:\n    steps:\n      - uses: actions/checkout@v4\n      - uses: hashicorp/setup-terraform@v3\n      - uses: docker/build-push-action@v5\n      - run: kubectl apply -f k8s/\n",
+				".gitlab-ci.yml":                "build:\n  image: golang:1.21\n  script: go build\n",
+				"Makefile":                      "build:\n\tgo build ./...\n",
+			},
+			want: []string{"GitHub Actions", "Terraform", "Docker", "Kubernetes", "GitLab CI", "Make"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stackNames(detectStack(tt.files))
+			if got == nil {
+				got = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectStackEvidence(t *testing.T) {
+	stack := detectStack(map[string]string{
+		"go.mod":       "module x\n\nrequire github.com/lib/pq v1.10.0\n",
+		"package.json": `{"dependencies": {"pg": "8.0.0"}}`,
+	})
+	// Each technology is listed once, with the first file it was found in
+	want := []StackEvidence{
+		{Name: "Go", Category: stackLanguage, Source: "go.mod"},
+		{Name: "PostgreSQL", Category: stackDatabase, Source: "go.mod: github.com/lib/pq"},
+		{Name: "JavaScript", Category: stackLanguage, Source: "package.json"},
+		{Name: "Node.js", Category: stackTooling, Source: "package.json"},
+	}
+	if !reflect.DeepEqual(stack, want) {
+		t.Errorf("got %+v\nwant %+v", stack, want)
+	}
+}
+
+func TestLookupStack(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"github.com/aws/aws-sdk-go", "AWS"},
+		{"github.com/aws/aws-sdk-go-v2/config", "AWS"},
+		{"github.com/labstack/echo/v4", "Echo"},
+		{"github.com/labstack/echoes", ""},
+		{"@aws-sdk/client-dynamodb", "AWS"},
+		{"react-native", "React Native"},
+		{"react-dom", ""},
+		{"org.springframework.boot:spring-boot-starter-web", "Spring Boot"},
+		{"org.springframework:spring-core", "Spring"},
+	}
+	rules := map[string]stackRule{}
+	for _, table := range []map[string]stackRule{goStack, npmStack, mavenStack} {
+		for key, rule := range table {
+			rules[key] = rule
+		}
+	}
+	for _, tt := range tests {
+		rule, _ := lookupStack(rules, tt.name)
+		if rule.name != tt.want {
+			t.Errorf("lookupStack(%q) = %q, want %q", tt.name, rule.name, tt.want)
+		}
+	}
+}
+
+func TestImageName(t *testing.T) {
+	tests := map[string]string{
+		"golang:1.21":                        "golang",
+		"postgres":                           "postgres",
+		"docker.io/library/redis@sha256:abc": "redis",
+		"ghcr.io/owner/postgres:16":          "postgres",
+		"localhost:5000/mysql:8":             "mysql",
+		"bitnami/redis:7":                    "bitnami/redis",
+		"Node:20-alpine":                     "node",
+	}
+	for image, want := range tests {
+		if got := imageName(image); got != want {
+			t.Errorf("imageName(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestCollectManifests(t *testing.T) {
+	tree := map[string][]string{
+		"":                  {"go.mod", "README.md", "main.go", "Dockerfile", ".github", ".circleci", "docs"},
+		".github/workflows": {"ci.yml", "release.yaml", "notes.txt"},
+		".circleci":         {"config.yml"},
+	}
+	dirs := map[string]bool{".github": true, ".circleci": true, "docs": true}
+	var listed []string
+	list := func(dir string) ([]string, []string, error) {
+		listed = append(listed, dir)
+		entries, ok := tree[dir]
+		if !ok {
+			return nil, nil, os.ErrNotExist
+		}
+		var files, subdirs []string
+		for _, name := range entries {
+			if dir == "" && dirs[name] {
+				subdirs = append(subdirs, name)
+			} else {
+				files = append(files, name)
+			}
+		}
+		return files, subdirs, nil
+	}
+	read := func(p string) (string, error) { return "content of " + p, nil }
+
+	contents, err := collectManifests(list, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"go.mod":                         "content of go.mod",
+		"Dockerfile":                     "content of Dockerfile",
+		".github/workflows/ci.yml":       "content of .github/workflows/ci.yml",
+		".github/workflows/release.yaml": "content of .github/workflows/release.yaml",
+		".circleci/config.yml":           "content of .circleci/config.yml",
+	}
+	if !reflect.DeepEqual(contents, want) {
+		t.Errorf("got %v\nwant %v", contents, want)
+	}
+	// Workflow directories are only listed when their parent exists
+	if !reflect.DeepEqual(listed, []string{"", ".github/workflows", ".circleci"}) {
+		t.Errorf("listed %q", listed)
+	}
+
+	failing := func(p string) (string, error) { return "", errors.New("boom") }
+	if _, err := collectManifests(list, failing); err == nil {
+		t.Error("a read error was not returned")
+	}
+}
+
+func TestLocalManifests(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Cargo.toml", "[dependencies]\naxum = \"0.7\"\n")
+	write("package.json", strings.Repeat(" ", maxManifestSize+1))
+	write(".forgejo/workflows/ci.yaml", "steps:\n  - run: helm lint\n")
+	// Only the top of the repository is analyzed
+	write("sub/go.mod", "module sub\n")
+
+	names := stackNames(detectStack(localManifests(root)))
+	want := []string{"Forgejo Actions", "Helm", "Rust", "Cargo", "Axum"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+}
@@ -0,0 +1,603 @@
+// Filename: sync.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-github/v45/github"
+)
+
+// syncIndexFile is stored next to the READMEs it describes
+const syncIndexFile = ".sync_index.json"
+
+// Outcomes of syncing a single README
+const (
+	syncFetched   = "fetched"
+	syncUnchanged = "unchanged"
+	syncMissing   = "missing"
+)
+
+// SyncEntry records what was last downloaded for a repository
+type SyncEntry struct {
+	FullName  string        `json:"full_name"`
+	File      string        `json:"file"`
+	SHA       string        `json:"sha"`
+	ETag      string        `json:"etag"`
+	PushedAt  time.Time     `json:"pushed_at"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	Source    string        `json:"source,omitempty"` // Empty for GitHub
+	Metadata  *RepoMetadata `json:"metadata,omitempty"`
+}
+
+// source returns where the README was synced from
+func (e *SyncEntry) source() string {
+	if e.Source == "" {
+		return sourceGitHub
+	}
+	return e.Source
+}
+
+// RepoMetadata is what is known about a repository besides its README
+type RepoMetadata struct {
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Homepage    string         `json:"homepage,omitempty"`
+	License     string         `json:"license,omitempty"` // SPDX ID, or the name when GitHub has none
+	Topics      []string       `json:"topics,omitempty"`
+	Languages   map[string]int `json:"languages,omitempty"` // Bytes of code per language
+	// Percent of code per language, for sources that report no sizes (GitLab)
+	LanguageShares map[string]float64 `json:"language_shares,omitempty"`
+	Stars          int                `json:"stars"`
+	Forks          int                `json:"forks"`
+	CreatedAt      time.Time          `json:"created_at"`
+	PushedAt       time.Time          `json:"pushed_at"`
+	Stack          []StackEvidence    `json:"stack"` // Technologies found in manifests; nil until analyzed
+
+	// Local repositories only
+	Path         string    `json:"path,omitempty"`
+	Manifests    []string  `json:"manifests,omitempty"`     // Dependency and build files present
+	Commits      int       `json:"commits,omitempty"`       // Commits authored by you
+	TotalCommits int       `json:"total_commits,omitempty"` // Commits by anyone
+	FirstCommit  time.Time `json:"first_commit"`            // Your first commit
+	LastCommit   time.Time `json:"last_commit"`             // Your latest commit
+}
+
+// SyncIndex maps README keys to their sync state
+type SyncIndex struct {
+	Entries map[string]*SyncEntry `json:"entries"`
+}
+
+// loadSyncIndex reads the index in dir, returning an empty one if none exists
+func loadSyncIndex(dir string) (*SyncIndex, error) {
+	index := &SyncIndex{Entries: make(map[string]*SyncEntry)}
+	data, err := os.ReadFile(filepath.Join(dir, syncIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", syncIndexFile, err)
+	}
+	if index.Entries == nil {
+		index.Entries = make(map[string]*SyncEntry)
+	}
+	return index, nil
+}
+
+// save writes the index to dir
+func (idx *SyncIndex) save(dir string) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, syncIndexFile), data, 0600)
+}
+
+// fetchRepoREADMEs is the tea.Cmd form of fetchSources
+func (m *model) fetchRepoREADMEs(ctx context.Context) tea.Cmd {
+	settings := Settings{
+		Sources: m.sources,
+		GitHub:  m.githubConfig,
+		GitLab:  m.gitlabConfig,
+		Gitea:   m.giteaConfig,
+		Paths:   m.paths,
+	}
+	send := m.sender()
+	return m.tagged(func() tea.Msg {
+		result, err := fetchSources(ctx, settings, send)
+		if err != nil {
+			return err
+		}
+		return result
+	})
+}
+
+// fetchSources syncs the READMEs of every configured source in turn. A source
+// that fails is logged and left out, keeping the READMEs it synced before;
+// the error is only returned when no source could be synced.
+func fetchSources(ctx context.Context, s Settings, send func(tea.Msg)) (FetchCompleteMsg, error) {
+	combined := FetchCompleteMsg{
+		Readmes:       make(map[string]string),
+		Metadata:      make(map[string]*RepoMetadata),
+		ReadmeSources: make(map[string]string),
+	}
+	var firstErr error
+	// Progress totals count the repositories of the sources already synced
+	offset, total := 0, 0
+	send = func(send func(tea.Msg)) func(tea.Msg) {
+		return func(msg tea.Msg) {
+			if started, ok := msg.(FetchStartedMsg); ok {
+				total = offset + started.Total
+				msg = FetchStartedMsg{Total: total}
+			}
+			send(msg)
+		}
+	}(send)
+	for _, source := range s.Sources {
+		offset = total
+		var result FetchCompleteMsg
+		var err error
+		switch source {
+		case sourceGitHub:
+			result, err = fetchREADMEs(ctx, s.GitHub, s.Paths.ReadmesDir, send)
+		case sourceGitLab:
+			result, err = fetchForgeREADMEs(ctx, source, s.GitLab, s.Paths.ReadmesDir, send)
+		case sourceGitea:
+			result, err = fetchForgeREADMEs(ctx, source, s.Gitea, s.Paths.ReadmesDir, send)
+		default:
+			err = fmt.Errorf("unknown repository source %q", source)
+		}
+		if ctx.Err() != nil {
+			return FetchCompleteMsg{}, ctx.Err()
+		}
+		if err != nil {
+			send(LogMsg(fmt.Sprintf("Fetching from %s failed: %v", source, err)))
+			combined.FailedSources = append(combined.FailedSources, source)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		combined.Sources = append(combined.Sources, result.Sources...)
+		for name, content := range result.Readmes {
+			combined.Readmes[name] = content
+		}
+		for name, md := range result.Metadata {
+			combined.Metadata[name] = md
+		}
+		for name, readmeSource := range result.ReadmeSources {
+			combined.ReadmeSources[name] = readmeSource
+		}
+		if result.Contributions != nil {
+			combined.Contributions = result.Contributions
+		}
+		combined.Names = append(combined.Names, result.Names...)
+		combined.Fetched += result.Fetched
+		combined.Unchanged += result.Unchanged
+		combined.Failed += result.Failed
+	}
+	if len(combined.Sources) == 0 {
+		return FetchCompleteMsg{}, firstErr
+	}
+	sort.Strings(combined.Names)
+	return combined, nil
+}
+
+// loadCachedREADMEs returns the READMEs recorded in the index in dir, with
+// the metadata and source of their repositories
+func loadCachedREADMEs(dir string) (FetchCompleteMsg, error) {
+	index, err := loadSyncIndex(dir)
+	if err != nil {
+		return FetchCompleteMsg{}, err
+	}
+
+	cached := FetchCompleteMsg{
+		Readmes:       make(map[string]string),
+		Metadata:      make(map[string]*RepoMetadata),
+		ReadmeSources: make(map[string]string),
+	}
+	for name, entry := range index.Entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			continue
+		}
+		cached.Readmes[name] = string(content)
+		cached.ReadmeSources[name] = entry.source()
+		cached.Names = append(cached.Names, name)
+		if entry.Metadata != nil {
+			cached.Metadata[name] = entry.Metadata
+		}
+	}
+	sort.Strings(cached.Names)
+	return cached, nil
+}
+
+// fetchRepoMetadata collects a repository's metadata. The language breakdown
+// costs a request, so it is reused from entry until the repository is pushed to.
+func fetchRepoMetadata(ctx context.Context, client *github.Client, retrier *githubRetrier, repo *github.Repository, entry *SyncEntry, analyzeStack bool) (*RepoMetadata, error) {
+	metadata := &RepoMetadata{
+		Description: repo.GetDescription(),
+		URL:         repo.GetHTMLURL(),
+		Homepage:    repo.GetHomepage(),
+		Topics:      repo.Topics,
+		Stars:       repo.GetStargazersCount(),
+		Forks:       repo.GetForksCount(),
+		CreatedAt:   repo.GetCreatedAt().Time,
+		PushedAt:    repo.GetPushedAt().Time,
+	}
+	if license := repo.GetLicense(); license != nil {
+		metadata.License = license.GetSPDXID()
+		if metadata.License == "" || metadata.License == "NOASSERTION" {
+			metadata.License = license.GetName()
+		}
+	}
+
+	previous := entry.unchangedMetadata(metadata.PushedAt)
+	if previous != nil && previous.Languages != nil {
+		metadata.Languages = previous.Languages
+	} else {
+		_, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+			metadata.Languages, resp, err = client.Repositories.ListLanguages(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+			return resp, err
+		})
+		if err != nil {
+			return metadata, fmt.Errorf("listing languages: %v", err)
+		}
+	}
+
+	if !analyzeStack {
+		return metadata, nil
+	}
+	if previous != nil && previous.Stack != nil {
+		metadata.Stack = previous.Stack
+		return metadata, nil
+	}
+	stack, err := githubStack(ctx, client, retrier, repo.GetOwner().GetLogin(), repo.GetName())
+	if err != nil {
+		return metadata, fmt.Errorf("analyzing manifests: %v", err)
+	}
+	metadata.Stack = stack
+	return metadata, nil
+}
+
+// unchangedMetadata returns the metadata recorded by the last sync if the
+// repository has not been pushed to since, or nil
+func (e *SyncEntry) unchangedMetadata(pushedAt time.Time) *RepoMetadata {
+	if e == nil || e.Metadata == nil || !e.Metadata.PushedAt.Equal(pushedAt) {
+		return nil
+	}
+	return e.Metadata
+}
+
+// githubStack analyzes the manifests on the default branch of a GitHub repository
+func githubStack(ctx context.Context, client *github.Client, retrier *githubRetrier, owner, repo string) ([]StackEvidence, error) {
+	list := func(dir string) ([]string, []string, error) {
+		var contents []*github.RepositoryContent
+		resp, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+			_, contents, resp, err = client.Repositories.GetContents(ctx, owner, repo, dir, nil)
+			return resp, err
+		})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				// Empty repository or missing directory
+				return nil, nil, nil
+			}
+			return nil, nil, err
+		}
+		var files, dirs []string
+		for _, content := range contents {
+			switch content.GetType() {
+			case "file":
+				files = append(files, content.GetName())
+			case "dir":
+				dirs = append(dirs, content.GetName())
+			}
+		}
+		return files, dirs, nil
+	}
+	read := func(path string) (string, error) {
+		var file *github.RepositoryContent
+		_, err := retrier.do(ctx, func() (resp *github.Response, err error) {
+			file, _, resp, err = client.Repositories.GetContents(ctx, owner, repo, path, nil)
+			return resp, err
+		})
+		if err != nil {
+			return "", err
+		}
+		if file == nil || file.GetSize() > maxManifestSize {
+			return "", nil
+		}
+		return file.GetContent()
+	}
+
+	files, err := collectManifests(list, read)
+	if err != nil {
+		return nil, err
+	}
+	return detectStack(files), nil
+}
+
+// languageShares returns the languages by share of the code, largest first, as
+// "Go 80%", from the sizes or, when the source reported none, its percentages
+func (md *RepoMetadata) languageShares() []string {
+	amounts := md.LanguageShares
+	if len(md.Languages) > 0 {
+		amounts = make(map[string]float64, len(md.Languages))
+		for name, bytes := range md.Languages {
+			amounts[name] = float64(bytes)
+		}
+	}
+	total := 0.0
+	var names []string
+	for name, amount := range amounts {
+		total += amount
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if amounts[names[i]] != amounts[names[j]] {
+			return amounts[names[i]] > amounts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var shares []string
+	for _, name := range names {
+		shares = append(shares, fmt.Sprintf("%s %.0f%%", name, amounts[name]*100/total))
+	}
+	return shares
+}
+
+// syncReadme downloads a repository's README unless the cached copy is current.
+// It returns the README content, the updated entry and the sync outcome.
+func syncReadme(ctx context.Context, client *github.Client, retrier *githubRetrier, dir, name string, repo *github.Repository, entry *SyncEntry) (string, *SyncEntry, string, error) {
+	cached := func() (string, bool) {
+		if entry == nil {
+			return "", false
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.File))
+		return string(content), err == nil
+	}
+
+	// Nothing was pushed since the last sync
+	if entry != nil && entry.PushedAt.Equal(repo.GetPushedAt().Time) {
+		if content, ok := cached(); ok {
+			return content, entry, syncUnchanged, nil
+		}
+	}
+
+	owner, repoName := repo.GetOwner().GetLogin(), repo.GetName()
+	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%v/%v/readme", owner, repoName), nil)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if entry != nil && entry.ETag != "" {
+		if _, ok := cached(); ok {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+	}
+
+	readme := new(github.RepositoryContent)
+	resp, err := retrier.do(ctx, func() (*github.Response, error) {
+		return client.Do(ctx, req, readme)
+	})
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		if content, ok := cached(); ok {
+			updated := *entry
+			updated.PushedAt = repo.GetPushedAt().Time
+			return content, &updated, syncUnchanged, nil
+		}
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", nil, syncMissing, nil
+		}
+		return "", nil, "", err
+	}
+
+	content, err := readme.GetContent()
+	if err != nil {
+		return "", nil, "", fmt.Errorf("decoding README: %v", err)
+	}
+
+	file := readmeFilename(name)
+	if err := writeFileAtomic(filepath.Join(dir, file), []byte(content)); err != nil {
+		return "", nil, "", fmt.Errorf("writing README to file: %v", err)
+	}
+
+	return content, &SyncEntry{
+		FullName:  repo.GetFullName(),
+		File:      file,
+		SHA:       readme.GetSHA(),
+		ETag:      resp.Header.Get("ETag"),
+		PushedAt:  repo.GetPushedAt().Time,
+		FetchedAt: time.Now(),
+	}, syncFetched, nil
+}
+
+// pruneSyncIndex removes entries and files for repositories of source that are
+// no longer listed; entries from other sources are kept
+func pruneSyncIndex(dir string, index *SyncIndex, listed map[string]bool, source string) []string {
+	var removed []string
+	for name, entry := range index.Entries {
+		if listed[name] || entry.source() != source {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		delete(index.Entries, name)
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// summary is the one-line overview shown next to a README in the selection
+func (md *RepoMetadata) summary() string {
+	var parts []string
+	if shares := md.languageShares(); len(shares) > 0 {
+		parts = append(parts, strings.Fields(shares[0])[0])
+	}
+	if md.Path != "" {
+		parts = append(parts, "local", fmt.Sprintf("%d of %d commits yours", md.Commits, md.TotalCommits))
+		if !md.PushedAt.IsZero() {
+			parts = append(parts, "last commit "+md.PushedAt.Format("2006-01-02"))
+		}
+		return strings.Join(parts, " · ")
+	}
+	parts = append(parts, fmt.Sprintf("★ %d", md.Stars), fmt.Sprintf("⑂ %d", md.Forks))
+	if !md.PushedAt.IsZero() {
+		parts = append(parts, "pushed "+md.PushedAt.Format("2006-01-02"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// details lists the remaining metadata, one line per field
+func (md *RepoMetadata) details() []string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	add("Description", md.Description)
+	add("Path", md.Path)
+	if md.Path != "" {
+		add("Remote", md.URL)
+	}
+	add("Manifests", strings.Join(md.Manifests, ", "))
+	add("Languages", strings.Join(md.languageShares(), ", "))
+	add("Stack", strings.Join(stackNames(md.Stack), ", "))
+	add("Topics", strings.Join(md.Topics, ", "))
+	add("Homepage", md.Homepage)
+	add("License", md.License)
+	if !md.CreatedAt.IsZero() {
+		add("Created", md.CreatedAt.Format("2006-01-02"))
+	}
+	return lines
+}
+
+// readmeResults collects the outcome of syncing each repository's README.
+// It is shared by the sync workers, so every method locks.
+type readmeResults struct {
+	mu        sync.Mutex
+	dir       string // Where the READMEs of earlier syncs are stored
+	index     *SyncIndex
+	send      func(tea.Msg)
+	contents  map[string]string        // README contents by name
+	metadata  map[string]*RepoMetadata // Repository metadata by name
+	sources   map[string]string        // README sources by name
+	names     []string                 // Names of the READMEs kept
+	listed    map[string]bool          // Repositories seen; the rest are pruned
+	total     int
+	fetched   int
+	unchanged int
+	failed    int
+}
+
+// newReadmeResults starts collecting results into the index of dir
+func newReadmeResults(dir string, index *SyncIndex, send func(tea.Msg)) *readmeResults {
+	return &readmeResults{
+		dir:      dir,
+		index:    index,
+		send:     send,
+		contents: make(map[string]string),
+		metadata: make(map[string]*RepoMetadata),
+		sources:  make(map[string]string),
+		listed:   make(map[string]bool),
+	}
+}
+
+// logf sends a log line
+func (r *readmeResults) logf(format string, args ...interface{}) {
+	r.send(LogMsg(fmt.Sprintf(format, args...)))
+}
+
+// start adds n repositories to the total shown in the progress bar
+func (r *readmeResults) start(n int) {
+	r.mu.Lock()
+	r.total += n
+	total := r.total
+	r.mu.Unlock()
+	r.logf("Found %d repositories.", total)
+	r.send(FetchStartedMsg{Total: total})
+}
+
+// skip removes a repository that turned out to be filtered from the total
+func (r *readmeResults) skip() {
+	r.mu.Lock()
+	r.total--
+	total := r.total
+	r.mu.Unlock()
+	r.send(FetchStartedMsg{Total: total})
+}
+
+// list marks a repository as still present, so its README is not pruned
+func (r *readmeResults) list(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listed[name] = true
+}
+
+// record stores the outcome of syncing one repository and reports it. A
+// repository that fails keeps the README of its last sync.
+func (r *readmeResults) record(name, content string, updated *SyncEntry, outcome string, err error) {
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			r.logf("Error fetching README for %s: %v", name, err)
+		}
+		r.mu.Lock()
+		r.failed++
+		if entry := r.index.Entries[name]; entry != nil {
+			if cached, readErr := os.ReadFile(filepath.Join(r.dir, entry.File)); readErr == nil {
+				r.keep(name, string(cached), entry)
+			}
+		}
+		r.mu.Unlock()
+		r.send(ReadmeFetchedMsg{Name: name, Err: err})
+		return
+	}
+
+	r.mu.Lock()
+	switch outcome {
+	case syncMissing:
+		// No README; forget any copy from an earlier sync
+		delete(r.listed, name)
+	default:
+		r.index.Entries[name] = updated
+		r.keep(name, content, updated)
+		if outcome == syncUnchanged {
+			r.unchanged++
+		} else {
+			r.fetched++
+		}
+	}
+	r.mu.Unlock()
+
+	if outcome == syncFetched {
+		r.logf("Fetched README for repository: %s", name)
+	}
+	r.send(ReadmeFetchedMsg{Name: name, Outcome: outcome})
+}
+
+// keep adds a README to the results; the caller holds the lock
+func (r *readmeResults) keep(name, content string, entry *SyncEntry) {
+	r.contents[name] = content
+	r.metadata[name] = entry.Metadata
+	r.sources[name] = entry.source()
+	r.names = append(r.names, name)
+}
@@ -0,0 +1,179 @@
+// Filename: sync_test.go
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/go-github/v45/github"
+)
+
+func TestSyncReadmeReusesUnchangedReadme(t *testing.T) {
+	var requests []string // If-None-Match of each request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/octo/demo/readme" {
+			http.NotFound(w, r)
+			return
+		}
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		json.NewEncoder(w).Encode(map[string]string{
+			"sha":      "abc",
+			"encoding": "base64",
+			"content":  base64.StdEncoding.EncodeToString([]byte("# Demo\n")),
+		})
+	}))
+	defer server.Close()
+	client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/v3/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultGitHubConfig()
+	cfg.MaxRetries = 0
+	retrier := newGitHubRetrier(cfg, nil)
+	ctx := context.Background()
+	dir := t.TempDir()
+	pushed := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repo := func(pushedAt time.Time) *github.Repository {
+		return &github.Repository{
+			Name:     github.String("demo"),
+			FullName: github.String("octo/demo"),
+			Owner:    &github.User{Login: github.String("octo")},
+			PushedAt: &github.Timestamp{Time: pushedAt},
+		}
+	}
+
+	// First sync downloads the README
+	content, entry, outcome, err := syncReadme(ctx, client, retrier, dir, "demo", repo(pushed), nil)
+	if err != nil || outcome != syncFetched || content != "# Demo\n" {
+		t.Fatalf("first sync: %q, %s, %v", content, outcome, err)
+	}
+	if entry.ETag != `"v1"` || entry.SHA != "abc" || !entry.PushedAt.Equal(pushed) {
+		t.Errorf("first sync entry = %+v", entry)
+	}
+	file := filepath.Join(dir, entry.File)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing pushed since: no request at all
+	_, same, outcome, err := syncReadme(ctx, client, retrier, dir, "demo", repo(pushed), entry)
+	if err != nil || outcome != syncUnchanged || same != entry || len(requests) != 1 {
+		t.Errorf("unchanged repository: %s, %v after %d requests", outcome, err, len(requests))
+	}
+
+	// A push that left the README alone is answered with 304
+	later := pushed.Add(time.Hour)
+	content, updated, outcome, err := syncReadme(ctx, client, retrier, dir, "demo", repo(later), entry)
+	if err != nil || outcome != syncUnchanged || content != "# Demo\n" {
+		t.Fatalf("304: %q, %s, %v", content, outcome, err)
+	}
+	if requests[len(requests)-1] != `"v1"` {
+		t.Errorf("requests = %q, want the ETag sent", requests)
+	}
+	if !updated.PushedAt.Equal(later) || updated.ETag != `"v1"` || !entry.PushedAt.Equal(pushed) {
+		t.Errorf("updated entry = %+v, original = %+v", updated, entry)
+	}
+	if info, err := os.Stat(file); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("unchanged README was rewritten: %v", err)
+	}
+
+	// Without the cached file the ETag is not sent, so the README is downloaded again
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	content, _, outcome, err = syncReadme(ctx, client, retrier, dir, "demo", repo(later.Add(time.Hour)), updated)
+	if err != nil || outcome != syncFetched || content != "# Demo\n" || requests[len(requests)-1] != "" {
+		t.Errorf("missing cache: %q, %s, %v, requests %q", content, outcome, err, requests)
+	}
+
+	// Repositories without a README are not an error
+	missing := repo(pushed)
+	missing.Name = github.String("empty")
+	if _, _, outcome, err := syncReadme(ctx, client, retrier, dir, "empty", missing, nil); err != nil || outcome != syncMissing {
+		t.Errorf("missing README: %s, %v", outcome, err)
+	}
+}
+
+func TestPruneSyncIndex(t *testing.T) {
+	dir := t.TempDir()
+	index := &SyncIndex{Entries: map[string]*SyncEntry{
+		"kept":          {File: readmeFilename("kept")},
+		"stale":         {File: readmeFilename("stale")},
+		"stale-no-file": {File: readmeFilename("stale-no-file")},
+		"@gitlab/other": {File: readmeFilename("@gitlab/other"), Source: sourceGitLab},
+	}}
+	for _, name := range []string{"kept", "stale", "@gitlab/other"} {
+		if err := os.WriteFile(filepath.Join(dir, readmeFilename(name)), []byte("# "+name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed := pruneSyncIndex(dir, index, map[string]bool{"kept": true}, sourceGitHub)
+	if want := []string{"stale", "stale-no-file"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	var names []string
+	for name := range index.Entries {
+		names = append(names, name)
+	}
+	if len(index.Entries) != 2 || index.Entries["kept"] == nil || index.Entries["@gitlab/other"] == nil {
+		t.Errorf("entries left = %q", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, readmeFilename("stale"))); !os.IsNotExist(err) {
+		t.Errorf("stale README file was not removed: %v", err)
+	}
+	for _, name := range []string{"kept", "@gitlab/other"} {
+		if _, err := os.Stat(filepath.Join(dir, readmeFilename(name))); err != nil {
+			t.Errorf("README of %s was removed: %v", name, err)
+		}
+	}
+}
+
+func TestReadmeResultsKeepCachedReadmeOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	md := &RepoMetadata{Description: "A demo"}
+	index := &SyncIndex{Entries: map[string]*SyncEntry{
+		"demo":    {File: readmeFilename("demo"), Metadata: md},
+		"no-file": {File: readmeFilename("no-file")},
+	}}
+	if err := os.WriteFile(filepath.Join(dir, readmeFilename("demo")), []byte("# Demo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []ReadmeFetchedMsg
+	results := newReadmeResults(dir, index, func(msg tea.Msg) {
+		if fetched, ok := msg.(ReadmeFetchedMsg); ok {
+			msgs = append(msgs, fetched)
+		}
+	})
+	for _, name := range []string{"demo", "no-file", "new"} {
+		results.record(name, "", nil, "", errors.New("HTTP 502"))
+	}
+
+	if results.failed != 3 || len(msgs) != 3 {
+		t.Errorf("got %d failed and %d messages, want 3 of each", results.failed, len(msgs))
+	}
+	if want := []string{"demo"}; !reflect.DeepEqual(results.names, want) {
+		t.Errorf("kept %q, want %q", results.names, want)
+	}
+	if results.contents["demo"] != "# Demo" || results.metadata["demo"] != md || results.sources["demo"] != sourceGitHub {
+		t.Errorf("got %q from %q with %+v, want the README of the last sync", results.contents["demo"], results.sources["demo"], results.metadata["demo"])
+	}
+}
@@ -2,6 +2,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,15 +28,19 @@ func (m *model) View() string {
 		s.WriteString(m.viewPerforming())
 	case stateSelectREADMEs:
 		s.WriteString(m.viewReadmeSelection())
+	case stateSelectFormat:
+		s.WriteString(m.viewFormatSelection())
 	case stateViewingLogs:
 		s.WriteString(m.viewLogs())
+	case stateEditingProfile:
+		s.WriteString(m.viewProfileEditor())
 	case stateChatWithProfile:
 		s.WriteString("Chat with Profile:\n")
 		for _, msg := range m.chatHistory {
 			s.WriteString(msg + "\n")
 		}
 		s.WriteString("\n" + m.chatInput)
-		s.WriteString("\n\nPress Enter to send, Ctrl+C to quit.")
+		s.WriteString("\n\nPress Enter to send, Esc to return to the menu, Ctrl+C to quit.")
 
 	}
 
@@ -57,11 +63,15 @@ func (m *model) viewFileSelection() string {
 		if m.cursor == i {
 			cursor = selectedStyle.Render("❯ ")
 		}
+		path := filepath.Join(m.directory, file.Name())
 		selected := "[ ]"
-		if contains(m.selected, filepath.Join(m.directory, file.Name())) {
+		if contains(m.selected, path) {
 			selected = "[x]"
 		}
 		s.WriteString(fmt.Sprintf("%s%s %s\n", cursor, selected, file.Name()))
+		if errMsg, ok := m.fileErrors[path]; ok {
+			s.WriteString(errorStyle.Render("      "+errMsg) + "\n")
+		}
 	}
 
 	if m.message != "" {
@@ -73,13 +83,14 @@ func (m *model) viewFileSelection() string {
 	return s.String()
 }
 
+// mainMenuOptions lists the main menu entries in display order
+var mainMenuOptions = []string{"Generate Resume", "Generate Cover Letter", "Fetch READMEs", "Scan Local Repositories", "Select READMEs", "Edit Profile", "Chat with Profile", "View Logs", "Quit"}
+
 func (m *model) viewMainMenu() string {
 	var s strings.Builder
 
 	s.WriteString(titleStyle.Render("\nAI-Powered Actions:\n\n"))
-	menuOptions := []string{"Generate Resume", "Generate Cover Letter", "Fetch GitHub READMEs", "Chat with Profile", "View Logs", "Quit"}
-
-	for i, option := range menuOptions {
+	for i, option := range mainMenuOptions {
 		prefix := "  "
 		if m.cursor == i {
 			prefix = selectedStyle.Render("❯ ")
@@ -96,18 +107,48 @@ func (m *model) viewMainMenu() string {
 	return s.String()
 }
 
+// viewFormatSelection renders the resume format choice
+func (m *model) viewFormatSelection() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("\nChoose the resume format:\n\n"))
+	for i, format := range resumeFormats {
+		label := fmt.Sprintf("%s (%s)", format.label, resumeOutputPath(m.paths.ResumeOutput, format.name))
+		if m.cursor == i {
+			s.WriteString(selectedStyle.Render("❯ "+label) + "\n")
+		} else {
+			s.WriteString("  " + normalStyle.Render(label) + "\n")
+		}
+	}
+	if resumeFormats[m.cursor].name == formatLaTeX {
+		s.WriteString("\n" + normalStyle.Render(fmt.Sprintf("LaTeX template: %s", m.resumeConfig.LaTeXTemplate)))
+		s.WriteString("\n\nPress enter to generate, t to change the template, esc to go back.")
+	} else {
+		s.WriteString("\n" + normalStyle.Render(fmt.Sprintf("HTML and PDF theme: %s", m.resumeConfig.Theme)))
+		s.WriteString("\n\nPress enter to generate, t to change the theme, esc to go back.")
+	}
+
+	return s.String()
+}
+
 // viewPerforming renders the performing action screen
 func (m *model) viewPerforming() string {
 	var s strings.Builder
 
 	if m.progressActive {
 		s.WriteString("\n" + m.progress.View())
+		s.WriteString(fmt.Sprintf("\n%d/%d repositories processed", m.fetchedCount, m.totalRepos))
+		if m.rateLimit.Limit > 0 || !m.rateLimit.WaitUntil.IsZero() {
+			s.WriteString("\n" + normalStyle.Render(m.rateLimit.String()))
+		}
 	} else if m.spinnerActive {
 		s.WriteString("\n" + m.spinner.View() + " " + messageStyle.Render(m.message))
 	} else {
 		s.WriteString("\n" + messageStyle.Render(m.message))
 	}
 
+	s.WriteString("\n\nPress Esc to cancel.")
+
 	return s.String()
 }
 
@@ -127,7 +168,16 @@ func (m *model) viewReadmeSelection() string {
 		if m.selectedREADMEs[name] {
 			selected = "[x]"
 		}
-		s.WriteString(fmt.Sprintf("%s%s %s\n", cursor, selected, name))
+		s.WriteString(fmt.Sprintf("%s%s %s", cursor, selected, name))
+		if md := m.repoMetadata[name]; md != nil {
+			s.WriteString("  " + normalStyle.Render(md.summary()))
+			if m.cursor == i {
+				for _, line := range md.details() {
+					s.WriteString("\n      " + normalStyle.Render(line))
+				}
+			}
+		}
+		s.WriteString("\n")
 	}
 
 	if m.message != "" {
@@ -158,6 +208,28 @@ func (m *model) viewLogs() string {
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	// Unwrap the messages of background commands, dropping those of actions
+	// that were cancelled or replaced; their log lines are still kept
+	if tagged, ok := msg.(actionMsg); ok {
+		if _, isLog := tagged.msg.(LogMsg); !isLog && tagged.id != m.actionID {
+			return m, nil
+		}
+		msg = tagged.msg
+	}
+
+	// Messages from background commands that apply in every state
+	switch msg := msg.(type) {
+	case LogMsg:
+		m.addLog(string(msg))
+		return m, nil
+	case RateLimitMsg:
+		if !msg.WaitUntil.IsZero() {
+			m.addLog(fmt.Sprintf("%s reached; pausing until %s.", msg.title(), msg.WaitUntil.Format(time.RFC3339)))
+		}
+		m.rateLimit = msg
+		return m, nil
+	}
+
 	switch m.state {
 	case stateSelectingFiles:
 		switch msg := msg.(type) {
@@ -171,9 +243,10 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.cursor < len(m.choices)-1 {
 					m.cursor++
 				}
-			case "space":
+			case " ":
 				file := m.choices[m.cursor]
 				filePath := filepath.Join(m.directory, file.Name())
+				delete(m.fileErrors, filePath)
 				if contains(m.selected, filePath) {
 					m.selected = remove(m.selected, filePath)
 					m.message = fmt.Sprintf("Deselected: %s", file.Name())
@@ -182,6 +255,27 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.message = fmt.Sprintf("Selected: %s", file.Name())
 				}
 			case "enter":
+				// Add the selected files to the profile. Files that cannot be
+				// parsed are deselected and their errors shown next to them.
+				m.fileErrors = make(map[string]string)
+				imported := 0
+				for _, file := range m.selected {
+					if err := m.profile.importFile(file); err != nil {
+						m.fileErrors[file] = err.Error()
+						m.selected = remove(m.selected, file)
+						m.addLog(err.Error())
+						continue
+					}
+					imported++
+					m.addLog(fmt.Sprintf("Imported %s into the profile.", file))
+				}
+				if imported > 0 {
+					m.saveProfile()
+				}
+				if len(m.fileErrors) > 0 {
+					m.message = fmt.Sprintf("%d file(s) could not be imported. Press enter again to continue without them.", len(m.fileErrors))
+					return m, nil
+				}
 				m.state = stateMainMenu
 				m.cursor = 0
 				m.message = ""
@@ -221,52 +315,92 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.cursor--
 				}
 			case "down", "j":
-				if m.cursor < 4 { // Adjust if more menu options are added
+				if m.cursor < len(mainMenuOptions)-1 {
 					m.cursor++
 				}
 			case "enter":
 				switch m.cursor {
-				case 0: // Generate Resume
-					m.action = actionGenerateResume
-					m.state = statePerforming
-					m.spinnerActive = true
-					m.message = "Generating resume using OpenAI..."
-					m.startTime = time.Now()
-					m.addLog("Initiated resume generation.")
-					return m, tea.Batch(m.spinner.Tick, generateResume(m))
+				case 0: // Generate Resume, after choosing the format
+					m.state = stateSelectFormat
+					m.cursor = 0
+					for i, format := range resumeFormats {
+						if format.name == m.resumeConfig.Format {
+							m.cursor = i
+						}
+					}
+					m.message = ""
+					return m, nil
 
 				case 1: // Generate Cover Letter
 					m.action = actionGenerateCoverLetter
 					m.state = statePerforming
 					m.spinnerActive = true
-					m.message = "Generating cover letter using OpenAI..."
+					m.message = fmt.Sprintf("Generating cover letter using %s...", m.llm.Name())
 					m.startTime = time.Now()
 					m.addLog("Initiated cover letter generation.")
-					return m, tea.Batch(m.spinner.Tick, m.generateCoverLetter)
+					return m, tea.Batch(m.spinner.Tick, m.generateCoverLetter(m.newActionContext()))
 
-				case 2: // Fetch GitHub READMEs
+				case 2: // Fetch READMEs
 					m.action = actionFetchREADMEs
+					m.fetchedCount = 0
+					m.failedCount = 0
+					m.totalRepos = 0
+					m.rateLimit = RateLimitMsg{}
 					m.state = statePerforming
 					m.spinnerActive = true
 					m.progressActive = true
-					m.message = "Fetching README files from GitHub..."
+					m.message = fmt.Sprintf("Fetching README files from %s...", strings.Join(m.sources, ", "))
 					m.startTime = time.Now()
-					m.addLog("Initiated fetching GitHub READMEs.")
-					return m, tea.Batch(m.spinner.Tick, m.fetchGitHubREADMEs())
+					m.addLog("Initiated fetching READMEs.")
+					return m, tea.Batch(m.spinner.Tick, m.fetchRepoREADMEs(m.newActionContext()))
+
+				case 3: // Scan Local Repositories
+					m.action = actionScanLocalRepos
+					m.fetchedCount = 0
+					m.failedCount = 0
+					m.totalRepos = 0
+					m.rateLimit = RateLimitMsg{}
+					m.state = statePerforming
+					m.spinnerActive = true
+					m.progressActive = true
+					m.message = "Scanning local git repositories..."
+					m.startTime = time.Now()
+					m.addLog("Initiated scanning local repositories.")
+					return m, tea.Batch(m.spinner.Tick, m.scanLocalRepos(m.newActionContext()))
 
-				case 3: // Chat with Profile (This is the new block)
+				case 4: // Select READMEs from the last sync
+					if len(m.readmeList) == 0 {
+						m.message = "No READMEs synced yet."
+						return m, nil
+					}
+					m.state = stateSelectREADMEs
+					m.cursor = 0
+					m.message = fmt.Sprintf("%d READMEs available.", len(m.readmeList))
+					m.addLog("Opened README selection from main menu.")
+					return m, nil
+
+				case 5: // Edit Profile
+					m.state = stateEditingProfile
+					m.cursor = 0
+					m.editingField = false
+					m.profileFields = profileFields(m.profile)
+					m.message = ""
+					m.addLog("Opened profile editor.")
+					return m, nil
+
+				case 6: // Chat with Profile
 					m.state = stateChatWithProfile
 					m.cursor = 0
 					m.addLog("Started chat with profile.")
 					return m, nil
 
-				case 4: // View Logs
+				case 7: // View Logs
 					m.state = stateViewingLogs
 					m.cursor = 0
 					m.message = ""
 					m.addLog("Opened log view from main menu.")
 
-				case 5: // Quit
+				case 8: // Quit
 					m.addLog("Application terminated by user.")
 					return m, tea.Quit
 				}
@@ -278,6 +412,20 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case statePerforming:
 		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "esc", "ctrl+c":
+				m.finishAction()
+				duration := time.Since(m.startTime)
+				m.spinnerActive = false
+				m.progressActive = false
+				m.message = fmt.Sprintf("Action cancelled after %v.", duration.Round(time.Millisecond))
+				m.state = stateMainMenu
+				m.cursor = 0
+				m.addLog(fmt.Sprintf("Cancelled action '%s' after %v.", m.action, duration))
+			}
+			return m, nil
+
 		case spinner.TickMsg:
 			var cmds []tea.Cmd
 			m.spinner, cmd = m.spinner.Update(msg)
@@ -287,22 +435,47 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, tea.Batch(cmds...)
 
-		case FetchProgressMsg:
-			// Update the progress bar with each fetched README
+		case FetchStartedMsg:
+			m.totalRepos = msg.Total
+			return m, m.updateProgressBar()
+
+		case ReadmeFetchedMsg:
+			if errors.Is(msg.Err, context.Canceled) {
+				return m, nil
+			}
+			// Update the progress bar with each processed repository
 			m.fetchedCount++
+			if msg.Err != nil {
+				m.failedCount++
+			}
 			m.addLog(fmt.Sprintf("Progress Update: %d/%d", m.fetchedCount, m.totalRepos))
 			return m, m.updateProgressBar()
 
 		case FetchCompleteMsg:
+			m.finishAction()
 			m.addLog("Received FetchCompleteMsg")
+			m.mergeReadmes(msg)
+			m.profile.refreshReadmes(msg.Readmes)
+			if msg.Contributions != nil {
+				m.profile.setContributions(msg.Contributions)
+			}
+			if contains(msg.Sources, sourceLocal) {
+				m.profile.setLocalContributions(msg.Metadata)
+			}
+			m.profile.setStack(m.repoMetadata)
+			m.saveProfile()
 			m.spinnerActive = false
 			m.progressActive = false
 			m.state = stateSelectREADMEs
 			m.cursor = 0
-			m.message = "README fetching complete."
+			m.message = fmt.Sprintf("README sync complete: %d updated, %d unchanged, %d failed.", msg.Fetched, msg.Unchanged, msg.Failed)
+			if len(msg.FailedSources) > 0 {
+				m.message += fmt.Sprintf(" Could not fetch from %s; see the logs.", strings.Join(msg.FailedSources, ", "))
+			}
 			return m, nil
 
 		case string:
+			m.finishAction()
 			duration := time.Since(m.startTime)
 			m.spinnerActive = false
 			m.progressActive = false
@@ -312,6 +485,11 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case error:
+			if errors.Is(msg, context.Canceled) {
+				// Already handled when the user cancelled
+				return m, nil
+			}
+			m.finishAction()
 			m.spinnerActive = false
 			m.progressActive = false
 			m.err = msg
@@ -334,6 +512,9 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.cursor++
 				}
 			case " ":
+				if m.cursor >= len(m.readmeList) {
+					return m, nil
+				}
 				name := m.readmeList[m.cursor]
 				m.selectedREADMEs[name] = !m.selectedREADMEs[name]
 				if m.selectedREADMEs[name] {
@@ -345,9 +526,19 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case "enter":
+				// The selected READMEs become the profile's README projects
+				var names []string
+				for _, name := range m.readmeList {
+					if m.selectedREADMEs[name] {
+						names = append(names, name)
+					}
+				}
+				m.profile.selectReadmes(names, m.readmes, m.readmeSources)
+				m.profile.setStack(m.repoMetadata)
+				m.saveProfile()
 				m.state = stateMainMenu
 				m.cursor = 0
-				m.message = "Proceeding to main menu."
+				m.message = fmt.Sprintf("Profile now includes %d README projects.", len(names))
 				m.addLog("Returned to main menu from README selection.")
 				return m, nil
 			case "l":
@@ -362,6 +553,44 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case stateSelectFormat:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "up", "k":
+				if m.cursor > 0 {
+					m.cursor--
+				}
+			case "down", "j":
+				if m.cursor < len(resumeFormats)-1 {
+					m.cursor++
+				}
+			case "t":
+				if resumeFormats[m.cursor].name == formatLaTeX {
+					m.resumeConfig.LaTeXTemplate = nextName(latexTemplateNames(), m.resumeConfig.LaTeXTemplate)
+				} else {
+					m.resumeConfig.Theme = nextName(resumeThemeNames(), m.resumeConfig.Theme)
+				}
+			case "enter":
+				format := resumeFormats[m.cursor]
+				m.resumeConfig.Format = format.name
+				m.action = actionGenerateResume
+				m.state = statePerforming
+				m.spinnerActive = true
+				m.message = fmt.Sprintf("Generating %s resume using %s...", format.label, m.llm.Name())
+				m.startTime = time.Now()
+				m.addLog("Initiated resume generation.")
+				return m, tea.Batch(m.spinner.Tick, generateResume(m.newActionContext(), m, format.name))
+			case "esc":
+				m.state = stateMainMenu
+				m.cursor = 0
+				m.message = ""
+			case "ctrl+c", "q":
+				m.addLog("Application terminated by user.")
+				return m, tea.Quit
+			}
+		}
+
 	case stateViewingLogs:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -377,13 +606,39 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case stateEditingProfile:
+		return m.updateProfileEditor(msg)
+
 	case stateChatWithProfile:
 		switch msg := msg.(type) {
+		case ChatReplyMsg:
+			if errors.Is(msg.Err, context.Canceled) {
+				return m, nil
+			}
+			m.finishAction()
+			if msg.Err != nil {
+				m.err = msg.Err
+				m.addLog(msg.Err.Error())
+				return m, nil
+			}
+			m.err = nil
+			m.chatHistory = append(m.chatHistory, "Assistant: "+msg.Reply)
+			return m, nil
+
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
 				return m, m.sendChatMessage() // Send the chat message
+			case "esc":
+				// Abandon any pending reply and leave the chat
+				m.finishAction()
+				m.state = stateMainMenu
+				m.cursor = 0
+				m.message = "Chat closed."
+				m.addLog("Closed chat with profile.")
+				return m, nil
 			case "ctrl+c":
+				m.finishAction()
 				return m, tea.Quit
 			default:
 				// Update chat input
@@ -404,6 +659,3 @@ func (m *model) updateProgressBar() tea.Cmd {
 	m.addLog(fmt.Sprintf("Setting progress bar to %.2f%%", percent*100))
 	return m.progress.SetPercent(percent)
 }
-
-// Define a new message type for fetch progress
-type FetchProgressMsg struct{}
@@ -0,0 +1,221 @@
+// Filename: ui_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestModel creates a model whose files live in a temporary directory and
+// whose provider is the fake one, or llm when given
+func newTestModel(t *testing.T, llm LLMProvider) *model {
+	t.Helper()
+	dir := t.TempDir()
+	settings := defaultSettings()
+	settings.Paths.ReadmesDir = filepath.Join(dir, "readmes")
+	settings.Paths.ProfileFile = filepath.Join(dir, "profile.json")
+	settings.Paths.ResumeOutput = filepath.Join(dir, "generated_resume.md")
+	settings.Paths.CoverLetterOutput = filepath.Join(dir, "generated_cover_letter.txt")
+	if llm == nil {
+		fake, err := newFakeProvider("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		llm = fake
+	}
+	return initialModel(nil, &app{Config: &Config{Settings: settings}, llm: llm})
+}
+
+// blockingProvider answers nothing until the request is cancelled
+type blockingProvider struct{ fakeProvider }
+
+func (p *blockingProvider) Complete(ctx context.Context, req CompletionRequest) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (p *blockingProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	return p.Complete(ctx, req)
+}
+
+// update applies msg and returns the model for further checks
+func update(t *testing.T, m *model, msg tea.Msg) *model {
+	t.Helper()
+	updated, _ := m.Update(msg)
+	return updated.(*model)
+}
+
+func TestUpdateLogMsg(t *testing.T) {
+	m := newTestModel(t, nil)
+	m = update(t, m, LogMsg("plain line"))
+	m.newActionContext()
+	// Log lines are kept even from an action that was replaced
+	m = update(t, m, actionMsg{id: m.actionID - 1, msg: LogMsg("stale line")})
+	m = update(t, m, actionMsg{id: m.actionID, msg: LogMsg("current line")})
+
+	logs := strings.Join(m.logs, "\n")
+	for _, line := range []string{"plain line", "stale line", "current line"} {
+		if !strings.Contains(logs, line) {
+			t.Errorf("logs do not contain %q:\n%s", line, logs)
+		}
+	}
+}
+
+func TestUpdateReadmeFetchedMsg(t *testing.T) {
+	m := newTestModel(t, nil)
+	m.state = statePerforming
+	m.newActionContext()
+	id := m.actionID
+
+	m = update(t, m, actionMsg{id: id, msg: FetchStartedMsg{Total: 3}})
+	m = update(t, m, actionMsg{id: id, msg: ReadmeFetchedMsg{Name: "a", Outcome: syncFetched}})
+	m = update(t, m, actionMsg{id: id, msg: ReadmeFetchedMsg{Name: "b", Err: errors.New("boom")}})
+	// Repositories interrupted by a cancellation are not progress
+	m = update(t, m, actionMsg{id: id, msg: ReadmeFetchedMsg{Name: "c", Err: context.Canceled}})
+	// Nor are those of an earlier action
+	m = update(t, m, actionMsg{id: id - 1, msg: ReadmeFetchedMsg{Name: "d", Outcome: syncFetched}})
+
+	if m.totalRepos != 3 || m.fetchedCount != 2 || m.failedCount != 1 {
+		t.Errorf("progress = %d/%d with %d failed, want 2/3 with 1 failed", m.fetchedCount, m.totalRepos, m.failedCount)
+	}
+}
+
+func TestUpdateFetchCompleteMsg(t *testing.T) {
+	m := newTestModel(t, nil)
+	m.state = statePerforming
+	ctx := m.newActionContext()
+
+	m = update(t, m, actionMsg{id: m.actionID, msg: FetchCompleteMsg{
+		Sources:       []string{sourceGitHub},
+		Readmes:       map[string]string{"demo": "# Demo\n\nA demo service.\n"},
+		Metadata:      map[string]*RepoMetadata{"demo": {Description: "A demo service"}},
+		ReadmeSources: map[string]string{"demo": sourceGitHub},
+		Names:         []string{"demo"},
+		Fetched:       1,
+		Unchanged:     2,
+	}})
+
+	if m.state != stateSelectREADMEs {
+		t.Errorf("state = %s, want %s", m.state, stateSelectREADMEs)
+	}
+	if len(m.readmeList) != 1 || m.readmes["demo"] == "" || m.repoMetadata["demo"] == nil {
+		t.Errorf("READMEs not merged: %v %v", m.readmeList, m.repoMetadata)
+	}
+	if !strings.Contains(m.message, "1 updated, 2 unchanged, 0 failed") {
+		t.Errorf("message = %q", m.message)
+	}
+	if ctx.Err() == nil || m.cancelAction != nil {
+		t.Error("the action context was not released")
+	}
+}
+
+func TestUpdateChatReplyMsg(t *testing.T) {
+	m := newTestModel(t, nil)
+	m.state = stateChatWithProfile
+	m.chatInput = "What are my strongest projects?"
+
+	// Run the command as Bubble Tea would, off the Update goroutine
+	cmd := m.sendChatMessage()
+	done := make(chan tea.Msg)
+	go func() { done <- cmd() }()
+	m = update(t, m, <-done)
+
+	if len(m.chatHistory) != 2 || !strings.HasPrefix(m.chatHistory[1], "Assistant: ") {
+		t.Fatalf("chat history = %q", m.chatHistory)
+	}
+	if m.cancelAction != nil {
+		t.Error("the action context was not released after the reply")
+	}
+
+	m.newActionContext()
+	m = update(t, m, actionMsg{id: m.actionID, msg: ChatReplyMsg{Err: errors.New("provider down")}})
+	if m.err == nil || len(m.chatHistory) != 2 {
+		t.Errorf("error reply: err = %v, history = %q", m.err, m.chatHistory)
+	}
+	if m.cancelAction != nil {
+		t.Error("the action context was not released after the error")
+	}
+
+	// Replies to a message sent before the chat was left and reopened are dropped
+	m.err = nil
+	stale := m.actionID
+	m.newActionContext()
+	m = update(t, m, actionMsg{id: stale, msg: ChatReplyMsg{Reply: "late"}})
+	if len(m.chatHistory) != 2 {
+		t.Errorf("stale reply was applied: %q", m.chatHistory)
+	}
+}
+
+func TestCancelAction(t *testing.T) {
+	m := newTestModel(t, &blockingProvider{})
+	m.state = statePerforming
+	m.action = actionGenerateCoverLetter
+
+	cmd := m.generateCoverLetter(m.newActionContext())
+	done := make(chan tea.Msg)
+	go func() { done <- cmd() }()
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.state != stateMainMenu || m.cancelAction != nil || !strings.HasPrefix(m.message, "Action cancelled") {
+		t.Fatalf("after esc: state = %s, message = %q", m.state, m.message)
+	}
+
+	// finishAction cancelled the context, so the command returns
+	result := <-done
+	tagged, ok := result.(actionMsg)
+	if !ok {
+		t.Fatalf("command returned %T, want an actionMsg", result)
+	}
+	if err, ok := tagged.msg.(error); !ok || !errors.Is(err, context.Canceled) {
+		t.Fatalf("command returned %v, want context.Canceled", tagged.msg)
+	}
+
+	// The late result must not end the next action
+	m.state = statePerforming
+	m.newActionContext()
+	m = update(t, m, result)
+	m = update(t, m, actionMsg{id: tagged.id, msg: "Cover letter generated"})
+	if m.state != statePerforming || m.cancelAction == nil {
+		t.Errorf("stale result ended the next action: state = %s, message = %q", m.state, m.message)
+	}
+	m.finishAction()
+}
+
+func TestSelectReadmesWithoutSync(t *testing.T) {
+	m := newTestModel(t, nil)
+	m.state = stateMainMenu
+	m.cursor = 4
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.state != stateMainMenu || m.message != "No READMEs synced yet." {
+		t.Errorf("state = %s, message = %q", m.state, m.message)
+	}
+
+	// Toggling in an empty selection list must not panic
+	m.state = stateSelectREADMEs
+	m.cursor = 0
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if len(m.selectedREADMEs) != 0 {
+		t.Errorf("selected = %v", m.selectedREADMEs)
+	}
+}
+
+func TestViewProfileEditorTruncatesByRunes(t *testing.T) {
+	m := newTestModel(t, nil)
+	m.profile.Summary = strings.Repeat("ü", 70)
+	m.profileFields = profileFields(m.profile)
+
+	view := m.viewProfileEditor()
+	if !strings.Contains(view, "Summary: "+strings.Repeat("ü", 57)+"...") {
+		t.Errorf("summary not cut after 57 characters:\n%s", view)
+	}
+	if !utf8.ValidString(view) {
+		t.Error("view contains a split character")
+	}
+}